@@ -34,7 +34,6 @@ export DB_NAME=${DB_NAME:-ykt_db}
 export REDIS_HOST=${REDIS_HOST:-47.84.96.59}
 export REDIS_PORT=${REDIS_PORT:-6379}
 export REDIS_PASSWORD=${REDIS_PASSWORD:-123456}
-export JWT_SECRET=${JWT_SECRET:-ykt-did-platform-secret-key-2024}
 
 # 切换到 Go 应用工作目录
 cd /app
@@ -16,6 +16,7 @@ func getConfig(section, key string) string {
 		"config.ini",
 		filepath.Join("src", "config.ini"),
 		filepath.Join("config", "config.ini"),
+		filepath.Join("src", "config", "config.ini"),
 	}
 
 	for _, path := range configPaths {
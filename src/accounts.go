@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// changeUserType 变更用户类型。已签发凭证中的 userType 和访问令牌中的 user_type 随之失效，
+// 因此在同一事务中撤销该用户的所有凭证并注销所有会话；任一步失败时整体回滚。
+// 用户不存在时返回 gorm.ErrRecordNotFound，类型未变化时不做任何修改
+func changeUserType(db *gorm.DB, did string, userType string) error {
+	changed := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var user User
+		if err := tx.Where("did = ?", did).First(&user).Error; err != nil {
+			return err
+		}
+		if user.UserType == userType {
+			return nil
+		}
+		if err := tx.Model(&User{}).Where("did = ?", did).Update("user_type", userType).Error; err != nil {
+			return err
+		}
+		if err := revokeCredentialsForDID(tx, did, statusReasonUserTypeChanged); err != nil {
+			return err
+		}
+		if _, err := revokeSessionsForDID(tx, did); err != nil {
+			return err
+		}
+		changed = true
+		fmt.Printf("【账户】用户 %s 类型变更: %s -> %s\n", did, user.UserType, userType)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if changed {
+		// 事务提交后再使缓存失效，避免并发请求用提交前的数据重新生成状态列表
+		statusLists.Invalidate()
+	}
+	return nil
+}
+
+// deleteAccount 删除账户：在同一事务中撤销该用户的所有凭证、注销所有会话、删除通行密钥和用户记录。
+// 签发记录、会话和刷新令牌保留（状态列表和已注销会话列表仍需要），安全事件保留用于审计。
+// 用户不存在时返回 gorm.ErrRecordNotFound
+func deleteAccount(db *gorm.DB, did string) error {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var user User
+		if err := tx.Where("did = ?", did).First(&user).Error; err != nil {
+			return err
+		}
+		if err := revokeCredentialsForDID(tx, did, statusReasonAccountDeleted); err != nil {
+			return err
+		}
+		if _, err := revokeSessionsForDID(tx, did); err != nil {
+			return err
+		}
+		if err := tx.Where("did = ?", did).Delete(&WebAuthnCredential{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("did = ?", did).Delete(&User{}).Error; err != nil {
+			return err
+		}
+		fmt.Printf("【账户】用户 %s (%s) 已删除\n", did, user.Email)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	statusLists.Invalidate()
+	return nil
+}
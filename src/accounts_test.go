@@ -0,0 +1,162 @@
+package main
+
+import (
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// fakeUser 查询用户时返回的记录
+func fakeUser(did string, userType string) fakeResult {
+	return fakeResult{
+		match:   "FROM `ykt_users`",
+		columns: []string{"did", "email", "user_type"},
+		rows:    [][]driver.Value{{did, "alice@example.com", userType}},
+	}
+}
+
+// fakeSessions 查询未注销会话时返回的会话ID
+func fakeSessions(ids ...string) fakeResult {
+	rows := make([][]driver.Value, 0, len(ids))
+	for _, id := range ids {
+		rows = append(rows, []driver.Value{id})
+	}
+	return fakeResult{match: "SELECT `id` FROM `ykt_sessions`", columns: []string{"id"}, rows: rows}
+}
+
+// assertInTransaction 断言所有语句都执行过，且都位于同一个已提交的事务中
+func assertInTransaction(t *testing.T, fake *fakeDB, substrs ...string) {
+	t.Helper()
+	statements := fake.Statements()
+	if len(statements) == 0 || statements[0] != "BEGIN" || statements[len(statements)-1] != "COMMIT" {
+		t.Fatalf("语句未在单个已提交事务中执行: %q", statements)
+	}
+	if fake.Index("ROLLBACK") >= 0 {
+		t.Fatalf("事务被回滚: %q", statements)
+	}
+	for _, substr := range substrs {
+		if fake.Index(substr) < 0 {
+			t.Errorf("缺少语句 %q，已执行: %q", substr, statements)
+		}
+	}
+}
+
+// assertRolledBack 断言事务被回滚，且没有执行 substrs 中的语句
+func assertRolledBack(t *testing.T, fake *fakeDB, substrs ...string) {
+	t.Helper()
+	statements := fake.Statements()
+	if fake.Index("ROLLBACK") < 0 || fake.Index("COMMIT") >= 0 {
+		t.Fatalf("事务未回滚: %q", statements)
+	}
+	for _, substr := range substrs {
+		if fake.Index(substr) >= 0 {
+			t.Errorf("不应执行 %q，已执行: %q", substr, statements)
+		}
+	}
+}
+
+func TestChangeUserTypeRevokesCredentialsAndSessions(t *testing.T) {
+	db, fake := newFakeGormDB(t,
+		fakeUser("did:example:alice", "个人"),
+		fakeSessions("change-type-s1", "change-type-s2"),
+		fakeResult{match: "UPDATE `ykt_issued_credentials`", rowsAffected: 2},
+	)
+
+	if err := changeUserType(db, "did:example:alice", "企业"); err != nil {
+		t.Fatalf("changeUserType: %v", err)
+	}
+	assertInTransaction(t, fake,
+		"UPDATE `ykt_users` SET `user_type`",
+		"UPDATE `ykt_issued_credentials` SET `revoked_at`",
+		"UPDATE `ykt_sessions` SET `revoked_at`",
+		"UPDATE `ykt_refresh_tokens` SET `revoked_at`",
+	)
+	for _, id := range []string{"change-type-s1", "change-type-s2"} {
+		if !revokedSessions.Revoked(id) {
+			t.Errorf("会话 %s 未加入已注销列表", id)
+		}
+	}
+}
+
+func TestChangeUserTypeUnchanged(t *testing.T) {
+	db, fake := newFakeGormDB(t, fakeUser("did:example:alice", "个人"))
+
+	if err := changeUserType(db, "did:example:alice", "个人"); err != nil {
+		t.Fatalf("changeUserType: %v", err)
+	}
+	if i := fake.Index("UPDATE"); i >= 0 {
+		t.Fatalf("类型未变化时不应更新: %q", fake.Statements())
+	}
+}
+
+func TestChangeUserTypeNotFound(t *testing.T) {
+	db, fake := newFakeGormDB(t)
+
+	err := changeUserType(db, "did:example:nobody", "企业")
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("err = %v, want gorm.ErrRecordNotFound", err)
+	}
+	assertRolledBack(t, fake, "UPDATE")
+}
+
+func TestChangeUserTypeRollsBackWhenRevocationFails(t *testing.T) {
+	db, fake := newFakeGormDB(t,
+		fakeUser("did:example:alice", "个人"),
+		fakeResult{match: "UPDATE `ykt_issued_credentials`", err: errors.New("数据库不可用")},
+	)
+
+	if err := changeUserType(db, "did:example:alice", "企业"); err == nil {
+		t.Fatal("撤销凭证失败时应返回错误")
+	}
+	assertRolledBack(t, fake, "UPDATE `ykt_sessions`")
+}
+
+func TestDeleteAccountRevokesCredentialsAndSessions(t *testing.T) {
+	db, fake := newFakeGormDB(t,
+		fakeUser("did:example:alice", "个人"),
+		fakeSessions("delete-account-s1"),
+		fakeResult{match: "UPDATE `ykt_issued_credentials`", rowsAffected: 1},
+	)
+
+	if err := deleteAccount(db, "did:example:alice"); err != nil {
+		t.Fatalf("deleteAccount: %v", err)
+	}
+	assertInTransaction(t, fake,
+		"UPDATE `ykt_issued_credentials` SET `revoked_at`",
+		"UPDATE `ykt_sessions` SET `revoked_at`",
+		"UPDATE `ykt_refresh_tokens` SET `revoked_at`",
+		"DELETE FROM `ykt_webauthn_credentials`",
+		"DELETE FROM `ykt_users`",
+	)
+	// 撤销在删除用户之前完成
+	if fake.Index("UPDATE `ykt_issued_credentials`") > fake.Index("DELETE FROM `ykt_users`") {
+		t.Errorf("凭证撤销应在删除用户之前: %q", fake.Statements())
+	}
+	if !revokedSessions.Revoked("delete-account-s1") {
+		t.Error("会话未加入已注销列表")
+	}
+}
+
+func TestDeleteAccountNotFound(t *testing.T) {
+	db, fake := newFakeGormDB(t)
+
+	err := deleteAccount(db, "did:example:nobody")
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("err = %v, want gorm.ErrRecordNotFound", err)
+	}
+	assertRolledBack(t, fake, "UPDATE", "DELETE")
+}
+
+func TestDeleteAccountRollsBackWhenSessionRevocationFails(t *testing.T) {
+	db, fake := newFakeGormDB(t,
+		fakeUser("did:example:alice", "个人"),
+		fakeResult{match: "SELECT `id` FROM `ykt_sessions`", err: errors.New("数据库不可用")},
+	)
+
+	if err := deleteAccount(db, "did:example:alice"); err == nil {
+		t.Fatal("注销会话失败时应返回错误")
+	}
+	assertRolledBack(t, fake, "DELETE FROM `ykt_users`")
+}
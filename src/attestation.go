@@ -0,0 +1,801 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/cosmos-link/did-login/config"
+	"github.com/fxamacker/cbor/v2"
+)
+
+// 证明类型（WebAuthn §6.5.4）
+type attestationType string
+
+const (
+	attestationTypeNone   attestationType = "none"   // 无证明
+	attestationTypeSelf   attestationType = "self"   // 自签名证明
+	attestationTypeBasic  attestationType = "basic"  // 证书链证明（Basic / AttCA）
+	attestationTypeAnonCA attestationType = "anonca" // 匿名CA证明（Apple）
+)
+
+// 证明策略
+const (
+	attestationPolicyNone    = "none"    // 接受任何证明（包括 none）
+	attestationPolicySelf    = "self"    // 至少需要自签名证明
+	attestationPolicyTrusted = "trusted" // 必须链接到受信任的根证书
+)
+
+// 证书扩展 OID
+var (
+	oidFIDOGenCeAAGUID     = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 45724, 1, 1, 4}
+	oidAndroidKeyAttest    = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 1, 17}
+	oidAppleNonce          = asn1.ObjectIdentifier{1, 2, 840, 113635, 100, 8, 2}
+	oidTCGKpAIKCertificate = asn1.ObjectIdentifier{2, 23, 133, 8, 3}
+	oidSubjectAltName      = asn1.ObjectIdentifier{2, 5, 29, 17}
+	oidExtKeyUsage         = asn1.ObjectIdentifier{2, 5, 29, 37}
+)
+
+// attestationResult 证明语句验证结果
+type attestationResult struct {
+	Format    string
+	Type      attestationType
+	TrustPath []*x509.Certificate // x5c 证书链，x5c[0] 为证明证书
+	Trusted   bool                // 证书链是否链接到信任锚
+}
+
+// verifyAttestationStatement 按 fmt 验证证明语句，并评估证书链是否可信
+func verifyAttestationStatement(att *attestationObject, authData *authenticatorData, clientDataHash []byte) (*attestationResult, error) {
+	cred := authData.AttestedCredential
+	credKey, err := parseCOSEKey(cred.CredentialPublicKey)
+	if err != nil {
+		return nil, err
+	}
+	credPub, err := credKey.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	// 证明签名的数据: authenticatorData || clientDataHash
+	attToBeSigned := append(append([]byte{}, authData.Raw...), clientDataHash...)
+
+	var result *attestationResult
+	switch att.Fmt {
+	case "none":
+		result, err = verifyNoneAttestation(att)
+	case "packed":
+		result, err = verifyPackedAttestation(att, cred, credKey, credPub, attToBeSigned)
+	case "fido-u2f":
+		result, err = verifyFIDOU2FAttestation(att, authData, credKey, clientDataHash)
+	case "tpm":
+		result, err = verifyTPMAttestation(att, cred, credPub, attToBeSigned)
+	case "android-key":
+		result, err = verifyAndroidKeyAttestation(att, credPub, attToBeSigned, clientDataHash)
+	case "apple":
+		result, err = verifyAppleAttestation(att, credPub, attToBeSigned)
+	default:
+		return nil, fmt.Errorf("不支持的证明格式: %s", att.Fmt)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s证明验证失败: %v", att.Fmt, err)
+	}
+
+	result.Format = att.Fmt
+	if len(result.TrustPath) > 0 {
+		result.Trusted = verifyAttestationTrustPath(result.TrustPath, formatAAGUID(authData.AttestedCredential.AAGUID)) == nil
+	}
+	return result, nil
+}
+
+// checkAttestationPolicy 按策略判断是否接受该证明
+func checkAttestationPolicy(result *attestationResult, policy string) error {
+	switch policy {
+	case "", attestationPolicyNone:
+		return nil
+	case attestationPolicySelf:
+		if result.Type == attestationTypeNone {
+			return fmt.Errorf("当前策略不接受无证明(none)的认证器")
+		}
+		return nil
+	case attestationPolicyTrusted:
+		if !result.Trusted {
+			return fmt.Errorf("认证器证明未链接到受信任的根证书")
+		}
+		return nil
+	}
+	return fmt.Errorf("未知的证明策略: %s", policy)
+}
+
+// -------------------------- 各格式验证 --------------------------
+
+func verifyNoneAttestation(att *attestationObject) (*attestationResult, error) {
+	if len(att.AttStmt) != 0 {
+		return nil, fmt.Errorf("none格式的attStmt必须为空")
+	}
+	return &attestationResult{Type: attestationTypeNone}, nil
+}
+
+// packed: 有 x5c 时用证明证书验证，否则为自签名证明
+func verifyPackedAttestation(att *attestationObject, cred *attestedCredentialData, credKey *coseKey, credPub crypto.PublicKey, attToBeSigned []byte) (*attestationResult, error) {
+	var alg int
+	var sig []byte
+	if err := attStmtField(att.AttStmt, "alg", &alg, true); err != nil {
+		return nil, err
+	}
+	if err := attStmtField(att.AttStmt, "sig", &sig, true); err != nil {
+		return nil, err
+	}
+	if _, ok := att.AttStmt["ecdaaKeyId"]; ok {
+		return nil, fmt.Errorf("不支持ECDAA证明")
+	}
+
+	chain, err := attStmtCertificates(att.AttStmt, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(chain) == 0 {
+		// 自签名证明：算法必须与凭证公钥一致
+		if alg != credKey.Alg {
+			return nil, fmt.Errorf("自签名证明算法 %d 与凭证算法 %d 不一致", alg, credKey.Alg)
+		}
+		if err := verifyCOSESignature(alg, credPub, attToBeSigned, sig); err != nil {
+			return nil, err
+		}
+		return &attestationResult{Type: attestationTypeSelf}, nil
+	}
+
+	attCert := chain[0]
+	if err := verifyCOSESignature(alg, attCert.PublicKey, attToBeSigned, sig); err != nil {
+		return nil, err
+	}
+
+	// 证明证书要求（§8.2.1）
+	if attCert.Version != 3 {
+		return nil, fmt.Errorf("证明证书版本必须为3")
+	}
+	subject := attCert.Subject
+	if len(subject.Country) == 0 || len(subject.Organization) == 0 || len(subject.CommonName) == 0 {
+		return nil, fmt.Errorf("证明证书主题信息不完整")
+	}
+	if len(subject.OrganizationalUnit) == 0 || subject.OrganizationalUnit[0] != "Authenticator Attestation" {
+		return nil, fmt.Errorf("证明证书OU必须为 Authenticator Attestation")
+	}
+	if attCert.IsCA {
+		return nil, fmt.Errorf("证明证书不能是CA证书")
+	}
+	if err := checkCertificateAAGUID(attCert, cred.AAGUID); err != nil {
+		return nil, err
+	}
+
+	return &attestationResult{Type: attestationTypeBasic, TrustPath: chain}, nil
+}
+
+// fido-u2f: 旧版U2F安全密钥
+func verifyFIDOU2FAttestation(att *attestationObject, authData *authenticatorData, credKey *coseKey, clientDataHash []byte) (*attestationResult, error) {
+	var sig []byte
+	if err := attStmtField(att.AttStmt, "sig", &sig, true); err != nil {
+		return nil, err
+	}
+	chain, err := attStmtCertificates(att.AttStmt, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(chain) != 1 {
+		return nil, fmt.Errorf("x5c必须只包含一个证书")
+	}
+
+	certPub, ok := chain[0].PublicKey.(*ecdsa.PublicKey)
+	if !ok || certPub.Curve != elliptic.P256() {
+		return nil, fmt.Errorf("证明证书公钥必须是P-256")
+	}
+	if credKey.Kty != coseKtyEC2 || credKey.Crv != coseCrvP256 {
+		return nil, fmt.Errorf("U2F凭证公钥必须是P-256")
+	}
+
+	// publicKeyU2F = 0x04 || x || y
+	publicKeyU2F := make([]byte, 0, 65)
+	publicKeyU2F = append(publicKeyU2F, 0x04)
+	publicKeyU2F = append(publicKeyU2F, new(big.Int).SetBytes(credKey.X).FillBytes(make([]byte, 32))...)
+	publicKeyU2F = append(publicKeyU2F, new(big.Int).SetBytes(credKey.Y).FillBytes(make([]byte, 32))...)
+
+	// verificationData = 0x00 || rpIdHash || clientDataHash || credentialId || publicKeyU2F
+	verificationData := []byte{0x00}
+	verificationData = append(verificationData, authData.RPIDHash...)
+	verificationData = append(verificationData, clientDataHash...)
+	verificationData = append(verificationData, authData.AttestedCredential.CredentialID...)
+	verificationData = append(verificationData, publicKeyU2F...)
+
+	digest := sha256.Sum256(verificationData)
+	if !ecdsa.VerifyASN1(certPub, digest[:], sig) {
+		return nil, fmt.Errorf("签名验证失败")
+	}
+
+	return &attestationResult{Type: attestationTypeBasic, TrustPath: chain}, nil
+}
+
+// android-key: Android Keystore 硬件证明
+func verifyAndroidKeyAttestation(att *attestationObject, credPub crypto.PublicKey, attToBeSigned, clientDataHash []byte) (*attestationResult, error) {
+	var alg int
+	var sig []byte
+	if err := attStmtField(att.AttStmt, "alg", &alg, true); err != nil {
+		return nil, err
+	}
+	if err := attStmtField(att.AttStmt, "sig", &sig, true); err != nil {
+		return nil, err
+	}
+	chain, err := attStmtCertificates(att.AttStmt, true)
+	if err != nil {
+		return nil, err
+	}
+
+	attCert := chain[0]
+	if err := verifyCOSESignature(alg, attCert.PublicKey, attToBeSigned, sig); err != nil {
+		return nil, err
+	}
+	if !publicKeysEqual(credPub, attCert.PublicKey) {
+		return nil, fmt.Errorf("证明证书公钥与凭证公钥不一致")
+	}
+
+	ext := findExtension(attCert, oidAndroidKeyAttest)
+	if ext == nil {
+		return nil, fmt.Errorf("证明证书缺少Android密钥证明扩展")
+	}
+
+	var desc struct {
+		AttestationVersion       int
+		AttestationSecurityLevel asn1.Enumerated
+		KeymasterVersion         int
+		KeymasterSecurityLevel   asn1.Enumerated
+		AttestationChallenge     []byte
+		UniqueID                 []byte
+		SoftwareEnforced         asn1.RawValue
+		TeeEnforced              asn1.RawValue
+	}
+	if _, err := asn1.Unmarshal(ext.Value, &desc); err != nil {
+		return nil, fmt.Errorf("解析KeyDescription失败: %v", err)
+	}
+	if !bytes.Equal(desc.AttestationChallenge, clientDataHash) {
+		return nil, fmt.Errorf("attestationChallenge与clientDataHash不一致")
+	}
+
+	software, err := parseAndroidAuthorizationList(desc.SoftwareEnforced.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	tee, err := parseAndroidAuthorizationList(desc.TeeEnforced.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	// 密钥不能对所有应用开放，且必须由密钥库生成、用于签名
+	if software.allApplications || tee.allApplications {
+		return nil, fmt.Errorf("密钥不能设置allApplications")
+	}
+	const kmOriginGenerated, kmPurposeSign = 0, 2
+	if !((tee.hasOrigin && tee.origin == kmOriginGenerated) || (software.hasOrigin && software.origin == kmOriginGenerated)) {
+		return nil, fmt.Errorf("密钥不是由Android密钥库生成")
+	}
+	if !tee.purposes[kmPurposeSign] && !software.purposes[kmPurposeSign] {
+		return nil, fmt.Errorf("密钥用途必须包含签名")
+	}
+
+	return &attestationResult{Type: attestationTypeBasic, TrustPath: chain}, nil
+}
+
+// androidAuthorizationList 只提取验证需要的字段
+type androidAuthorizationList struct {
+	purposes        map[int]bool
+	origin          int
+	hasOrigin       bool
+	allApplications bool
+}
+
+// parseAndroidAuthorizationList 逐个读取 AuthorizationList 中的显式标签字段
+func parseAndroidAuthorizationList(data []byte) (*androidAuthorizationList, error) {
+	const tagPurpose, tagAllApplications, tagOrigin = 1, 600, 702
+
+	list := &androidAuthorizationList{purposes: map[int]bool{}}
+	rest := data
+	for len(rest) > 0 {
+		var field asn1.RawValue
+		var err error
+		rest, err = asn1.Unmarshal(rest, &field)
+		if err != nil {
+			return nil, fmt.Errorf("解析AuthorizationList失败: %v", err)
+		}
+		if field.Class != asn1.ClassContextSpecific {
+			continue
+		}
+
+		switch field.Tag {
+		case tagPurpose:
+			var purposes []int
+			if _, err := asn1.UnmarshalWithParams(field.Bytes, &purposes, "set"); err != nil {
+				return nil, fmt.Errorf("解析purpose失败: %v", err)
+			}
+			for _, p := range purposes {
+				list.purposes[p] = true
+			}
+		case tagAllApplications:
+			list.allApplications = true
+		case tagOrigin:
+			if _, err := asn1.Unmarshal(field.Bytes, &list.origin); err != nil {
+				return nil, fmt.Errorf("解析origin失败: %v", err)
+			}
+			list.hasOrigin = true
+		}
+	}
+	return list, nil
+}
+
+// apple: Apple 匿名证明
+func verifyAppleAttestation(att *attestationObject, credPub crypto.PublicKey, attToBeSigned []byte) (*attestationResult, error) {
+	chain, err := attStmtCertificates(att.AttStmt, true)
+	if err != nil {
+		return nil, err
+	}
+	attCert := chain[0]
+
+	ext := findExtension(attCert, oidAppleNonce)
+	if ext == nil {
+		return nil, fmt.Errorf("证明证书缺少nonce扩展")
+	}
+	var appleExt struct {
+		Nonce []byte `asn1:"tag:1,explicit"`
+	}
+	if _, err := asn1.Unmarshal(ext.Value, &appleExt); err != nil {
+		return nil, fmt.Errorf("解析nonce扩展失败: %v", err)
+	}
+
+	nonce := sha256.Sum256(attToBeSigned)
+	if !bytes.Equal(appleExt.Nonce, nonce[:]) {
+		return nil, fmt.Errorf("nonce不匹配")
+	}
+	if !publicKeysEqual(credPub, attCert.PublicKey) {
+		return nil, fmt.Errorf("证明证书公钥与凭证公钥不一致")
+	}
+
+	return &attestationResult{Type: attestationTypeAnonCA, TrustPath: chain}, nil
+}
+
+// -------------------------- TPM --------------------------
+
+// TPM 常量（TPM 2.0 Library Part 2）
+const (
+	tpmGeneratedValue     = 0xff544347
+	tpmStAttestCertify    = 0x8017
+	tpmAlgRSA             = 0x0001
+	tpmAlgSHA1            = 0x0004
+	tpmAlgSHA256          = 0x000B
+	tpmAlgSHA384          = 0x000C
+	tpmAlgSHA512          = 0x000D
+	tpmAlgNull            = 0x0010
+	tpmAlgECC             = 0x0023
+	tpmECCNistP256        = 0x0003
+	tpmECCNistP384        = 0x0004
+	tpmDefaultRSAExponent = 65537
+)
+
+// tpmPublicArea 解析后的 TPMT_PUBLIC
+type tpmPublicArea struct {
+	Type      uint16
+	NameAlg   uint16
+	RSAModule []byte
+	RSAExp    uint32
+	ECCCurve  uint16
+	ECCX      []byte
+	ECCY      []byte
+}
+
+// tpmReader 按大端序顺序读取TPM结构
+type tpmReader struct {
+	data []byte
+	err  error
+}
+
+func (r *tpmReader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if len(r.data) < n {
+		r.err = fmt.Errorf("TPM结构数据不完整")
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *tpmReader) u16() uint16 {
+	b := r.next(2)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint16(b)
+}
+
+func (r *tpmReader) u32() uint32 {
+	b := r.next(4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+// sized 读取 TPM2B 结构（2字节长度 + 数据）
+func (r *tpmReader) sized() []byte {
+	return r.next(int(r.u16()))
+}
+
+func parseTPMPublicArea(data []byte) (*tpmPublicArea, error) {
+	r := &tpmReader{data: data}
+	pub := &tpmPublicArea{}
+	pub.Type = r.u16()
+	pub.NameAlg = r.u16()
+	r.u32()                    // objectAttributes
+	r.sized()                  // authPolicy
+	if r.u16() != tpmAlgNull { // symmetric
+		return nil, fmt.Errorf("不支持的pubArea symmetric参数")
+	}
+	if scheme := r.u16(); scheme != tpmAlgNull {
+		r.u16() // scheme.details.hashAlg
+	}
+
+	switch pub.Type {
+	case tpmAlgRSA:
+		r.u16() // keyBits
+		pub.RSAExp = r.u32()
+		pub.RSAModule = r.sized()
+	case tpmAlgECC:
+		pub.ECCCurve = r.u16()
+		if kdf := r.u16(); kdf != tpmAlgNull {
+			r.u16() // kdf.details.hashAlg
+		}
+		pub.ECCX = r.sized()
+		pub.ECCY = r.sized()
+	default:
+		return nil, fmt.Errorf("不支持的pubArea类型: %d", pub.Type)
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+	return pub, nil
+}
+
+// tpmNameHash 返回TPM nameAlg对应的摘要算法
+func tpmNameHash(alg uint16) (crypto.Hash, error) {
+	switch alg {
+	case tpmAlgSHA1:
+		return crypto.SHA1, nil
+	case tpmAlgSHA256:
+		return crypto.SHA256, nil
+	case tpmAlgSHA384:
+		return crypto.SHA384, nil
+	case tpmAlgSHA512:
+		return crypto.SHA512, nil
+	}
+	return 0, fmt.Errorf("不支持的TPM摘要算法: %d", alg)
+}
+
+func verifyTPMAttestation(att *attestationObject, cred *attestedCredentialData, credPub crypto.PublicKey, attToBeSigned []byte) (*attestationResult, error) {
+	var ver string
+	var alg int
+	var sig, certInfo, pubAreaBytes []byte
+	if err := attStmtField(att.AttStmt, "ver", &ver, true); err != nil {
+		return nil, err
+	}
+	if ver != "2.0" {
+		return nil, fmt.Errorf("不支持的TPM版本: %s", ver)
+	}
+	if err := attStmtField(att.AttStmt, "alg", &alg, true); err != nil {
+		return nil, err
+	}
+	if err := attStmtField(att.AttStmt, "sig", &sig, true); err != nil {
+		return nil, err
+	}
+	if err := attStmtField(att.AttStmt, "certInfo", &certInfo, true); err != nil {
+		return nil, err
+	}
+	if err := attStmtField(att.AttStmt, "pubArea", &pubAreaBytes, true); err != nil {
+		return nil, err
+	}
+	if _, ok := att.AttStmt["ecdaaKeyId"]; ok {
+		return nil, fmt.Errorf("不支持ECDAA证明")
+	}
+	chain, err := attStmtCertificates(att.AttStmt, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// pubArea 中的公钥必须与凭证公钥一致
+	pubArea, err := parseTPMPublicArea(pubAreaBytes)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkTPMPublicKey(pubArea, credPub); err != nil {
+		return nil, err
+	}
+
+	// 解析 TPMS_ATTEST
+	r := &tpmReader{data: certInfo}
+	magic := r.u32()
+	attType := r.u16()
+	r.sized() // qualifiedSigner
+	extraData := r.sized()
+	r.next(17) // clockInfo
+	r.next(8)  // firmwareVersion
+	attestedName := r.sized()
+	r.sized() // attestedQualifiedName
+	if r.err != nil {
+		return nil, fmt.Errorf("解析certInfo失败: %v", r.err)
+	}
+	if magic != tpmGeneratedValue {
+		return nil, fmt.Errorf("certInfo magic无效")
+	}
+	if attType != tpmStAttestCertify {
+		return nil, fmt.Errorf("certInfo类型无效")
+	}
+
+	// extraData = hash(attToBeSigned)，摘要算法由 alg 决定
+	hash, err := hashForCOSEAlg(alg)
+	if err != nil {
+		return nil, err
+	}
+	h := hash.New()
+	h.Write(attToBeSigned)
+	if !bytes.Equal(extraData, h.Sum(nil)) {
+		return nil, fmt.Errorf("certInfo extraData不匹配")
+	}
+
+	// attested.name = nameAlg || hash(pubArea)
+	if len(attestedName) < 2 {
+		return nil, fmt.Errorf("certInfo attested name无效")
+	}
+	nameHash, err := tpmNameHash(binary.BigEndian.Uint16(attestedName[:2]))
+	if err != nil {
+		return nil, err
+	}
+	nh := nameHash.New()
+	nh.Write(pubAreaBytes)
+	if !bytes.Equal(attestedName[2:], nh.Sum(nil)) {
+		return nil, fmt.Errorf("certInfo attested name与pubArea不匹配")
+	}
+
+	aikCert := chain[0]
+	if err := verifyCOSESignature(alg, aikCert.PublicKey, certInfo, sig); err != nil {
+		return nil, err
+	}
+
+	// AIK 证书要求（§8.3.1）
+	if aikCert.Version != 3 {
+		return nil, fmt.Errorf("AIK证书版本必须为3")
+	}
+	if len(aikCert.Subject.Names) != 0 {
+		return nil, fmt.Errorf("AIK证书主题必须为空")
+	}
+	if findExtension(aikCert, oidSubjectAltName) == nil {
+		return nil, fmt.Errorf("AIK证书缺少SubjectAltName")
+	}
+	if !hasExtKeyUsage(aikCert, oidTCGKpAIKCertificate) {
+		return nil, fmt.Errorf("AIK证书缺少tcg-kp-AIKCertificate用途")
+	}
+	if aikCert.IsCA {
+		return nil, fmt.Errorf("AIK证书不能是CA证书")
+	}
+	if err := checkCertificateAAGUID(aikCert, cred.AAGUID); err != nil {
+		return nil, err
+	}
+
+	// SAN 中只有 directoryName（TPM厂商信息），标准库不会将其标记为已处理
+	for _, cert := range chain {
+		cert.UnhandledCriticalExtensions = removeOID(cert.UnhandledCriticalExtensions, oidSubjectAltName)
+		cert.UnhandledCriticalExtensions = removeOID(cert.UnhandledCriticalExtensions, oidExtKeyUsage)
+	}
+
+	return &attestationResult{Type: attestationTypeBasic, TrustPath: chain}, nil
+}
+
+// checkTPMPublicKey 比较 pubArea 中的公钥与凭证公钥
+func checkTPMPublicKey(pubArea *tpmPublicArea, credPub crypto.PublicKey) error {
+	switch pubArea.Type {
+	case tpmAlgRSA:
+		exp := pubArea.RSAExp
+		if exp == 0 {
+			exp = tpmDefaultRSAExponent
+		}
+		pub := &rsa.PublicKey{N: new(big.Int).SetBytes(pubArea.RSAModule), E: int(exp)}
+		if !publicKeysEqual(credPub, pub) {
+			return fmt.Errorf("pubArea公钥与凭证公钥不一致")
+		}
+	case tpmAlgECC:
+		var curve elliptic.Curve
+		switch pubArea.ECCCurve {
+		case tpmECCNistP256:
+			curve = elliptic.P256()
+		case tpmECCNistP384:
+			curve = elliptic.P384()
+		default:
+			return fmt.Errorf("不支持的pubArea曲线: %d", pubArea.ECCCurve)
+		}
+		pub := &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(pubArea.ECCX),
+			Y:     new(big.Int).SetBytes(pubArea.ECCY),
+		}
+		if !publicKeysEqual(credPub, pub) {
+			return fmt.Errorf("pubArea公钥与凭证公钥不一致")
+		}
+	}
+	return nil
+}
+
+// -------------------------- 证书与信任锚 --------------------------
+
+// attStmtField 读取 attStmt 中的字段
+func attStmtField(stmt map[string]cbor.RawMessage, name string, v interface{}, required bool) error {
+	raw, ok := stmt[name]
+	if !ok {
+		if required {
+			return fmt.Errorf("attStmt缺少%s", name)
+		}
+		return nil
+	}
+	if err := cbor.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("attStmt字段%s解码失败: %v", name, err)
+	}
+	return nil
+}
+
+// attStmtCertificates 解析 x5c 证书链
+func attStmtCertificates(stmt map[string]cbor.RawMessage, required bool) ([]*x509.Certificate, error) {
+	var x5c [][]byte
+	if err := attStmtField(stmt, "x5c", &x5c, required); err != nil {
+		return nil, err
+	}
+	if required && len(x5c) == 0 {
+		return nil, fmt.Errorf("x5c不能为空")
+	}
+
+	chain := make([]*x509.Certificate, 0, len(x5c))
+	for i, der := range x5c {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("解析x5c[%d]失败: %v", i, err)
+		}
+		chain = append(chain, cert)
+	}
+	return chain, nil
+}
+
+// checkCertificateAAGUID 证书中含 id-fido-gen-ce-aaguid 扩展时，必须与 authData 中的 AAGUID 一致
+func checkCertificateAAGUID(cert *x509.Certificate, aaguid []byte) error {
+	ext := findExtension(cert, oidFIDOGenCeAAGUID)
+	if ext == nil {
+		return nil
+	}
+	if ext.Critical {
+		return fmt.Errorf("AAGUID扩展不能是关键扩展")
+	}
+	var value []byte
+	if _, err := asn1.Unmarshal(ext.Value, &value); err != nil {
+		return fmt.Errorf("解析AAGUID扩展失败: %v", err)
+	}
+	if !bytes.Equal(value, aaguid) {
+		return fmt.Errorf("证书AAGUID与认证器AAGUID不一致")
+	}
+	return nil
+}
+
+func findExtension(cert *x509.Certificate, oid asn1.ObjectIdentifier) *pkix.Extension {
+	for i := range cert.Extensions {
+		if cert.Extensions[i].Id.Equal(oid) {
+			return &cert.Extensions[i]
+		}
+	}
+	return nil
+}
+
+func hasExtKeyUsage(cert *x509.Certificate, oid asn1.ObjectIdentifier) bool {
+	for _, usage := range cert.UnknownExtKeyUsage {
+		if usage.Equal(oid) {
+			return true
+		}
+	}
+	return false
+}
+
+func removeOID(list []asn1.ObjectIdentifier, oid asn1.ObjectIdentifier) []asn1.ObjectIdentifier {
+	out := list[:0]
+	for _, item := range list {
+		if !item.Equal(oid) {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// publicKeysEqual 比较两个公钥是否相同
+func publicKeysEqual(a, b crypto.PublicKey) bool {
+	key, ok := a.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && key.Equal(b)
+}
+
+var (
+	trustAnchorsOnce sync.Once
+	trustAnchors     *x509.CertPool
+)
+
+// attestationTrustAnchors 从配置目录加载信任锚（PEM格式的根证书）
+func attestationTrustAnchors() *x509.CertPool {
+	trustAnchorsOnce.Do(func() {
+		trustAnchors = x509.NewCertPool()
+		dir := config.WEBAUTHN_TRUST_ANCHORS_PATH
+		if dir == "" {
+			return
+		}
+
+		files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+		if err != nil {
+			fmt.Printf("Warning: 读取信任锚目录失败 %s: %v\n", dir, err)
+			return
+		}
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				fmt.Printf("Warning: 读取信任锚失败 %s: %v\n", file, err)
+				continue
+			}
+			for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+				cert, err := x509.ParseCertificate(block.Bytes)
+				if err != nil {
+					fmt.Printf("Warning: 解析信任锚失败 %s: %v\n", file, err)
+					continue
+				}
+				trustAnchors.AddCert(cert)
+			}
+		}
+		fmt.Printf("✓ 从 %s 加载了 %d 个证明信任锚文件\n", dir, len(files))
+	})
+	return trustAnchors
+}
+
+// verifyAttestationTrustPath 验证 x5c 证书链是否链接到信任锚
+// 除配置目录中的根证书外，还信任 FIDO 元数据中该型号声明的证明根证书
+func verifyAttestationTrustPath(chain []*x509.Certificate, aaguid string) error {
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	roots := attestationTrustAnchors()
+	if entry := metadataService.Lookup(aaguid); entry != nil {
+		roots = roots.Clone()
+		for _, cert := range entry.RootCertificates() {
+			roots.AddCert(cert)
+		}
+	}
+
+	_, err := chain[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   time.Now(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	return err
+}
@@ -0,0 +1,663 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/cosmos-link/did-login/config"
+	"github.com/fxamacker/cbor/v2"
+)
+
+const attestationTestRPID = "example.com"
+
+var (
+	attestationTestAAGUID      = []byte{0xee, 0x88, 0x28, 0x79, 0x72, 0x1c, 0x49, 0x13, 0x97, 0x75, 0x3d, 0xfc, 0xce, 0x97, 0x07, 0x2a}
+	attestationTestOtherAAGUID = []byte{0x0b, 0xb4, 0x35, 0x45, 0xfd, 0x2c, 0x41, 0x85, 0x87, 0xdd, 0xfe, 0xb0, 0xb2, 0x91, 0x6a, 0xce}
+)
+
+// attestationFixture 一次注册的认证器数据：P-256 凭证密钥、authData 和 clientDataHash
+type attestationFixture struct {
+	credKey        *ecdsa.PrivateKey
+	credID         []byte
+	aaguid         []byte
+	authData       []byte
+	clientDataHash []byte
+}
+
+func newAttestationFixture(t *testing.T, aaguid []byte) *attestationFixture {
+	t.Helper()
+	credKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credID := make([]byte, 32)
+	rand.Read(credID)
+	clientDataHash := sha256.Sum256([]byte(`{"type":"webauthn.create","challenge":"test","origin":"https://example.com"}`))
+	return &attestationFixture{
+		credKey:        credKey,
+		credID:         credID,
+		aaguid:         aaguid,
+		authData:       buildTestAuthData(t, attestationTestRPID, authFlagUserPresent|authFlagUserVerified|authFlagAttestedCredData, aaguid, credID, &credKey.PublicKey),
+		clientDataHash: clientDataHash[:],
+	}
+}
+
+// buildTestAuthData rpIdHash | flags | signCount | aaguid | credentialIdLength | credentialId | COSE_Key
+func buildTestAuthData(t *testing.T, rpID string, flags byte, aaguid, credID []byte, pub *ecdsa.PublicKey) []byte {
+	t.Helper()
+	coseKey, err := cbor.Marshal(map[int]interface{}{
+		1: coseKtyEC2, 3: coseAlgES256, -1: coseCrvP256,
+		-2: pub.X.FillBytes(make([]byte, 32)),
+		-3: pub.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	data := append([]byte{}, rpIDHash[:]...)
+	data = append(data, flags, 0, 0, 0, 0)
+	data = append(data, aaguid...)
+	data = binary.BigEndian.AppendUint16(data, uint16(len(credID)))
+	data = append(data, credID...)
+	return append(data, coseKey...)
+}
+
+// toBeSigned 证明签名的数据: authenticatorData || clientDataHash
+func (f *attestationFixture) toBeSigned() []byte {
+	return append(append([]byte{}, f.authData...), f.clientDataHash...)
+}
+
+// encode CBOR 编码的 attestationObject
+func (f *attestationFixture) encode(t *testing.T, format string, stmt map[string]interface{}) []byte {
+	t.Helper()
+	if stmt == nil {
+		stmt = map[string]interface{}{}
+	}
+	raw, err := cbor.Marshal(map[string]interface{}{"fmt": format, "attStmt": stmt, "authData": f.authData})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+// verify 经 parseAttestationObject 解析后验证证明语句
+func (f *attestationFixture) verify(t *testing.T, format string, stmt map[string]interface{}) (*attestationResult, error) {
+	t.Helper()
+	att, authData, err := parseAttestationObject(f.encode(t, format, stmt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return verifyAttestationStatement(att, authData, f.clientDataHash)
+}
+
+// signTestES256 ES256 签名（ASN.1 DER）
+func signTestES256(t *testing.T, key *ecdsa.PrivateKey, data []byte) []byte {
+	t.Helper()
+	digest := sha256.Sum256(data)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sig
+}
+
+// withTrustAnchors 测试期间只信任给定的根证书，并清空 FIDO 元数据
+func withTrustAnchors(t *testing.T, roots ...*testCert) {
+	t.Helper()
+	attestationTrustAnchors() // 先完成配置目录的加载，之后直接替换结果
+	savedAnchors, savedMetadata := trustAnchors, metadataService
+	t.Cleanup(func() { trustAnchors, metadataService = savedAnchors, savedMetadata })
+
+	trustAnchors = x509.NewCertPool()
+	for _, root := range roots {
+		trustAnchors.AddCert(root.cert)
+	}
+	metadataService = &metadataStore{}
+}
+
+// testAttestationPKI 证明根证书 -> 中间证书
+func testAttestationPKI(t *testing.T) (root, intermediate *testCert) {
+	root = newTestCert(t, testCertTemplate("Attestation Test Root", true), nil)
+	intermediate = newTestCert(t, testCertTemplate("Attestation Test CA", true), root)
+	return root, intermediate
+}
+
+// packedCertTemplate 符合 §8.2.1 的 packed 证明证书模板，aaguid 非空时写入 id-fido-gen-ce-aaguid 扩展
+func packedCertTemplate(t *testing.T, aaguid []byte) *x509.Certificate {
+	t.Helper()
+	template := testCertTemplate("Test Authenticator", false)
+	template.Subject.OrganizationalUnit = []string{"Authenticator Attestation"}
+	if aaguid != nil {
+		value, err := asn1.Marshal(aaguid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		template.ExtraExtensions = []pkix.Extension{{Id: oidFIDOGenCeAAGUID, Value: value}}
+	}
+	return template
+}
+
+func TestNoneAttestation(t *testing.T) {
+	withTrustAnchors(t)
+	f := newAttestationFixture(t, make([]byte, 16))
+
+	result, err := f.verify(t, "none", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Type != attestationTypeNone || result.Trusted {
+		t.Fatalf("结果错误: %+v", result)
+	}
+	if err := checkAttestationPolicy(result, attestationPolicyNone); err != nil {
+		t.Fatalf("none 策略拒绝了无证明: %v", err)
+	}
+	for _, policy := range []string{attestationPolicySelf, attestationPolicyTrusted} {
+		if err := checkAttestationPolicy(result, policy); err == nil {
+			t.Errorf("%s 策略接受了无证明", policy)
+		}
+	}
+
+	if _, err := f.verify(t, "none", map[string]interface{}{"sig": []byte{1}}); err == nil {
+		t.Error("attStmt 非空的 none 证明被接受")
+	}
+	if _, err := f.verify(t, "unknown-fmt", nil); err == nil {
+		t.Error("不支持的证明格式被接受")
+	}
+}
+
+func TestPackedAttestation(t *testing.T) {
+	root, intermediate := testAttestationPKI(t)
+	f := newAttestationFixture(t, attestationTestAAGUID)
+	attCert := newTestCert(t, packedCertTemplate(t, attestationTestAAGUID), intermediate)
+	stmt := func(cert *testCert) map[string]interface{} {
+		return map[string]interface{}{
+			"alg": coseAlgES256,
+			"sig": signTestES256(t, cert.key.(*ecdsa.PrivateKey), f.toBeSigned()),
+			"x5c": [][]byte{cert.cert.Raw, intermediate.cert.Raw},
+		}
+	}
+
+	t.Run("trusted chain", func(t *testing.T) {
+		withTrustAnchors(t, root)
+		result, err := f.verify(t, "packed", stmt(attCert))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Type != attestationTypeBasic || !result.Trusted || len(result.TrustPath) != 2 {
+			t.Fatalf("结果错误: %+v", result)
+		}
+		if err := checkAttestationPolicy(result, attestationPolicyTrusted); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("chain not anchored", func(t *testing.T) {
+		otherRoot := newTestCert(t, testCertTemplate("Other Root", true), nil)
+		withTrustAnchors(t, otherRoot)
+		result, err := f.verify(t, "packed", stmt(attCert))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Trusted {
+			t.Fatal("未链接到信任锚的证书链被标记为可信")
+		}
+		if err := checkAttestationPolicy(result, attestationPolicyTrusted); err == nil {
+			t.Fatal("trusted 策略接受了未链接到信任锚的证书链")
+		}
+		if err := checkAttestationPolicy(result, attestationPolicySelf); err != nil {
+			t.Fatalf("self 策略拒绝了证书链证明: %v", err)
+		}
+	})
+
+	t.Run("anchored by metadata root", func(t *testing.T) {
+		withTrustAnchors(t)
+		metadataService = &metadataStore{entries: map[string]*metadataEntry{
+			formatAAGUID(attestationTestAAGUID): {
+				AAGUID: formatAAGUID(attestationTestAAGUID),
+				MetadataStatement: metadataStatement{
+					AttestationRootCertificates: []string{base64.StdEncoding.EncodeToString(root.cert.Raw)},
+				},
+			},
+		}}
+		result, err := f.verify(t, "packed", stmt(attCert))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.Trusted {
+			t.Fatal("元数据声明的根证书未作为信任锚")
+		}
+	})
+
+	t.Run("aaguid mismatch", func(t *testing.T) {
+		withTrustAnchors(t, root)
+		mismatched := newTestCert(t, packedCertTemplate(t, attestationTestOtherAAGUID), intermediate)
+		if _, err := f.verify(t, "packed", stmt(mismatched)); err == nil || !strings.Contains(err.Error(), "AAGUID") {
+			t.Fatalf("证书 AAGUID 与 authData 不一致时 err = %v", err)
+		}
+	})
+
+	t.Run("invalid certificate subject", func(t *testing.T) {
+		withTrustAnchors(t, root)
+		template := packedCertTemplate(t, attestationTestAAGUID)
+		template.Subject.OrganizationalUnit = []string{"Other"}
+		if _, err := f.verify(t, "packed", stmt(newTestCert(t, template, intermediate))); err == nil {
+			t.Fatal("OU 不是 Authenticator Attestation 的证书被接受")
+		}
+	})
+
+	t.Run("tampered signature", func(t *testing.T) {
+		withTrustAnchors(t, root)
+		s := stmt(attCert)
+		s["sig"] = signTestES256(t, attCert.key.(*ecdsa.PrivateKey), []byte("other data"))
+		if _, err := f.verify(t, "packed", s); err == nil {
+			t.Fatal("签名不匹配的证明被接受")
+		}
+	})
+
+	t.Run("self attestation", func(t *testing.T) {
+		withTrustAnchors(t, root)
+		self := map[string]interface{}{"alg": coseAlgES256, "sig": signTestES256(t, f.credKey, f.toBeSigned())}
+		result, err := f.verify(t, "packed", self)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Type != attestationTypeSelf || result.Trusted {
+			t.Fatalf("结果错误: %+v", result)
+		}
+		if err := checkAttestationPolicy(result, attestationPolicySelf); err != nil {
+			t.Fatalf("self 策略拒绝了自签名证明: %v", err)
+		}
+		if err := checkAttestationPolicy(result, attestationPolicyTrusted); err == nil {
+			t.Fatal("trusted 策略接受了自签名证明")
+		}
+
+		self["alg"] = coseAlgRS256
+		if _, err := f.verify(t, "packed", self); err == nil {
+			t.Fatal("算法与凭证公钥不一致的自签名证明被接受")
+		}
+	})
+}
+
+func TestFIDOU2FAttestation(t *testing.T) {
+	root, intermediate := testAttestationPKI(t)
+	f := newAttestationFixture(t, make([]byte, 16))
+	attCert := newTestCert(t, testCertTemplate("U2F Test Key", false), intermediate)
+
+	publicKeyU2F := elliptic.Marshal(elliptic.P256(), f.credKey.X, f.credKey.Y)
+	rpIDHash := sha256.Sum256([]byte(attestationTestRPID))
+	verificationData := append([]byte{0x00}, rpIDHash[:]...)
+	verificationData = append(verificationData, f.clientDataHash...)
+	verificationData = append(verificationData, f.credID...)
+	verificationData = append(verificationData, publicKeyU2F...)
+
+	withTrustAnchors(t, root)
+	stmt := map[string]interface{}{
+		"sig": signTestES256(t, attCert.key.(*ecdsa.PrivateKey), verificationData),
+		"x5c": [][]byte{attCert.cert.Raw},
+	}
+	result, err := f.verify(t, "fido-u2f", stmt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Type != attestationTypeBasic || result.Trusted {
+		// x5c 只有证明证书，中间证书缺失时无法链接到根证书
+		t.Fatalf("结果错误: %+v", result)
+	}
+
+	// 证明证书由根证书直接签发时可信
+	direct := newTestCert(t, testCertTemplate("U2F Direct", false), root)
+	result, err = f.verify(t, "fido-u2f", map[string]interface{}{
+		"sig": signTestES256(t, direct.key.(*ecdsa.PrivateKey), verificationData),
+		"x5c": [][]byte{direct.cert.Raw},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Trusted {
+		t.Fatal("根证书签发的 U2F 证明证书未被信任")
+	}
+
+	tampered := map[string]interface{}{
+		"sig": signTestES256(t, attCert.key.(*ecdsa.PrivateKey), append(verificationData, 0)),
+		"x5c": stmt["x5c"],
+	}
+	if _, err := f.verify(t, "fido-u2f", tampered); err == nil {
+		t.Error("签名不匹配的 U2F 证明被接受")
+	}
+	if _, err := f.verify(t, "fido-u2f", map[string]interface{}{"sig": stmt["sig"], "x5c": [][]byte{attCert.cert.Raw, intermediate.cert.Raw}}); err == nil {
+		t.Error("x5c 包含多个证书的 U2F 证明被接受")
+	}
+
+	p384Key, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	p384Cert := newTestCertWithKey(t, testCertTemplate("U2F P-384", false), intermediate, p384Key)
+	if _, err := f.verify(t, "fido-u2f", map[string]interface{}{"sig": stmt["sig"], "x5c": [][]byte{p384Cert.cert.Raw}}); err == nil {
+		t.Error("P-384 证明证书被接受")
+	}
+}
+
+// tpmTestPubArea P-256 凭证公钥的 TPMT_PUBLIC
+func tpmTestPubArea(pub *ecdsa.PublicKey) []byte {
+	b := binary.BigEndian.AppendUint16(nil, tpmAlgECC)
+	b = binary.BigEndian.AppendUint16(b, tpmAlgSHA256) // nameAlg
+	b = binary.BigEndian.AppendUint32(b, 0x00060472)   // objectAttributes
+	b = binary.BigEndian.AppendUint16(b, 0)            // authPolicy
+	b = binary.BigEndian.AppendUint16(b, tpmAlgNull)   // symmetric
+	b = binary.BigEndian.AppendUint16(b, tpmAlgNull)   // scheme
+	b = binary.BigEndian.AppendUint16(b, tpmECCNistP256)
+	b = binary.BigEndian.AppendUint16(b, tpmAlgNull) // kdf
+	b = binary.BigEndian.AppendUint16(b, 32)
+	b = append(b, pub.X.FillBytes(make([]byte, 32))...)
+	b = binary.BigEndian.AppendUint16(b, 32)
+	return append(b, pub.Y.FillBytes(make([]byte, 32))...)
+}
+
+// tpmTestCertInfo TPMS_ATTEST（TPM_ST_ATTEST_CERTIFY）
+func tpmTestCertInfo(extraData, pubArea []byte) []byte {
+	name := sha256.Sum256(pubArea)
+	b := binary.BigEndian.AppendUint32(nil, tpmGeneratedValue)
+	b = binary.BigEndian.AppendUint16(b, tpmStAttestCertify)
+	b = binary.BigEndian.AppendUint16(b, 0) // qualifiedSigner
+	b = binary.BigEndian.AppendUint16(b, uint16(len(extraData)))
+	b = append(b, extraData...)
+	b = append(b, make([]byte, 17)...) // clockInfo
+	b = append(b, make([]byte, 8)...)  // firmwareVersion
+	b = binary.BigEndian.AppendUint16(b, uint16(2+len(name)))
+	b = binary.BigEndian.AppendUint16(b, tpmAlgSHA256)
+	b = append(b, name[:]...)
+	return binary.BigEndian.AppendUint16(b, 0) // attestedQualifiedName
+}
+
+// aikCertTemplate 符合 §8.3.1 的 AIK 证书模板：主题为空，SAN 为 TPM 厂商信息，EKU 为 tcg-kp-AIKCertificate
+func aikCertTemplate(t *testing.T, aaguid []byte) *x509.Certificate {
+	t.Helper()
+	template := testCertTemplate("", false)
+	template.Subject = pkix.Name{}
+	template.UnknownExtKeyUsage = []asn1.ObjectIdentifier{oidTCGKpAIKCertificate}
+
+	tpmManufacturer := pkix.Name{ExtraNames: []pkix.AttributeTypeAndValue{
+		{Type: asn1.ObjectIdentifier{2, 23, 133, 2, 1}, Value: "id:FFFFF1D0"},
+		{Type: asn1.ObjectIdentifier{2, 23, 133, 2, 2}, Value: "TEST TPM"},
+		{Type: asn1.ObjectIdentifier{2, 23, 133, 2, 3}, Value: "id:00010000"},
+	}}
+	directoryName, err := asn1.Marshal(tpmManufacturer.ToRDNSequence())
+	if err != nil {
+		t.Fatal(err)
+	}
+	san, err := asn1.Marshal([]asn1.RawValue{{Class: asn1.ClassContextSpecific, Tag: 4, IsCompound: true, Bytes: directoryName}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.ExtraExtensions = []pkix.Extension{{Id: oidSubjectAltName, Critical: true, Value: san}}
+	if aaguid != nil {
+		value, _ := asn1.Marshal(aaguid)
+		template.ExtraExtensions = append(template.ExtraExtensions, pkix.Extension{Id: oidFIDOGenCeAAGUID, Value: value})
+	}
+	return template
+}
+
+func TestTPMAttestation(t *testing.T) {
+	root, intermediate := testAttestationPKI(t)
+	f := newAttestationFixture(t, attestationTestAAGUID)
+	aik := newTestCert(t, aikCertTemplate(t, attestationTestAAGUID), intermediate)
+	pubArea := tpmTestPubArea(&f.credKey.PublicKey)
+	extraData := sha256.Sum256(f.toBeSigned())
+
+	stmt := func(aik *testCert, certInfo, pubArea []byte) map[string]interface{} {
+		return map[string]interface{}{
+			"ver":      "2.0",
+			"alg":      coseAlgES256,
+			"sig":      signTestES256(t, aik.key.(*ecdsa.PrivateKey), certInfo),
+			"x5c":      [][]byte{aik.cert.Raw, intermediate.cert.Raw},
+			"certInfo": certInfo,
+			"pubArea":  pubArea,
+		}
+	}
+
+	withTrustAnchors(t, root)
+	result, err := f.verify(t, "tpm", stmt(aik, tpmTestCertInfo(extraData[:], pubArea), pubArea))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Type != attestationTypeBasic || !result.Trusted {
+		t.Fatalf("结果错误: %+v", result)
+	}
+
+	otherKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	otherPubArea := tpmTestPubArea(&otherKey.PublicKey)
+	wrongExtraData := sha256.Sum256([]byte("other"))
+	subjectTemplate := aikCertTemplate(t, attestationTestAAGUID)
+	subjectTemplate.Subject = pkix.Name{CommonName: "AIK"}
+	noEKUTemplate := aikCertTemplate(t, attestationTestAAGUID)
+	noEKUTemplate.UnknownExtKeyUsage = nil
+
+	tests := []struct {
+		name string
+		stmt map[string]interface{}
+	}{
+		{"extraData mismatch", stmt(aik, tpmTestCertInfo(wrongExtraData[:], pubArea), pubArea)},
+		{"pubArea is another key", stmt(aik, tpmTestCertInfo(extraData[:], otherPubArea), otherPubArea)},
+		{"attested name mismatch", stmt(aik, tpmTestCertInfo(extraData[:], otherPubArea), pubArea)},
+		{"aik with subject", stmt(newTestCert(t, subjectTemplate, intermediate), tpmTestCertInfo(extraData[:], pubArea), pubArea)},
+		{"aik without tcg-kp-AIKCertificate", stmt(newTestCert(t, noEKUTemplate, intermediate), tpmTestCertInfo(extraData[:], pubArea), pubArea)},
+		{"aaguid mismatch", stmt(newTestCert(t, aikCertTemplate(t, attestationTestOtherAAGUID), intermediate), tpmTestCertInfo(extraData[:], pubArea), pubArea)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := f.verify(t, "tpm", tt.stmt); err == nil {
+				t.Fatal("无效的 TPM 证明被接受")
+			}
+		})
+	}
+
+	t.Run("unsupported version", func(t *testing.T) {
+		s := stmt(aik, tpmTestCertInfo(extraData[:], pubArea), pubArea)
+		s["ver"] = "1.2"
+		if _, err := f.verify(t, "tpm", s); err == nil {
+			t.Fatal("TPM 1.2 证明被接受")
+		}
+	})
+}
+
+// androidAuthorizationListForTest 编码 AuthorizationList（SEQUENCE），只包含 purpose、allApplications 和 origin
+func androidAuthorizationListForTest(t *testing.T, purposes []int, origin int, allApplications bool) asn1.RawValue {
+	t.Helper()
+	var content []byte
+	if purposes != nil {
+		set, err := asn1.MarshalWithParams(purposes, "set")
+		if err != nil {
+			t.Fatal(err)
+		}
+		field, _ := asn1.Marshal(asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 1, IsCompound: true, Bytes: set})
+		content = append(content, field...)
+	}
+	if allApplications {
+		null, _ := asn1.Marshal(asn1.NullRawValue)
+		field, _ := asn1.Marshal(asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 600, IsCompound: true, Bytes: null})
+		content = append(content, field...)
+	}
+	if origin >= 0 {
+		value, _ := asn1.Marshal(origin)
+		field, _ := asn1.Marshal(asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 702, IsCompound: true, Bytes: value})
+		content = append(content, field...)
+	}
+	return asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSequence, IsCompound: true, Bytes: content}
+}
+
+func TestAndroidKeyAttestation(t *testing.T) {
+	root, intermediate := testAttestationPKI(t)
+	f := newAttestationFixture(t, attestationTestAAGUID)
+
+	// Android 证明证书的公钥就是凭证公钥
+	attestCert := func(challenge []byte, tee asn1.RawValue, key *ecdsa.PrivateKey) *testCert {
+		desc, err := asn1.Marshal(struct {
+			AttestationVersion       int
+			AttestationSecurityLevel asn1.Enumerated
+			KeymasterVersion         int
+			KeymasterSecurityLevel   asn1.Enumerated
+			AttestationChallenge     []byte
+			UniqueID                 []byte
+			SoftwareEnforced         asn1.RawValue
+			TeeEnforced              asn1.RawValue
+		}{3, 1, 4, 1, challenge, []byte{}, androidAuthorizationListForTest(t, nil, -1, false), tee})
+		if err != nil {
+			t.Fatal(err)
+		}
+		template := testCertTemplate("Android Keystore Key", false)
+		template.ExtraExtensions = []pkix.Extension{{Id: oidAndroidKeyAttest, Value: desc}}
+		return newTestCertWithKey(t, template, intermediate, key)
+	}
+	stmt := func(cert *testCert) map[string]interface{} {
+		return map[string]interface{}{
+			"alg": coseAlgES256,
+			"sig": signTestES256(t, f.credKey, f.toBeSigned()),
+			"x5c": [][]byte{cert.cert.Raw, intermediate.cert.Raw},
+		}
+	}
+	generatedForSigning := androidAuthorizationListForTest(t, []int{2}, 0, false)
+
+	withTrustAnchors(t, root)
+	result, err := f.verify(t, "android-key", stmt(attestCert(f.clientDataHash, generatedForSigning, f.credKey)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Type != attestationTypeBasic || !result.Trusted {
+		t.Fatalf("结果错误: %+v", result)
+	}
+
+	otherKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tests := []struct {
+		name string
+		cert *testCert
+	}{
+		{"challenge mismatch", attestCert(make([]byte, 32), generatedForSigning, f.credKey)},
+		{"certificate key differs from credential", attestCert(f.clientDataHash, generatedForSigning, otherKey)},
+		{"all applications", attestCert(f.clientDataHash, androidAuthorizationListForTest(t, []int{2}, 0, true), f.credKey)},
+		{"imported key", attestCert(f.clientDataHash, androidAuthorizationListForTest(t, []int{2}, 2, false), f.credKey)},
+		{"no sign purpose", attestCert(f.clientDataHash, androidAuthorizationListForTest(t, []int{0, 1}, 0, false), f.credKey)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := f.verify(t, "android-key", stmt(tt.cert)); err == nil {
+				t.Fatal("无效的 Android 证明被接受")
+			}
+		})
+	}
+}
+
+func TestAppleAttestation(t *testing.T) {
+	root, intermediate := testAttestationPKI(t)
+	f := newAttestationFixture(t, make([]byte, 16))
+
+	attestCert := func(nonce []byte, key *ecdsa.PrivateKey) *testCert {
+		ext, err := asn1.Marshal(struct {
+			Nonce []byte `asn1:"tag:1,explicit"`
+		}{nonce})
+		if err != nil {
+			t.Fatal(err)
+		}
+		template := testCertTemplate("Apple Test Credential", false)
+		template.ExtraExtensions = []pkix.Extension{{Id: oidAppleNonce, Value: ext}}
+		return newTestCertWithKey(t, template, intermediate, key)
+	}
+	stmt := func(cert *testCert) map[string]interface{} {
+		return map[string]interface{}{"x5c": [][]byte{cert.cert.Raw, intermediate.cert.Raw}}
+	}
+	nonce := sha256.Sum256(f.toBeSigned())
+
+	withTrustAnchors(t, root)
+	result, err := f.verify(t, "apple", stmt(attestCert(nonce[:], f.credKey)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Type != attestationTypeAnonCA || !result.Trusted {
+		t.Fatalf("结果错误: %+v", result)
+	}
+
+	otherKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if _, err := f.verify(t, "apple", stmt(attestCert(make([]byte, 32), f.credKey))); err == nil {
+		t.Error("nonce 不匹配的 Apple 证明被接受")
+	}
+	if _, err := f.verify(t, "apple", stmt(attestCert(nonce[:], otherKey))); err == nil {
+		t.Error("证书公钥与凭证公钥不一致的 Apple 证明被接受")
+	}
+	if _, err := f.verify(t, "apple", map[string]interface{}{}); err == nil {
+		t.Error("缺少 x5c 的 Apple 证明被接受")
+	}
+}
+
+// 完整注册流程：政府用户只接受链接到信任锚的证明
+func TestVerifyRegistrationAttestationPolicy(t *testing.T) {
+	savedOrigins, savedRPID, savedAlgorithms := config.WEBAUTHN_ALLOWED_ORIGINS, config.WEBAUTHN_RP_ID, config.WEBAUTHN_ALGORITHMS
+	savedPolicy := config.WEBAUTHN_ATTESTATION_POLICY
+	t.Cleanup(func() {
+		config.WEBAUTHN_ALLOWED_ORIGINS, config.WEBAUTHN_RP_ID, config.WEBAUTHN_ALGORITHMS = savedOrigins, savedRPID, savedAlgorithms
+		config.WEBAUTHN_ATTESTATION_POLICY = savedPolicy
+	})
+	config.WEBAUTHN_ALLOWED_ORIGINS = []string{"https://" + attestationTestRPID}
+	config.WEBAUTHN_RP_ID = attestationTestRPID
+	config.WEBAUTHN_ALGORITHMS = []string{"ES256"}
+	config.WEBAUTHN_ATTESTATION_POLICY = attestationPolicyNone
+
+	root, intermediate := testAttestationPKI(t)
+	withTrustAnchors(t, root)
+
+	const challenge = "registration-challenge"
+	clientDataJSON, _ := json.Marshal(map[string]interface{}{
+		"type": "webauthn.create", "challenge": challenge, "origin": "https://" + attestationTestRPID,
+	})
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	f := newAttestationFixture(t, attestationTestAAGUID)
+	f.clientDataHash = clientDataHash[:]
+	encodedClientData := base64.RawURLEncoding.EncodeToString(clientDataJSON)
+
+	register := func(attestation []byte, policy userTypePolicy) error {
+		_, _, err := verifyRegistration(encodedClientData, base64.RawURLEncoding.EncodeToString(attestation), challenge, policy)
+		return err
+	}
+
+	none := f.encode(t, "none", nil)
+	if err := register(none, defaultUserTypePolicy); err != nil {
+		t.Fatalf("默认策略拒绝了 none 证明: %v", err)
+	}
+	for _, userType := range []string{"政府", "机构"} {
+		if err := register(none, policyForUserType(userType)); err == nil {
+			t.Errorf("%s用户接受了 none 证明", userType)
+		}
+	}
+
+	self := f.encode(t, "packed", map[string]interface{}{"alg": coseAlgES256, "sig": signTestES256(t, f.credKey, f.toBeSigned())})
+	if err := register(self, policyForUserType("政府")); err == nil {
+		t.Error("政府用户接受了自签名证明")
+	}
+
+	attCert := newTestCert(t, packedCertTemplate(t, attestationTestAAGUID), intermediate)
+	packed := f.encode(t, "packed", map[string]interface{}{
+		"alg": coseAlgES256,
+		"sig": signTestES256(t, attCert.key.(*ecdsa.PrivateKey), f.toBeSigned()),
+		"x5c": [][]byte{attCert.cert.Raw, intermediate.cert.Raw},
+	})
+	if err := register(packed, policyForUserType("政府")); err != nil {
+		t.Errorf("政府用户拒绝了可信的 packed 证明: %v", err)
+	}
+
+	_, untrustedCA := testAttestationPKI(t)
+	untrustedCert := newTestCert(t, packedCertTemplate(t, attestationTestAAGUID), untrustedCA)
+	untrusted := f.encode(t, "packed", map[string]interface{}{
+		"alg": coseAlgES256,
+		"sig": signTestES256(t, untrustedCert.key.(*ecdsa.PrivateKey), f.toBeSigned()),
+		"x5c": [][]byte{untrustedCert.cert.Raw, untrustedCA.cert.Raw},
+	})
+	if err := register(untrusted, policyForUserType("政府")); err == nil {
+		t.Error("政府用户接受了未链接到信任锚的证明")
+	}
+}
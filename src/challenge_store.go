@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ceremonyType 挑战用途，不同用途的挑战互不通用
+type ceremonyType string
+
+const (
+	ceremonyCreate        ceremonyType = "webauthn.create"    // 注册
+	ceremonyGet           ceremonyType = "webauthn.get"       // 登录
+	ceremonyResetPassword ceremonyType = "did.reset-password" // DID 签名重置密码
+	ceremonySIWE          ceremonyType = "siwe"               // Sign-In with Ethereum，按 nonce 保存
+	ceremonyPresentation  ceremonyType = "openid4vp"          // 可验证展示登录，按 nonce 保存
+)
+
+// 挑战有效期（略长于前端 60 秒超时）
+const challengeTTL = 2 * time.Minute
+
+// 内存存储中每种用途最多保留的挑战数
+const maxChallengesPerCeremony = 10000
+
+var errChallengeNotFound = errors.New("挑战不存在或已过期")
+
+// challengeEntry 一次挑战的内容
+type challengeEntry struct {
+	Challenge string `json:"challenge"`
+	Subject   string `json:"subject"` // 发起挑战的用户（邮箱），无用户名登录为空
+	// 未登录发起的注册挑战（为尚无通行密钥的新账户注册），完成时不要求登录令牌
+	NewAccount bool `json:"new_account,omitempty"`
+}
+
+// ChallengeStore 挑战存储：挑战按用途和会话保存，只能被取出一次
+type ChallengeStore interface {
+	Save(ctx context.Context, ceremony ceremonyType, sessionID string, entry challengeEntry, ttl time.Duration) error
+	Consume(ctx context.Context, ceremony ceremonyType, sessionID string) (*challengeEntry, error)
+}
+
+// 全局挑战存储
+var challengeStore ChallengeStore
+
+// initChallengeStore 配置了 REDIS_HOST 时使用 Redis，否则（或连接失败时）使用内存存储
+func initChallengeStore() {
+	redisHost := os.Getenv("REDIS_HOST")
+	if redisHost == "" {
+		fmt.Println("REDIS_HOST 未设置，使用内存挑战存储")
+		challengeStore = newMemoryChallengeStore(maxChallengesPerCeremony)
+		return
+	}
+
+	redisPort := os.Getenv("REDIS_PORT")
+	if redisPort == "" {
+		redisPort = "6379"
+	}
+	redisDB, _ := strconv.Atoi(os.Getenv("REDIS_DB"))
+
+	client := redis.NewClient(&redis.Options{
+		Addr:     redisHost + ":" + redisPort,
+		Password: os.Getenv("REDIS_PASSWORD"),
+		DB:       redisDB,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		fmt.Printf("Warning: 无法连接 Redis %s:%s (%v)，使用内存挑战存储\n", redisHost, redisPort, err)
+		client.Close()
+		challengeStore = newMemoryChallengeStore(maxChallengesPerCeremony)
+		return
+	}
+
+	fmt.Printf("✅ 使用 Redis 挑战存储: %s:%s\n", redisHost, redisPort)
+	challengeStore = newRedisChallengeStore(client)
+}
+
+// -------------------------- 内存实现 --------------------------
+
+type memoryChallenge struct {
+	entry     challengeEntry
+	expiresAt time.Time
+}
+
+// memoryChallengeStore 单实例部署使用的内存存储
+type memoryChallengeStore struct {
+	mu      sync.Mutex
+	items   map[ceremonyType]map[string]memoryChallenge
+	maxSize int
+}
+
+func newMemoryChallengeStore(maxSize int) *memoryChallengeStore {
+	store := &memoryChallengeStore{
+		items:   make(map[ceremonyType]map[string]memoryChallenge),
+		maxSize: maxSize,
+	}
+
+	// 定期清理过期挑战
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for range ticker.C {
+			store.sweep()
+		}
+	}()
+
+	return store
+}
+
+func (s *memoryChallengeStore) Save(ctx context.Context, ceremony ceremonyType, sessionID string, entry challengeEntry, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bucket, ok := s.items[ceremony]
+	if !ok {
+		bucket = make(map[string]memoryChallenge)
+		s.items[ceremony] = bucket
+	}
+
+	if _, exists := bucket[sessionID]; !exists && len(bucket) >= s.maxSize {
+		s.sweepLocked(time.Now())
+		if len(bucket) >= s.maxSize {
+			s.evictOldestLocked(bucket)
+		}
+	}
+
+	bucket[sessionID] = memoryChallenge{entry: entry, expiresAt: time.Now().Add(ttl)}
+	return nil
+}
+
+func (s *memoryChallengeStore) Consume(ctx context.Context, ceremony ceremonyType, sessionID string) (*challengeEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.items[ceremony][sessionID]
+	if !ok {
+		return nil, errChallengeNotFound
+	}
+	delete(s.items[ceremony], sessionID)
+
+	if time.Now().After(item.expiresAt) {
+		return nil, errChallengeNotFound
+	}
+	return &item.entry, nil
+}
+
+func (s *memoryChallengeStore) sweep() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweepLocked(time.Now())
+}
+
+func (s *memoryChallengeStore) sweepLocked(now time.Time) {
+	for _, bucket := range s.items {
+		for id, item := range bucket {
+			if now.After(item.expiresAt) {
+				delete(bucket, id)
+			}
+		}
+	}
+}
+
+// evictOldestLocked 达到上限时淘汰最早过期的挑战
+func (s *memoryChallengeStore) evictOldestLocked(bucket map[string]memoryChallenge) {
+	var oldestID string
+	var oldest time.Time
+	for id, item := range bucket {
+		if oldestID == "" || item.expiresAt.Before(oldest) {
+			oldestID = id
+			oldest = item.expiresAt
+		}
+	}
+	delete(bucket, oldestID)
+}
+
+// -------------------------- Redis 实现 --------------------------
+
+// redisChallengeStore 多副本部署共享的 Redis 存储，过期由 Redis 负责
+type redisChallengeStore struct {
+	client *redis.Client
+}
+
+func newRedisChallengeStore(client *redis.Client) *redisChallengeStore {
+	return &redisChallengeStore{client: client}
+}
+
+func (s *redisChallengeStore) key(ceremony ceremonyType, sessionID string) string {
+	return fmt.Sprintf("did-login:challenge:%s:%s", ceremony, sessionID)
+}
+
+func (s *redisChallengeStore) Save(ctx context.Context, ceremony ceremonyType, sessionID string, entry challengeEntry, ttl time.Duration) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, s.key(ceremony, sessionID), data, ttl).Err()
+}
+
+func (s *redisChallengeStore) Consume(ctx context.Context, ceremony ceremonyType, sessionID string) (*challengeEntry, error) {
+	// GETDEL 保证同一挑战只能被取出一次
+	data, err := s.client.GetDel(ctx, s.key(ceremony, sessionID)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, errChallengeNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entry challengeEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
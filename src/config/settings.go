@@ -0,0 +1,319 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// 全局配置解析器实例
+// 注意：包级变量先于 init() 初始化，下方的 APP_* 等变量依赖配置文件，
+// 因此配置必须在变量初始化阶段加载，而不能放在 init() 中
+var config = loadConfig()
+
+// 加载配置：程序启动时读取配置文件（环境变量在 GetConfig 中优先读取）
+func loadConfig() map[string]map[string]string {
+	cfg := make(map[string]map[string]string)
+
+	// 获取配置文件路径（与Python逻辑一致：当前文件目录下的config.ini）
+	configFile, err := getConfigFilePath()
+	if err != nil {
+		fmt.Printf("警告：获取配置文件路径失败，仅使用环境变量和默认值: %v\n", err)
+		return cfg
+	}
+
+	// 读取并解析配置文件
+	err = parseIniFile(configFile, cfg)
+	if err != nil {
+		fmt.Printf("警告：配置文件解析失败，仅使用环境变量和默认值: %v\n", err)
+	}
+	return cfg
+}
+
+// 获取配置文件路径（兼容不同运行环境）
+func getConfigFilePath() (string, error) {
+	// 获取当前文件所在目录
+	execPath, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	execDir := filepath.Dir(execPath)
+
+	// 优先查找当前文件目录下的config.ini
+	configPath := filepath.Join(execDir, "config.ini")
+	if _, err := os.Stat(configPath); err == nil {
+		return configPath, nil
+	}
+
+	// 备用：当前工作目录下的config/config.ini（与Python的Path(__file__).parent逻辑对齐）
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	altConfigPath := filepath.Join(wd, "config", "config.ini")
+	if _, err := os.Stat(altConfigPath); err == nil {
+		return altConfigPath, nil
+	}
+
+	return "", fmt.Errorf("配置文件未找到（已尝试：%s, %s）", configPath, altConfigPath)
+}
+
+// 解析INI格式配置文件
+func parseIniFile(filePath string, config map[string]map[string]string) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	currentSection := ""
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		// 跳过空行和注释
+		if line == "" || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// 匹配节（如 [app]）
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			currentSection = strings.TrimSpace(line[1 : len(line)-1])
+			if _, exists := config[currentSection]; !exists {
+				config[currentSection] = make(map[string]string)
+			}
+			continue
+		}
+
+		// 匹配键值对（如 port = 50100）
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue // 跳过无效行
+		}
+
+		key := strings.TrimSpace(parts[0])
+		value := strings.TrimSpace(parts[1])
+		// 移除值两侧的引号（兼容带引号的配置）
+		value = strings.Trim(value, "\"'")
+
+		if currentSection != "" {
+			config[currentSection][key] = value
+		}
+	}
+
+	return scanner.Err()
+}
+
+// GetConfig 统一读取配置：优先环境变量 → 配置文件 → 默认值
+// 环境变量名格式：APP_{SECTION}_{KEY}（全大写）
+func GetConfig(section, key string, defaultValue interface{}) interface{} {
+	// 1. 优先读取环境变量
+	envKey := fmt.Sprintf("APP_%s_%s", strings.ToUpper(section), strings.ToUpper(key))
+	if envValue, exists := os.LookupEnv(envKey); exists {
+		return envValue
+	}
+
+	// 2. 读取配置文件
+	sectionMap, sectionExists := config[section]
+	if sectionExists {
+		if value, keyExists := sectionMap[key]; keyExists {
+			return value
+		}
+	}
+
+	// 3. 返回默认值
+	return defaultValue
+}
+
+// -------------------------- 封装常用配置（直接导入使用） --------------------------
+
+// 字符串类型配置
+var (
+	APP_NAME              = GetConfig("app", "name", "flask-echo").(string)
+	APP_HOST              = GetConfig("server", "host", "0.0.0.0").(string)
+	APP_LOG_PATH          = GetConfig("server", "log_path", "/app/log").(string)
+	CONTAINER_LOG_PATH    = GetConfig("server", "container_log_path", "/var/log").(string)
+	DOCKER_IMAGE_NAME     = GetConfig("docker", "image_name", "flask-echo").(string)
+	DOCKER_CONTAINER_NAME = GetConfig("docker", "container_name", "flask-echo-container").(string)
+)
+
+// 数值/布尔类型配置（需要类型转换）
+var (
+	APP_PORT  = getIntConfig("app", "port", 50100)
+	APP_DEBUG = getBoolConfig("app", "debug", false)
+)
+
+// WebAuthn 依赖方配置
+var (
+	WEBAUTHN_RP_ID           = GetConfig("webauthn", "rp_id", "localhost").(string)
+	WEBAUTHN_RP_NAME         = GetConfig("webauthn", "rp_name", "DID Portal").(string)
+	WEBAUTHN_ALLOWED_ORIGINS = getListConfig("webauthn", "allowed_origins", nil)
+	WEBAUTHN_ALLOW_LOCALHOST = getBoolConfig("webauthn", "allow_localhost", false)
+	WEBAUTHN_ALGORITHMS      = getListConfig("webauthn", "algorithms", []string{"ES256", "EdDSA", "ES384", "PS256", "RS256"}) // 按优先级排列
+
+	// 认证器证明
+	WEBAUTHN_ATTESTATION        = GetConfig("webauthn", "attestation", "none").(string)        // 注册时请求的证明方式: none/indirect/direct/enterprise
+	WEBAUTHN_ATTESTATION_POLICY = GetConfig("webauthn", "attestation_policy", "none").(string) // 默认证明策略: none/self/trusted
+	WEBAUTHN_TRUST_ANCHORS_PATH = GetConfig("webauthn", "trust_anchors_path", "").(string)     // 证明根证书目录（*.pem）
+
+	// FIDO 元数据服务（MDS3）
+	WEBAUTHN_MDS_BLOB_PATH       = GetConfig("webauthn", "mds_blob_path", "").(string) // MDS3 blob（JWT）文件路径
+	WEBAUTHN_MDS_ROOT_CERT       = GetConfig("webauthn", "mds_root_cert", "").(string) // blob 签名根证书（PEM）
+	WEBAUTHN_MDS_REFRESH_MINUTES = getIntConfig("webauthn", "mds_refresh_minutes", 60) // 检查 blob 文件更新的间隔，0 表示不刷新
+)
+
+// Sign-In with Ethereum (EIP-4361) 配置
+var (
+	SIWE_CHAIN_ID  = getIntConfig("siwe", "chain_id", CHAIN_ID)
+	SIWE_STATEMENT = GetConfig("siwe", "statement", "使用以太坊账户登录 DID Portal").(string)
+	SIWE_RESOURCES = getListConfig("siwe", "resources", nil)
+)
+
+// 链上访问配置（EIP-1271 合约钱包签名验证）
+var (
+	CHAIN_ID                  = getIntConfig("chain", "chain_id", 1)                      // 用户 DID 所在的链，did:ethr:<chain>:0x…
+	CHAIN_SIGNATURE_VERIFIER  = GetConfig("chain", "signature_verifier", "none").(string) // none / rpc / local
+	CHAIN_RPC_URL             = GetConfig("chain", "rpc_url", "").(string)
+	CHAIN_RPC_TIMEOUT_SECONDS = getIntConfig("chain", "rpc_timeout_seconds", 5)
+	CHAIN_LOCAL_WALLETS       = getListConfig("chain", "local_wallets", nil) // 合约地址:所有者1:所有者2
+)
+
+// DID 解析配置（GET /api/did/:did 和可验证展示中的持有者 DID）
+var (
+	DID_WEB_ALLOWED_HOSTS       = getListConfig("did", "web_allowed_hosts", nil)     // 允许解析的 did:web 主机，支持 *.example.com；为空表示任意公网主机
+	DID_WEB_ALLOW_PRIVATE       = getBoolConfig("did", "web_allow_private", false)   // 是否允许 did:web 连接内网、回环地址（仅本地开发）
+	DID_RESOLVE_RATE_PER_MINUTE = getIntConfig("did", "resolve_rate_per_minute", 30) // 每个客户端 IP 每分钟的解析次数，0 表示不限制
+)
+
+// 平台签发者配置（平台 DID 与本地签名密钥库）
+var (
+	ISSUER_DID            = GetConfig("issuer", "did", "did:web:"+WEBAUTHN_RP_ID).(string)
+	ISSUER_BASE_URL       = GetConfig("issuer", "base_url", "https://"+WEBAUTHN_RP_ID).(string) // 对外地址，用于凭证状态列表等公开URL
+	ISSUER_KEYSTORE_PATH  = GetConfig("issuer", "keystore_path", "config/keystore").(string)    // 每个 <kid>.pem 是一个 PKCS#8 私钥
+	ISSUER_CURRENT_KEY_ID = GetConfig("issuer", "current_key_id", "").(string)                  // 为空时使用最新的密钥签名
+	ISSUER_KEY_ALGORITHM  = GetConfig("issuer", "key_algorithm", "ES256").(string)              // 密钥库为空时生成的密钥类型: ES256/EdDSA
+
+	// 可验证凭证
+	ISSUER_CREDENTIAL_TTL_DAYS = getIntConfig("issuer", "credential_ttl_days", 365) // 用户类型凭证有效期（天）
+)
+
+// 登录令牌（JWT）配置
+var (
+	JWT_ISSUER             = GetConfig("jwt", "issuer", ISSUER_DID).(string)
+	JWT_AUDIENCE           = GetConfig("jwt", "audience", ISSUER_BASE_URL).(string)
+	JWT_ACCESS_TTL_MINUTES = getIntConfig("jwt", "access_ttl_minutes", 15) // 访问令牌有效期（分钟）
+	JWT_REFRESH_TTL_DAYS   = getIntConfig("jwt", "refresh_ttl_days", 30)   // 刷新令牌有效期（天），每次刷新重新计算
+
+	// 令牌签名密钥库（与凭证签名密钥分开，按计划轮换）
+	JWT_KEYSTORE_PATH     = GetConfig("jwt", "keystore_path", "config/keystore/jwt").(string)
+	JWT_KEY_ALGORITHM     = GetConfig("jwt", "key_algorithm", "ES256").(string) // ES256 / EdDSA
+	JWT_KEY_ROTATION_DAYS = getIntConfig("jwt", "key_rotation_days", 30)        // 自动轮换间隔（天），0 表示只手动轮换
+	JWT_KEY_GRACE_HOURS   = getIntConfig("jwt", "key_grace_hours", 24)          // 退役密钥继续发布的时间（小时），不少于令牌有效期
+)
+
+// 管理接口配置
+var (
+	ADMIN_API_TOKEN = GetConfig("admin", "api_token", "").(string) // 管理接口令牌（Authorization: Bearer），为空时禁用管理接口
+)
+
+// 辅助函数：获取整数类型配置
+func getIntConfig(section, key string, defaultValue int) int {
+	value := GetConfig(section, key, fmt.Sprintf("%d", defaultValue))
+	strVal, ok := value.(string)
+	if !ok {
+		return defaultValue
+	}
+
+	intVal, err := strconv.Atoi(strVal)
+	if err != nil {
+		return defaultValue
+	}
+	return intVal
+}
+
+// 辅助函数：获取布尔类型配置（兼容 true/false、1/0、yes/no）
+func getBoolConfig(section, key string, defaultValue bool) bool {
+	value := GetConfig(section, key, fmt.Sprintf("%t", defaultValue))
+	strVal, ok := value.(string)
+	if !ok {
+		return defaultValue
+	}
+
+	strVal = strings.ToLower(strings.TrimSpace(strVal))
+	switch strVal {
+	case "true", "1", "yes", "on":
+		return true
+	case "false", "0", "no", "off":
+		return false
+	default:
+		return defaultValue
+	}
+}
+
+// 辅助函数：获取列表类型配置（逗号分隔，忽略空项）
+func getListConfig(section, key string, defaultValue []string) []string {
+	value := GetConfig(section, key, "")
+	strVal, ok := value.(string)
+	if !ok || strings.TrimSpace(strVal) == "" {
+		return defaultValue
+	}
+
+	var list []string
+	for _, item := range strings.Split(strVal, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// 辅助函数：格式化输出所有配置（调试用）
+func PrintAllConfigs() {
+	fmt.Println("=== 当前配置 ===")
+	fmt.Printf("APP_NAME: %s\n", APP_NAME)
+	fmt.Printf("APP_PORT: %d\n", APP_PORT)
+	fmt.Printf("APP_HOST: %s\n", APP_HOST)
+	fmt.Printf("APP_DEBUG: %t\n", APP_DEBUG)
+	fmt.Printf("APP_LOG_PATH: %s\n", APP_LOG_PATH)
+	fmt.Printf("CONTAINER_LOG_PATH: %s\n", CONTAINER_LOG_PATH)
+	fmt.Printf("DOCKER_IMAGE_NAME: %s\n", DOCKER_IMAGE_NAME)
+	fmt.Printf("DOCKER_CONTAINER_NAME: %s\n", DOCKER_CONTAINER_NAME)
+	fmt.Printf("WEBAUTHN_RP_ID: %s\n", WEBAUTHN_RP_ID)
+	fmt.Printf("WEBAUTHN_RP_NAME: %s\n", WEBAUTHN_RP_NAME)
+	fmt.Printf("WEBAUTHN_ALLOWED_ORIGINS: %v\n", WEBAUTHN_ALLOWED_ORIGINS)
+	fmt.Printf("WEBAUTHN_ALLOW_LOCALHOST: %t\n", WEBAUTHN_ALLOW_LOCALHOST)
+	fmt.Printf("WEBAUTHN_ALGORITHMS: %v\n", WEBAUTHN_ALGORITHMS)
+	fmt.Printf("WEBAUTHN_ATTESTATION: %s\n", WEBAUTHN_ATTESTATION)
+	fmt.Printf("WEBAUTHN_ATTESTATION_POLICY: %s\n", WEBAUTHN_ATTESTATION_POLICY)
+	fmt.Printf("WEBAUTHN_TRUST_ANCHORS_PATH: %s\n", WEBAUTHN_TRUST_ANCHORS_PATH)
+	fmt.Printf("WEBAUTHN_MDS_BLOB_PATH: %s\n", WEBAUTHN_MDS_BLOB_PATH)
+	fmt.Printf("WEBAUTHN_MDS_ROOT_CERT: %s\n", WEBAUTHN_MDS_ROOT_CERT)
+	fmt.Printf("WEBAUTHN_MDS_REFRESH_MINUTES: %d\n", WEBAUTHN_MDS_REFRESH_MINUTES)
+	fmt.Printf("SIWE_CHAIN_ID: %d\n", SIWE_CHAIN_ID)
+	fmt.Printf("SIWE_STATEMENT: %s\n", SIWE_STATEMENT)
+	fmt.Printf("SIWE_RESOURCES: %v\n", SIWE_RESOURCES)
+	fmt.Printf("CHAIN_ID: %d\n", CHAIN_ID)
+	fmt.Printf("CHAIN_SIGNATURE_VERIFIER: %s\n", CHAIN_SIGNATURE_VERIFIER)
+	fmt.Printf("CHAIN_RPC_URL: %s\n", CHAIN_RPC_URL)
+	fmt.Printf("DID_WEB_ALLOWED_HOSTS: %v\n", DID_WEB_ALLOWED_HOSTS)
+	fmt.Printf("DID_WEB_ALLOW_PRIVATE: %t\n", DID_WEB_ALLOW_PRIVATE)
+	fmt.Printf("DID_RESOLVE_RATE_PER_MINUTE: %d\n", DID_RESOLVE_RATE_PER_MINUTE)
+	fmt.Printf("ISSUER_DID: %s\n", ISSUER_DID)
+	fmt.Printf("ISSUER_BASE_URL: %s\n", ISSUER_BASE_URL)
+	fmt.Printf("ISSUER_KEYSTORE_PATH: %s\n", ISSUER_KEYSTORE_PATH)
+	fmt.Printf("ISSUER_CURRENT_KEY_ID: %s\n", ISSUER_CURRENT_KEY_ID)
+	fmt.Printf("ISSUER_KEY_ALGORITHM: %s\n", ISSUER_KEY_ALGORITHM)
+	fmt.Printf("ISSUER_CREDENTIAL_TTL_DAYS: %d\n", ISSUER_CREDENTIAL_TTL_DAYS)
+	fmt.Printf("JWT_ISSUER: %s\n", JWT_ISSUER)
+	fmt.Printf("JWT_AUDIENCE: %s\n", JWT_AUDIENCE)
+	fmt.Printf("JWT_ACCESS_TTL_MINUTES: %d\n", JWT_ACCESS_TTL_MINUTES)
+	fmt.Printf("JWT_REFRESH_TTL_DAYS: %d\n", JWT_REFRESH_TTL_DAYS)
+	fmt.Printf("JWT_KEYSTORE_PATH: %s\n", JWT_KEYSTORE_PATH)
+	fmt.Printf("JWT_KEY_ALGORITHM: %s\n", JWT_KEY_ALGORITHM)
+	fmt.Printf("JWT_KEY_ROTATION_DAYS: %d\n", JWT_KEY_ROTATION_DAYS)
+	fmt.Printf("JWT_KEY_GRACE_HOURS: %d\n", JWT_KEY_GRACE_HOURS)
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// COSE 密钥类型（RFC 9053）
+const (
+	coseKtyOKP = 1
+	coseKtyEC2 = 2
+	coseKtyRSA = 3
+)
+
+// COSE 算法标识
+const (
+	coseAlgES256 = -7
+	coseAlgEdDSA = -8
+	coseAlgES384 = -35
+	coseAlgPS256 = -37
+	coseAlgRS256 = -257
+	coseAlgRS1   = -65535 // 仅用于部分TPM证明语句，不作为凭证算法
+)
+
+// COSE 曲线标识
+const (
+	coseCrvP256    = 1
+	coseCrvP384    = 2
+	coseCrvEd25519 = 6
+)
+
+// 配置中使用的算法名称
+var coseAlgorithmNames = map[string]int{
+	"ES256": coseAlgES256,
+	"EdDSA": coseAlgEdDSA,
+	"ES384": coseAlgES384,
+	"PS256": coseAlgPS256,
+	"RS256": coseAlgRS256,
+}
+
+// parseCOSEAlgorithms 将算法名称列表转换为COSE算法标识，保持原有顺序（即优先级）
+func parseCOSEAlgorithms(names []string) []int {
+	var algs []int
+	seen := map[int]bool{}
+	for _, name := range names {
+		alg, ok := coseAlgorithmNames[strings.TrimSpace(name)]
+		if !ok {
+			fmt.Printf("Warning: 忽略不支持的签名算法 %s\n", name)
+			continue
+		}
+		if !seen[alg] {
+			seen[alg] = true
+			algs = append(algs, alg)
+		}
+	}
+	return algs
+}
+
+// coseKey 解析后的COSE_Key
+type coseKey struct {
+	Kty int
+	Alg int
+	Crv int
+	X   []byte
+	Y   []byte
+	N   []byte
+	E   []byte
+}
+
+// parseCOSEKey 解析CBOR编码的COSE_Key
+func parseCOSEKey(raw []byte) (*coseKey, error) {
+	var m map[int]cbor.RawMessage
+	if err := cbor.Unmarshal(raw, &m); err != nil {
+		return nil, fmt.Errorf("COSE_Key解码失败: %v", err)
+	}
+
+	key := &coseKey{}
+	if err := unmarshalCOSEField(m, 1, &key.Kty, true); err != nil {
+		return nil, err
+	}
+	if err := unmarshalCOSEField(m, 3, &key.Alg, true); err != nil {
+		return nil, err
+	}
+
+	switch key.Kty {
+	case coseKtyEC2, coseKtyOKP:
+		if err := unmarshalCOSEField(m, -1, &key.Crv, true); err != nil {
+			return nil, err
+		}
+		if err := unmarshalCOSEField(m, -2, &key.X, true); err != nil {
+			return nil, err
+		}
+		if err := unmarshalCOSEField(m, -3, &key.Y, key.Kty == coseKtyEC2); err != nil {
+			return nil, err
+		}
+	case coseKtyRSA:
+		if err := unmarshalCOSEField(m, -1, &key.N, true); err != nil {
+			return nil, err
+		}
+		if err := unmarshalCOSEField(m, -2, &key.E, true); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("不支持的COSE密钥类型: %d", key.Kty)
+	}
+	return key, nil
+}
+
+// unmarshalCOSEField 读取COSE_Key中的单个字段
+func unmarshalCOSEField(m map[int]cbor.RawMessage, label int, v interface{}, required bool) error {
+	raw, ok := m[label]
+	if !ok {
+		if required {
+			return fmt.Errorf("COSE_Key缺少字段 %d", label)
+		}
+		return nil
+	}
+	if err := cbor.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("COSE_Key字段 %d 解码失败: %v", label, err)
+	}
+	return nil
+}
+
+// PublicKey 转换为Go标准库公钥
+func (k *coseKey) PublicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case coseKtyEC2:
+		var curve elliptic.Curve
+		switch k.Crv {
+		case coseCrvP256:
+			curve = elliptic.P256()
+		case coseCrvP384:
+			curve = elliptic.P384()
+		default:
+			return nil, fmt.Errorf("不支持的EC2曲线: %d", k.Crv)
+		}
+		pub := &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(k.X),
+			Y:     new(big.Int).SetBytes(k.Y),
+		}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, fmt.Errorf("EC公钥不在曲线上")
+		}
+		return pub, nil
+	case coseKtyOKP:
+		if k.Crv != coseCrvEd25519 {
+			return nil, fmt.Errorf("不支持的OKP曲线: %d", k.Crv)
+		}
+		if len(k.X) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("Ed25519公钥长度无效: %d", len(k.X))
+		}
+		return ed25519.PublicKey(k.X), nil
+	case coseKtyRSA:
+		e := new(big.Int).SetBytes(k.E)
+		if !e.IsInt64() || e.Int64() > int64(^uint32(0)>>1) {
+			return nil, fmt.Errorf("RSA公钥指数无效")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(k.N), E: int(e.Int64())}, nil
+	}
+	return nil, fmt.Errorf("不支持的COSE密钥类型: %d", k.Kty)
+}
+
+// Verify 按COSE算法验证签名
+func (k *coseKey) Verify(data, sig []byte) error {
+	pub, err := k.PublicKey()
+	if err != nil {
+		return err
+	}
+	return verifyCOSESignature(k.Alg, pub, data, sig)
+}
+
+// hashForCOSEAlg 返回COSE算法使用的摘要算法
+func hashForCOSEAlg(alg int) (crypto.Hash, error) {
+	switch alg {
+	case coseAlgES256, coseAlgRS256, coseAlgPS256:
+		return crypto.SHA256, nil
+	case coseAlgES384:
+		return crypto.SHA384, nil
+	case coseAlgRS1:
+		return crypto.SHA1, nil
+	}
+	return 0, fmt.Errorf("不支持的签名算法: %d", alg)
+}
+
+// verifyCOSESignature 使用指定COSE算法和公钥验证签名（凭证公钥和证明证书公钥共用）
+func verifyCOSESignature(alg int, pub crypto.PublicKey, data, sig []byte) error {
+	if alg == coseAlgEdDSA {
+		edPub, ok := pub.(ed25519.PublicKey)
+		if !ok {
+			return fmt.Errorf("EdDSA需要Ed25519公钥")
+		}
+		if !ed25519.Verify(edPub, data, sig) {
+			return fmt.Errorf("签名验证失败")
+		}
+		return nil
+	}
+
+	hash, err := hashForCOSEAlg(alg)
+	if err != nil {
+		return err
+	}
+	h := hash.New()
+	h.Write(data)
+	digest := h.Sum(nil)
+
+	switch alg {
+	case coseAlgES256, coseAlgES384:
+		ecPub, ok := pub.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("ECDSA算法需要EC2公钥")
+		}
+		// 曲线必须与算法对应：ES256 使用 P-256，ES384 使用 P-384
+		curve := elliptic.P256()
+		if alg == coseAlgES384 {
+			curve = elliptic.P384()
+		}
+		if ecPub.Curve != curve {
+			return fmt.Errorf("签名算法 %d 与公钥曲线不匹配", alg)
+		}
+		// WebAuthn 的 ECDSA 签名为 ASN.1 DER 编码
+		if !ecdsa.VerifyASN1(ecPub, digest, sig) {
+			return fmt.Errorf("签名验证失败")
+		}
+	case coseAlgRS256, coseAlgRS1:
+		rsaPub, ok := pub.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("RSA算法需要RSA公钥")
+		}
+		if err := rsa.VerifyPKCS1v15(rsaPub, hash, digest, sig); err != nil {
+			return fmt.Errorf("签名验证失败")
+		}
+	case coseAlgPS256:
+		rsaPub, ok := pub.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("RSA算法需要RSA公钥")
+		}
+		// RFC 8230: 盐长度等于摘要长度
+		if err := rsa.VerifyPSS(rsaPub, hash, digest, sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}); err != nil {
+			return fmt.Errorf("签名验证失败")
+		}
+	default:
+		return fmt.Errorf("不支持的签名算法: %d", alg)
+	}
+	return nil
+}
+
+// normalizeStoredPublicKey 将旧版保存的attestationObject转换为COSE_Key原始字节
+func normalizeStoredPublicKey(stored []byte) ([]byte, error) {
+	if _, err := parseCOSEKey(stored); err == nil {
+		return stored, nil
+	}
+	_, authData, err := parseAttestationObject(stored)
+	if err != nil {
+		return nil, err
+	}
+	if authData.AttestedCredential == nil {
+		return nil, fmt.Errorf("attestationObject中缺少凭证数据")
+	}
+	return authData.AttestedCredential.CredentialPublicKey, nil
+}
+
+// loadStoredCOSEKey 读取数据库中保存的公钥
+// 早期版本直接保存了整个attestationObject，这里兼容并从中提取COSE_Key
+func loadStoredCOSEKey(stored []byte) (*coseKey, error) {
+	if len(stored) == 0 {
+		return nil, fmt.Errorf("未找到已注册的公钥")
+	}
+
+	raw, err := normalizeStoredPublicKey(stored)
+	if err != nil {
+		return nil, err
+	}
+	return parseCOSEKey(raw)
+}
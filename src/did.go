@@ -0,0 +1,449 @@
+package main
+
+import (
+	"context"
+	"crypto/elliptic"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cosmos-link/did-login/config"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+// DID 解析错误（对应 DID Resolution 规范中的 error 代码）
+var (
+	errInvalidDID            = errors.New("invalidDid")
+	errDIDNotFound           = errors.New("notFound")
+	errDIDMethodNotSupported = errors.New("methodNotSupported")
+	errDIDWebHostNotAllowed  = errors.New("hostNotAllowed") // did:web 主机不在允许列表中
+)
+
+// DID 文档相关的 JSON-LD 上下文
+const (
+	didContextV1        = "https://www.w3.org/ns/did/v1"
+	didContextMultikey  = "https://w3id.org/security/multikey/v1"
+	didContextSecp256k1 = "https://w3id.org/security/suites/secp256k1recovery-2020/v2"
+)
+
+// did:web 文档大小上限
+const maxDIDWebDocumentSize = 1 << 20
+
+// verificationMethod DID 文档中的验证方法
+type verificationMethod struct {
+	ID                  string                 `json:"id"`
+	Type                string                 `json:"type"`
+	Controller          string                 `json:"controller"`
+	BlockchainAccountID string                 `json:"blockchainAccountId,omitempty"`
+	PublicKeyMultibase  string                 `json:"publicKeyMultibase,omitempty"`
+	PublicKeyJwk        map[string]interface{} `json:"publicKeyJwk,omitempty"`
+}
+
+// didDocument W3C DID Core 文档
+// authentication 等关系既可以是验证方法ID，也可以是内嵌的验证方法，因此使用 interface{}
+type didDocument struct {
+	Context              interface{}          `json:"@context"`
+	ID                   string               `json:"id"`
+	Controller           interface{}          `json:"controller,omitempty"`
+	AlsoKnownAs          []string             `json:"alsoKnownAs,omitempty"`
+	VerificationMethod   []verificationMethod `json:"verificationMethod,omitempty"`
+	Authentication       []interface{}        `json:"authentication,omitempty"`
+	AssertionMethod      []interface{}        `json:"assertionMethod,omitempty"`
+	KeyAgreement         []interface{}        `json:"keyAgreement,omitempty"`
+	CapabilityInvocation []interface{}        `json:"capabilityInvocation,omitempty"`
+	CapabilityDelegation []interface{}        `json:"capabilityDelegation,omitempty"`
+	Service              []interface{}        `json:"service,omitempty"`
+}
+
+// FindVerificationMethod 按ID（完整ID或 #fragment）查找验证方法
+func (d *didDocument) FindVerificationMethod(id string) *verificationMethod {
+	if strings.HasPrefix(id, "#") {
+		id = d.ID + id
+	}
+	for i := range d.VerificationMethod {
+		if d.VerificationMethod[i].ID == id {
+			return &d.VerificationMethod[i]
+		}
+	}
+	return nil
+}
+
+// DIDResolver 将 DID 解析为 DID 文档
+type DIDResolver interface {
+	Resolve(ctx context.Context, did string) (*didDocument, error)
+}
+
+// methodDIDResolver 按 DID 方法分派：did:ethr、did:key 离线生成，did:web 通过 HTTP 获取
+type methodDIDResolver struct {
+	httpClient *http.Client
+}
+
+// newDIDResolver 创建解析器；httpClient 用于 did:web，可注入测试或代理客户端，
+// 为空时使用 newDIDWebHTTPClient（只连接公网地址）
+func newDIDResolver(httpClient *http.Client) *methodDIDResolver {
+	if httpClient == nil {
+		httpClient = newDIDWebHTTPClient(config.DID_WEB_ALLOW_PRIVATE)
+	}
+	return &methodDIDResolver{httpClient: httpClient}
+}
+
+// 全局 DID 解析器
+var didResolver DIDResolver = newDIDResolver(nil)
+
+func (r *methodDIDResolver) Resolve(ctx context.Context, did string) (*didDocument, error) {
+	did, err := normalizeDID(did)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case strings.HasPrefix(did, "did:ethr:"):
+		return resolveDIDEthr(did)
+	case strings.HasPrefix(did, "did:key:"):
+		return resolveDIDKey(did)
+	case strings.HasPrefix(did, "did:web:"):
+		return r.resolveDIDWeb(ctx, did)
+	}
+	return nil, errDIDMethodNotSupported
+}
+
+// normalizeDID 规范化 DID：数据库中保存的裸地址 0x… 转换为 did:ethr:<chain>:0x…
+// did:ethr 的网络统一写成十六进制链ID，地址统一为小写
+func normalizeDID(did string) (string, error) {
+	did = strings.TrimSpace(did)
+	if strings.HasPrefix(did, "0x") || strings.HasPrefix(did, "0X") {
+		address, err := normalizeEthereumAddress(did)
+		if err != nil {
+			return "", errInvalidDID
+		}
+		return fmt.Sprintf("did:ethr:0x%x:%s", config.CHAIN_ID, address), nil
+	}
+
+	parts := strings.Split(did, ":")
+	if len(parts) < 3 || parts[0] != "did" || parts[1] == "" || parts[2] == "" {
+		return "", errInvalidDID
+	}
+	if parts[1] != "ethr" {
+		return did, nil
+	}
+
+	// did:ethr:0x… 表示主网，did:ethr:<network>:0x… 指定网络
+	network, addr := "0x1", parts[len(parts)-1]
+	switch len(parts) {
+	case 3:
+	case 4:
+		network = parts[2]
+	default:
+		return "", errInvalidDID
+	}
+	chainID, err := ethrNetworkChainID(network)
+	if err != nil {
+		return "", err
+	}
+	address, err := normalizeEthereumAddress(addr)
+	if err != nil {
+		return "", errInvalidDID
+	}
+	return fmt.Sprintf("did:ethr:0x%x:%s", chainID, address), nil
+}
+
+// ethrNetworkChainID 解析 did:ethr 的网络名称或十六进制链ID
+func ethrNetworkChainID(network string) (int64, error) {
+	switch network {
+	case "mainnet":
+		return 1, nil
+	case "sepolia":
+		return 11155111, nil
+	}
+	if strings.HasPrefix(network, "0x") {
+		if id, err := strconv.ParseInt(network[2:], 16, 64); err == nil && id > 0 {
+			return id, nil
+		}
+	}
+	return 0, errInvalidDID
+}
+
+// -------------------------- did:ethr --------------------------
+
+// resolveDIDEthr 生成 did:ethr 的默认文档（未访问 ERC-1056 注册表，即链上没有变更时的文档）
+func resolveDIDEthr(did string) (*didDocument, error) {
+	parts := strings.Split(did, ":")
+	chainID, _ := ethrNetworkChainID(parts[2])
+	address := parts[3]
+
+	controller := did + "#controller"
+	return &didDocument{
+		Context: []string{didContextV1, didContextSecp256k1},
+		ID:      did,
+		VerificationMethod: []verificationMethod{{
+			ID:                  controller,
+			Type:                "EcdsaSecp256k1RecoveryMethod2020",
+			Controller:          did,
+			BlockchainAccountID: fmt.Sprintf("eip155:%d:%s", chainID, address),
+		}},
+		Authentication:  []interface{}{controller},
+		AssertionMethod: []interface{}{controller},
+	}, nil
+}
+
+// -------------------------- did:key --------------------------
+
+// multicodec 公钥前缀（varint 编码）
+var (
+	multicodecEd25519Pub   = []byte{0xed, 0x01}
+	multicodecSecp256k1Pub = []byte{0xe7, 0x01}
+	multicodecP256Pub      = []byte{0x80, 0x24}
+)
+
+// resolveDIDKey 从 did:key 中解出公钥并生成文档（支持 Ed25519、secp256k1、P-256）
+func resolveDIDKey(did string) (*didDocument, error) {
+	multibase := strings.TrimPrefix(did, "did:key:")
+	if !strings.HasPrefix(multibase, "z") {
+		return nil, errInvalidDID
+	}
+	raw, err := base58Decode(multibase[1:])
+	if err != nil || len(raw) < 2 {
+		return nil, errInvalidDID
+	}
+
+	switch {
+	case hasPrefixBytes(raw, multicodecEd25519Pub):
+		if len(raw)-2 != 32 {
+			return nil, errInvalidDID
+		}
+	case hasPrefixBytes(raw, multicodecSecp256k1Pub):
+		if _, err := secp256k1.ParsePubKey(raw[2:]); err != nil {
+			return nil, errInvalidDID
+		}
+	case hasPrefixBytes(raw, multicodecP256Pub):
+		if x, _ := elliptic.UnmarshalCompressed(elliptic.P256(), raw[2:]); x == nil {
+			return nil, errInvalidDID
+		}
+	default:
+		return nil, errDIDMethodNotSupported
+	}
+
+	keyID := did + "#" + multibase
+	return &didDocument{
+		Context: []string{didContextV1, didContextMultikey},
+		ID:      did,
+		VerificationMethod: []verificationMethod{{
+			ID:                 keyID,
+			Type:               "Multikey",
+			Controller:         did,
+			PublicKeyMultibase: multibase,
+		}},
+		Authentication:       []interface{}{keyID},
+		AssertionMethod:      []interface{}{keyID},
+		CapabilityInvocation: []interface{}{keyID},
+		CapabilityDelegation: []interface{}{keyID},
+	}, nil
+}
+
+func hasPrefixBytes(b, prefix []byte) bool {
+	return len(b) >= len(prefix) && string(b[:len(prefix)]) == string(prefix)
+}
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// base58Decode base58btc 解码
+func base58Decode(s string) ([]byte, error) {
+	n := new(big.Int)
+	radix := big.NewInt(58)
+	for _, c := range s {
+		idx := strings.IndexRune(base58Alphabet, c)
+		if idx < 0 {
+			return nil, fmt.Errorf("无效的base58字符: %c", c)
+		}
+		n.Mul(n, radix)
+		n.Add(n, big.NewInt(int64(idx)))
+	}
+
+	leadingZeros := 0
+	for leadingZeros < len(s) && s[leadingZeros] == base58Alphabet[0] {
+		leadingZeros++
+	}
+	return append(make([]byte, leadingZeros), n.Bytes()...), nil
+}
+
+// base58Encode base58btc 编码
+func base58Encode(data []byte) string {
+	n := new(big.Int).SetBytes(data)
+	radix := big.NewInt(58)
+	mod := new(big.Int)
+	var out []byte
+	for n.Sign() > 0 {
+		n.DivMod(n, radix, mod)
+		out = append(out, base58Alphabet[mod.Int64()])
+	}
+	for _, b := range data {
+		if b != 0 {
+			break
+		}
+		out = append(out, base58Alphabet[0])
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return string(out)
+}
+
+// -------------------------- did:web --------------------------
+
+// 禁止 did:web 连接的地址段（除 netip.Addr 方法已能识别的回环、私有、链路本地、未指定地址以外）
+var didWebBlockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // 本网络
+	netip.MustParsePrefix("100.64.0.0/10"), // 运营商级 NAT
+	netip.MustParsePrefix("192.0.0.0/24"),  // IETF 协议分配
+	netip.MustParsePrefix("198.18.0.0/15"), // 基准测试
+	netip.MustParsePrefix("240.0.0.0/4"),   // 保留
+	netip.MustParsePrefix("64:ff9b::/96"),  // NAT64，可映射到任意 IPv4 地址
+}
+
+// isPublicAddr 地址是否可以被 did:web 解析连接（不是回环、内网、链路本地、未指定或保留地址）
+func isPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return false
+	}
+	for _, prefix := range didWebBlockedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// newDIDWebHTTPClient did:web 专用的 HTTP 客户端，防止通过 DID 让服务端访问内网（SSRF）：
+// 在 DNS 解析之后、建立连接之前检查实际连接的 IP（同时防止 DNS 重绑定），不使用环境变量中的代理，不跟随重定向
+func newDIDWebHTTPClient(allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			if allowPrivate {
+				return nil
+			}
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !isPublicAddr(addrPort.Addr()) {
+				return fmt.Errorf("did:web 不允许连接非公网地址 %s", addrPort.Addr())
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return fmt.Errorf("did:web 文档地址不允许重定向")
+		},
+	}
+}
+
+// didWebHostAllowed 主机是否在 [did] 节的 web_allowed_hosts 中（支持 *.example.com），列表为空时允许任意主机
+func didWebHostAllowed(host string) bool {
+	if len(config.DID_WEB_ALLOWED_HOSTS) == 0 {
+		return true
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	for _, allowed := range config.DID_WEB_ALLOWED_HOSTS {
+		allowed = strings.ToLower(allowed)
+		if host == allowed {
+			return true
+		}
+		if strings.HasPrefix(allowed, "*.") && strings.HasSuffix(host, allowed[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// didWebURL 将 did:web 转换为文档地址
+// did:web:example.com → https://example.com/.well-known/did.json
+// did:web:example.com:user:alice → https://example.com/user/alice/did.json
+func didWebURL(did string) (string, error) {
+	parts := strings.Split(strings.TrimPrefix(did, "did:web:"), ":")
+	host, err := url.PathUnescape(parts[0]) // 端口写成 %3A
+	if err != nil || host == "" || strings.ContainsAny(host, "/?#@") {
+		return "", errInvalidDID
+	}
+
+	path := "/.well-known"
+	if len(parts) > 1 {
+		segments := make([]string, 0, len(parts)-1)
+		for _, p := range parts[1:] {
+			segment, err := url.PathUnescape(p)
+			if err != nil || segment == "" || segment == "." || segment == ".." || strings.Contains(segment, "/") {
+				return "", errInvalidDID
+			}
+			segments = append(segments, url.PathEscape(segment))
+		}
+		path = "/" + strings.Join(segments, "/")
+	}
+	return "https://" + host + path + "/did.json", nil
+}
+
+func (r *methodDIDResolver) resolveDIDWeb(ctx context.Context, did string) (*didDocument, error) {
+	docURL, err := didWebURL(did)
+	if err != nil {
+		return nil, err
+	}
+	if u, _ := url.Parse(docURL); u == nil || !didWebHostAllowed(u.Host) {
+		return nil, errDIDWebHostNotAllowed
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, docURL, nil)
+	if err != nil {
+		return nil, errInvalidDID
+	}
+	req.Header.Set("Accept", "application/did+json, application/json")
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("获取did:web文档失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone {
+		return nil, errDIDNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("获取did:web文档失败: HTTP %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxDIDWebDocumentSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("读取did:web文档失败: %v", err)
+	}
+	if len(body) > maxDIDWebDocumentSize {
+		return nil, fmt.Errorf("did:web文档过大")
+	}
+
+	var doc didDocument
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("解析did:web文档失败: %v", err)
+	}
+	if doc.ID != did {
+		return nil, fmt.Errorf("did:web文档id %s 与请求的 %s 不一致", doc.ID, did)
+	}
+	return &doc, nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/cosmos-link/did-login/config"
+)
+
+func TestIsPublicAddr(t *testing.T) {
+	tests := []struct {
+		addr   string
+		public bool
+	}{
+		{"93.184.215.14", true},
+		{"2606:2800:21f:cb07:6820:80da:af6b:8b2c", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false}, // 云厂商元数据服务
+		{"fe80::1", false},
+		{"fc00::1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"100.64.0.1", false},
+		{"::ffff:127.0.0.1", false}, // IPv4 映射地址
+		{"::ffff:169.254.169.254", false},
+		{"64:ff9b::a9fe:a9fe", false}, // NAT64 映射的 169.254.169.254
+	}
+	for _, tt := range tests {
+		if got := isPublicAddr(netip.MustParseAddr(tt.addr)); got != tt.public {
+			t.Errorf("isPublicAddr(%s) = %t, want %t", tt.addr, got, tt.public)
+		}
+	}
+}
+
+func TestDIDWebClientRejectsLoopback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	if _, err := newDIDWebHTTPClient(false).Get(server.URL); err == nil {
+		t.Fatal("did:web 客户端连接了回环地址")
+	}
+	resp, err := newDIDWebHTTPClient(true).Get(server.URL)
+	if err != nil {
+		t.Fatalf("允许内网地址时请求失败: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestDIDWebClientRefusesRedirect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://169.254.169.254/latest/meta-data/", http.StatusFound)
+	}))
+	defer server.Close()
+
+	if _, err := newDIDWebHTTPClient(true).Get(server.URL); err == nil {
+		t.Fatal("did:web 客户端跟随了重定向")
+	}
+}
+
+func TestDIDWebHostAllowed(t *testing.T) {
+	saved := config.DID_WEB_ALLOWED_HOSTS
+	defer func() { config.DID_WEB_ALLOWED_HOSTS = saved }()
+
+	config.DID_WEB_ALLOWED_HOSTS = nil
+	if !didWebHostAllowed("example.com") {
+		t.Error("未配置允许列表时应允许任意主机")
+	}
+
+	config.DID_WEB_ALLOWED_HOSTS = []string{"issuer.example.com", "*.partner.org"}
+	tests := []struct {
+		host    string
+		allowed bool
+	}{
+		{"issuer.example.com", true},
+		{"Issuer.Example.com:8443", true},
+		{"a.partner.org", true},
+		{"partner.org", false},
+		{"evilpartner.org", false},
+		{"example.com", false},
+	}
+	for _, tt := range tests {
+		if got := didWebHostAllowed(tt.host); got != tt.allowed {
+			t.Errorf("didWebHostAllowed(%s) = %t, want %t", tt.host, got, tt.allowed)
+		}
+	}
+}
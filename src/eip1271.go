@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cosmos-link/did-login/config"
+)
+
+// EIP-1271 isValidSignature(bytes32,bytes) 的函数选择器，也是合法签名时的返回值
+var eip1271MagicValue = []byte{0x16, 0x26, 0xba, 0x7e}
+
+// ContractSignatureVerifier 合约钱包（多签等）签名验证，对应 EIP-1271 isValidSignature
+type ContractSignatureVerifier interface {
+	IsValidSignature(ctx context.Context, contract string, hash []byte, signature []byte) (bool, error)
+}
+
+// 全局合约签名验证器，为 nil 时只支持普通账户（EOA）签名
+var contractVerifier ContractSignatureVerifier
+
+// initContractVerifier 按配置选择合约签名验证器：
+// none 只支持 EOA（离线部署），rpc 调用以太坊 JSON-RPC，local 使用配置中的钱包所有者列表
+func initContractVerifier() {
+	switch config.CHAIN_SIGNATURE_VERIFIER {
+	case "", "none":
+		fmt.Println("未启用合约钱包签名验证，仅支持普通账户签名")
+		contractVerifier = nil
+	case "rpc":
+		if config.CHAIN_RPC_URL == "" {
+			fmt.Println("Warning: 未配置 rpc_url，合约钱包签名验证不可用")
+			return
+		}
+		fmt.Printf("✅ 使用 JSON-RPC 验证合约钱包签名: %s\n", config.CHAIN_RPC_URL)
+		contractVerifier = newRPCContractVerifier(config.CHAIN_RPC_URL, time.Duration(config.CHAIN_RPC_TIMEOUT_SECONDS)*time.Second)
+	case "local":
+		verifier, err := newLocalContractVerifier(config.CHAIN_LOCAL_WALLETS)
+		if err != nil {
+			fmt.Printf("Warning: 本地合约钱包配置无效: %v\n", err)
+			return
+		}
+		fmt.Printf("✅ 使用本地配置验证合约钱包签名（%d 个钱包）\n", len(verifier.owners))
+		contractVerifier = verifier
+	default:
+		fmt.Printf("Warning: 未知的签名验证器 %s，仅支持普通账户签名\n", config.CHAIN_SIGNATURE_VERIFIER)
+	}
+}
+
+// verifyDIDSignature 验证 DID 对 personal_sign 消息的签名：
+// 先按普通账户恢复地址，不匹配时再按合约钱包（EIP-1271）验证
+func verifyDIDSignature(ctx context.Context, message string, signature string, address string) error {
+	eoaErr := verifyPersonalSign(message, signature, address)
+	if eoaErr == nil || contractVerifier == nil {
+		return eoaErr
+	}
+
+	normalized, err := normalizeEthereumAddress(address)
+	if err != nil {
+		return err
+	}
+	sig, err := decodeHexSignature(signature)
+	if err != nil {
+		return err
+	}
+
+	valid, err := contractVerifier.IsValidSignature(ctx, normalized, eip191Hash([]byte(message)), sig)
+	if err != nil {
+		return fmt.Errorf("合约钱包签名验证失败: %v", err)
+	}
+	if !valid {
+		return eoaErr
+	}
+	return nil
+}
+
+// -------------------------- JSON-RPC 实现 --------------------------
+
+// rpcContractVerifier 通过 eth_call 调用合约的 isValidSignature
+type rpcContractVerifier struct {
+	endpoint string
+	client   *http.Client
+}
+
+func newRPCContractVerifier(endpoint string, timeout time.Duration) *rpcContractVerifier {
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	return &rpcContractVerifier{endpoint: endpoint, client: &http.Client{Timeout: timeout}}
+}
+
+// encodeIsValidSignatureCall ABI 编码 isValidSignature(bytes32 hash, bytes signature)
+func encodeIsValidSignatureCall(hash []byte, signature []byte) []byte {
+	word := func(n int) []byte {
+		b := make([]byte, 32)
+		b[28], b[29], b[30], b[31] = byte(n>>24), byte(n>>16), byte(n>>8), byte(n)
+		return b
+	}
+
+	data := append([]byte{}, eip1271MagicValue...)
+	data = append(data, hash...)
+	data = append(data, word(64)...) // bytes 参数的偏移量
+	data = append(data, word(len(signature))...)
+	data = append(data, signature...)
+	if pad := len(signature) % 32; pad != 0 {
+		data = append(data, make([]byte, 32-pad)...)
+	}
+	return data
+}
+
+func (v *rpcContractVerifier) IsValidSignature(ctx context.Context, contract string, hash []byte, signature []byte) (bool, error) {
+	payload, _ := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "eth_call",
+		"params": []interface{}{
+			map[string]string{
+				"to":   contract,
+				"data": "0x" + hex.EncodeToString(encodeIsValidSignatureCall(hash, signature)),
+			},
+			"latest",
+		},
+	})
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	// 非 200 响应（限流、网关错误页等）不是 JSON-RPC 响应，直接报错
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+		return false, fmt.Errorf("JSON-RPC请求失败: HTTP %d %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var result struct {
+		Result string `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, fmt.Errorf("解析JSON-RPC响应失败: %v", err)
+	}
+	// 合约回滚（签名无效或不是合约）视为验证不通过
+	if result.Error != nil {
+		fmt.Printf("【EIP-1271】eth_call 返回错误: %d %s\n", result.Error.Code, result.Error.Message)
+		return false, nil
+	}
+
+	ret, err := hex.DecodeString(strings.TrimPrefix(result.Result, "0x"))
+	if err != nil {
+		return false, fmt.Errorf("JSON-RPC返回值无效: %v", err)
+	}
+	// 返回值为 bytes4，ABI 编码后左对齐在 32 字节中
+	return len(ret) >= 4 && bytes.Equal(ret[:4], eip1271MagicValue), nil
+}
+
+// -------------------------- 本地实现 --------------------------
+
+// localContractVerifier 不访问链上，按配置的钱包所有者列表验证：签名者是任一所有者即视为有效
+// 用于离线部署和测试
+type localContractVerifier struct {
+	owners map[string][]string // 合约地址 -> 所有者地址
+}
+
+// newLocalContractVerifier 解析配置，格式: 合约地址:所有者1:所有者2,合约地址2:所有者3
+func newLocalContractVerifier(wallets []string) (*localContractVerifier, error) {
+	v := &localContractVerifier{owners: map[string][]string{}}
+	for _, wallet := range wallets {
+		parts := strings.Split(wallet, ":")
+		if len(parts) < 2 {
+			return nil, fmt.Errorf("钱包配置缺少所有者: %s", wallet)
+		}
+		contract, err := normalizeEthereumAddress(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		for _, owner := range parts[1:] {
+			normalized, err := normalizeEthereumAddress(owner)
+			if err != nil {
+				return nil, err
+			}
+			v.owners[contract] = append(v.owners[contract], normalized)
+		}
+	}
+	return v, nil
+}
+
+func (v *localContractVerifier) IsValidSignature(ctx context.Context, contract string, hash []byte, signature []byte) (bool, error) {
+	owners, ok := v.owners[contract]
+	if !ok {
+		return false, nil
+	}
+	signer, err := recoverEthereumAddress(hash, signature)
+	if err != nil {
+		return false, nil
+	}
+	for _, owner := range owners {
+		if owner == signer {
+			return true, nil
+		}
+	}
+	return false, nil
+}
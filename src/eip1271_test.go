@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	secpecdsa "github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+)
+
+// newTestEthereumKey 随机 secp256k1 私钥及其以太坊地址
+func newTestEthereumKey(t *testing.T) (*secp256k1.PrivateKey, string) {
+	t.Helper()
+	key, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, publicKeyToAddress(key.PubKey())
+}
+
+// signTestPersonalMessage 按钱包的 personal_sign 格式签名，返回 0x 前缀的 r || s || v（v 为 27/28）
+func signTestPersonalMessage(key *secp256k1.PrivateKey, message string) string {
+	compact := secpecdsa.SignCompact(key, eip191Hash([]byte(message)), false) // [27 + recid] || r || s
+	sig := append(append([]byte{}, compact[1:]...), compact[0])
+	return "0x" + hex.EncodeToString(sig)
+}
+
+// withContractVerifier 在测试期间替换全局合约签名验证器
+func withContractVerifier(t *testing.T, verifier ContractSignatureVerifier) {
+	saved := contractVerifier
+	t.Cleanup(func() { contractVerifier = saved })
+	contractVerifier = verifier
+}
+
+const eip1271TestMessage = "did-login 请求您签名以验证钱包所有权。\n\nNonce: 0123456789abcdef"
+
+func TestVerifyDIDSignatureEOA(t *testing.T) {
+	withContractVerifier(t, nil)
+	key, address := newTestEthereumKey(t)
+	_, other := newTestEthereumKey(t)
+	signature := signTestPersonalMessage(key, eip1271TestMessage)
+
+	if err := verifyDIDSignature(context.Background(), eip1271TestMessage, signature, address); err != nil {
+		t.Fatalf("EOA 签名验证失败: %v", err)
+	}
+	if err := verifyDIDSignature(context.Background(), eip1271TestMessage, signature, strings.ToUpper("0x"+address[2:])); err != nil {
+		t.Fatalf("大写地址验证失败: %v", err)
+	}
+	if err := verifyDIDSignature(context.Background(), eip1271TestMessage, signature, other); err == nil {
+		t.Fatal("其他地址的签名验证通过")
+	}
+	if err := verifyDIDSignature(context.Background(), eip1271TestMessage+"!", signature, address); err == nil {
+		t.Fatal("修改后的消息验证通过")
+	}
+}
+
+func TestVerifyDIDSignatureLocalContract(t *testing.T) {
+	owner, ownerAddress := newTestEthereumKey(t)
+	stranger, _ := newTestEthereumKey(t)
+	_, contract := newTestEthereumKey(t)
+	_, otherContract := newTestEthereumKey(t)
+
+	verifier, err := newLocalContractVerifier([]string{contract + ":" + ownerAddress})
+	if err != nil {
+		t.Fatal(err)
+	}
+	withContractVerifier(t, verifier)
+
+	tests := []struct {
+		name     string
+		signer   *secp256k1.PrivateKey
+		contract string
+		ok       bool
+	}{
+		{"owner signs for wallet", owner, contract, true},
+		{"stranger signs for wallet", stranger, contract, false},
+		{"unknown wallet", owner, otherContract, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyDIDSignature(context.Background(), eip1271TestMessage, signTestPersonalMessage(tt.signer, eip1271TestMessage), tt.contract)
+			if (err == nil) != tt.ok {
+				t.Fatalf("verifyDIDSignature() = %v, want ok=%t", err, tt.ok)
+			}
+		})
+	}
+
+	if _, err := newLocalContractVerifier([]string{contract}); err == nil {
+		t.Fatal("缺少所有者的钱包配置被接受")
+	}
+	if _, err := newLocalContractVerifier([]string{contract + ":0x1234"}); err == nil {
+		t.Fatal("无效的所有者地址被接受")
+	}
+}
+
+// newEIP1271TestServer 模拟 JSON-RPC 节点：校验 eth_call 请求，然后按 respond 写出响应
+func newEIP1271TestServer(t *testing.T, contract string, respond func(w http.ResponseWriter)) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Method != "eth_call" || len(req.Params) != 2 {
+			t.Errorf("意外的 JSON-RPC 请求: %+v (%v)", req, err)
+		}
+		var call struct {
+			To   string `json:"to"`
+			Data string `json:"data"`
+		}
+		json.Unmarshal(req.Params[0], &call)
+		if call.To != contract || !strings.HasPrefix(call.Data, "0x1626ba7e") {
+			t.Errorf("eth_call 参数错误: %+v", call)
+		}
+		respond(w)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestVerifyDIDSignatureRPCContract(t *testing.T) {
+	signer, _ := newTestEthereumKey(t)
+	_, contract := newTestEthereumKey(t)
+	signature := signTestPersonalMessage(signer, eip1271TestMessage)
+	jsonResponse := func(body string) func(w http.ResponseWriter) {
+		return func(w http.ResponseWriter) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(body))
+		}
+	}
+
+	tests := []struct {
+		name    string
+		respond func(w http.ResponseWriter)
+		ok      bool
+		errText string // 非空时要求错误信息包含此文本
+	}{
+		{"magic value", jsonResponse(`{"jsonrpc":"2.0","id":1,"result":"0x1626ba7e00000000000000000000000000000000000000000000000000000000"}`), true, ""},
+		{"wrong magic value", jsonResponse(`{"jsonrpc":"2.0","id":1,"result":"0xffffffff00000000000000000000000000000000000000000000000000000000"}`), false, "不一致"},
+		{"empty result", jsonResponse(`{"jsonrpc":"2.0","id":1,"result":"0x"}`), false, "不一致"},
+		{"contract reverted", jsonResponse(`{"jsonrpc":"2.0","id":1,"error":{"code":3,"message":"execution reverted"}}`), false, "不一致"},
+		{"invalid result", jsonResponse(`{"jsonrpc":"2.0","id":1,"result":"0xzz"}`), false, "返回值无效"},
+		{"http 500 html", func(w http.ResponseWriter) {
+			w.Header().Set("Content-Type", "text/html")
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("<html><body>Internal Server Error</body></html>"))
+		}, false, "HTTP 500"},
+		{"http 429", func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"error":"rate limited"}`))
+		}, false, "HTTP 429"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newEIP1271TestServer(t, contract, tt.respond)
+			withContractVerifier(t, newRPCContractVerifier(server.URL, time.Second))
+
+			err := verifyDIDSignature(context.Background(), eip1271TestMessage, signature, contract)
+			if (err == nil) != tt.ok {
+				t.Fatalf("verifyDIDSignature() = %v, want ok=%t", err, tt.ok)
+			}
+			if tt.errText != "" && !strings.Contains(err.Error(), tt.errText) {
+				t.Fatalf("错误信息 %q 不包含 %q", err, tt.errText)
+			}
+		})
+	}
+
+	// 节点不可达
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+	withContractVerifier(t, newRPCContractVerifier(url, time.Second))
+	if err := verifyDIDSignature(context.Background(), eip1271TestMessage, signature, contract); err == nil || !strings.Contains(err.Error(), "合约钱包签名验证失败") {
+		t.Fatalf("节点不可达时 err = %v", err)
+	}
+}
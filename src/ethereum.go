@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	secpecdsa "github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"golang.org/x/crypto/sha3"
+)
+
+// keccak256 以太坊使用的 Keccak-256（非标准 SHA3-256）
+func keccak256(data ...[]byte) []byte {
+	h := sha3.NewLegacyKeccak256()
+	for _, d := range data {
+		h.Write(d)
+	}
+	return h.Sum(nil)
+}
+
+// eip191Hash personal_sign 的消息哈希: keccak256("\x19Ethereum Signed Message:\n" + len(message) + message)
+func eip191Hash(message []byte) []byte {
+	prefix := fmt.Sprintf("\x19Ethereum Signed Message:\n%d", len(message))
+	return keccak256([]byte(prefix), message)
+}
+
+// normalizeEthereumAddress 校验并转换为小写的 0x 地址
+func normalizeEthereumAddress(address string) (string, error) {
+	address = strings.TrimSpace(address)
+	if !strings.HasPrefix(address, "0x") && !strings.HasPrefix(address, "0X") {
+		return "", fmt.Errorf("无效的以太坊地址: %s", address)
+	}
+	raw, err := hex.DecodeString(address[2:])
+	if err != nil || len(raw) != 20 {
+		return "", fmt.Errorf("无效的以太坊地址: %s", address)
+	}
+	return "0x" + hex.EncodeToString(raw), nil
+}
+
+// publicKeyToAddress 公钥对应的以太坊地址: keccak256(X || Y) 的后 20 字节
+func publicKeyToAddress(pub *secp256k1.PublicKey) string {
+	uncompressed := pub.SerializeUncompressed()
+	return "0x" + hex.EncodeToString(keccak256(uncompressed[1:])[12:])
+}
+
+// recoverEthereumAddress 从 65 字节签名 r || s || v 中恢复签名者地址
+func recoverEthereumAddress(hash []byte, signature []byte) (string, error) {
+	if len(signature) != 65 {
+		return "", fmt.Errorf("签名长度无效: %d", len(signature))
+	}
+
+	// v 兼容 27/28 和 0/1 两种写法
+	v := signature[64]
+	if v >= 27 {
+		v -= 27
+	}
+	if v > 1 {
+		return "", fmt.Errorf("签名 v 值无效: %d", signature[64])
+	}
+
+	var s secp256k1.ModNScalar
+	if overflow := s.SetByteSlice(signature[32:64]); overflow || s.IsZero() {
+		return "", fmt.Errorf("签名 s 值无效")
+	}
+	if s.IsOverHalfOrder() {
+		return "", fmt.Errorf("签名 s 值过大（EIP-2）")
+	}
+
+	// RecoverCompact 需要的格式: [27 + recid] || r || s（未压缩公钥）
+	compact := make([]byte, 65)
+	compact[0] = 27 + v
+	copy(compact[1:], signature[:64])
+	pub, _, err := secpecdsa.RecoverCompact(compact, hash)
+	if err != nil {
+		return "", fmt.Errorf("恢复签名公钥失败: %v", err)
+	}
+	return publicKeyToAddress(pub), nil
+}
+
+// decodeHexSignature 解码 0x 前缀的十六进制签名
+func decodeHexSignature(signature string) ([]byte, error) {
+	signature = strings.TrimPrefix(strings.TrimSpace(signature), "0x")
+	raw, err := hex.DecodeString(signature)
+	if err != nil {
+		return nil, fmt.Errorf("签名格式无效: %v", err)
+	}
+	return raw, nil
+}
+
+// verifyPersonalSign 验证 EIP-191 personal_sign 签名是否由指定地址签出
+func verifyPersonalSign(message string, signature string, address string) error {
+	expected, err := normalizeEthereumAddress(address)
+	if err != nil {
+		return err
+	}
+	sig, err := decodeHexSignature(signature)
+	if err != nil {
+		return err
+	}
+
+	recovered, err := recoverEthereumAddress(eip191Hash([]byte(message)), sig)
+	if err != nil {
+		return err
+	}
+	if recovered != expected {
+		return fmt.Errorf("签名地址 %s 与 DID %s 不一致", recovered, expected)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+// web3.js 文档中 web3.eth.accounts.sign("Some data", privateKey) 的示例
+const (
+	personalSignTestPrivateKey  = "4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318"
+	personalSignTestAddress     = "0x2c7536e3605d9c16a7a3d7b1898e529396a65c23"
+	personalSignTestMessage     = "Some data"
+	personalSignTestMessageHash = "1da44b586eb0729ff70a73c326926f6ed5a25f5b056e7f47fbc6e58d86871655"
+	personalSignTestSignature   = "0xb91467e570a6466aa9e9876cbcd013baba02900b8979d43fe208a4a4f339f5fd6007e74cd82e037b800186422fc2da167c747ef045e5d18a5f5d4300f8e1a0291c"
+)
+
+func TestKeccak256(t *testing.T) {
+	if got := hex.EncodeToString(keccak256(nil)); got != "c5d2460186f7233c927e7db2dcc703c0e500b653ca82273b7bfad8045d85a470" {
+		t.Fatalf("keccak256(\"\") = %s", got)
+	}
+	if got := hex.EncodeToString(eip191Hash([]byte(personalSignTestMessage))); got != personalSignTestMessageHash {
+		t.Fatalf("eip191Hash() = %s, want %s", got, personalSignTestMessageHash)
+	}
+}
+
+func TestPublicKeyToAddress(t *testing.T) {
+	raw, _ := hex.DecodeString(personalSignTestPrivateKey)
+	key := secp256k1.PrivKeyFromBytes(raw)
+	if got := publicKeyToAddress(key.PubKey()); got != personalSignTestAddress {
+		t.Fatalf("publicKeyToAddress() = %s, want %s", got, personalSignTestAddress)
+	}
+}
+
+// withSignatureV 替换签名的 v 字节
+func withSignatureV(t *testing.T, signature string, v byte) []byte {
+	t.Helper()
+	sig, err := decodeHexSignature(signature)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig[64] = v
+	return sig
+}
+
+func TestRecoverEthereumAddress(t *testing.T) {
+	hash := eip191Hash([]byte(personalSignTestMessage))
+
+	// 同一签名的高 s 形式: s' = n - s，恢复标识取反，数学上仍然有效
+	highS := withSignatureV(t, personalSignTestSignature, 27)
+	var s secp256k1.ModNScalar
+	s.SetByteSlice(highS[32:64])
+	sBytes := s.Negate().Bytes()
+	copy(highS[32:64], sBytes[:])
+
+	tests := []struct {
+		name      string
+		signature []byte
+		want      string // 为空时期望返回错误
+	}{
+		{"v=28", withSignatureV(t, personalSignTestSignature, 28), personalSignTestAddress},
+		{"v=1", withSignatureV(t, personalSignTestSignature, 1), personalSignTestAddress},
+		{"flipped v=27", withSignatureV(t, personalSignTestSignature, 27), "other"},
+		{"flipped v=0", withSignatureV(t, personalSignTestSignature, 0), "other"},
+		{"invalid v", withSignatureV(t, personalSignTestSignature, 29), ""},
+		{"invalid v=2", withSignatureV(t, personalSignTestSignature, 2), ""},
+		{"high s", highS, ""},
+		{"zero s", append(withSignatureV(t, personalSignTestSignature, 28)[:32], append(make([]byte, 32), 28)...), ""},
+		{"too short", withSignatureV(t, personalSignTestSignature, 28)[:64], ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := recoverEthereumAddress(hash, tt.signature)
+			switch tt.want {
+			case "":
+				if err == nil {
+					t.Fatalf("recoverEthereumAddress() = %s, want error", got)
+				}
+			case "other":
+				// 错误的恢复标识恢复出其他地址
+				if err == nil && got == personalSignTestAddress {
+					t.Fatal("翻转 v 后仍恢复出签名者地址")
+				}
+			default:
+				if err != nil || got != tt.want {
+					t.Fatalf("recoverEthereumAddress() = %s, %v, want %s", got, err, tt.want)
+				}
+			}
+		})
+	}
+
+	if _, err := recoverEthereumAddress(hash, highS); err == nil || !strings.Contains(err.Error(), "EIP-2") {
+		t.Fatalf("高 s 签名的错误信息 = %v", err)
+	}
+}
+
+func TestVerifyPersonalSign(t *testing.T) {
+	checksummed := "0x2c7536E3605D9C16a7a3D7b1898e529396a65c23"
+	tests := []struct {
+		name      string
+		message   string
+		signature string
+		address   string
+		ok        bool
+	}{
+		{"known answer", personalSignTestMessage, personalSignTestSignature, personalSignTestAddress, true},
+		{"checksummed address", personalSignTestMessage, personalSignTestSignature, checksummed, true},
+		{"v=0/1 signature", personalSignTestMessage, "0x" + hex.EncodeToString(withSignatureV(t, personalSignTestSignature, 1)), personalSignTestAddress, true},
+		{"without 0x prefix", personalSignTestMessage, strings.TrimPrefix(personalSignTestSignature, "0x"), personalSignTestAddress, true},
+		{"other message", "Some data!", personalSignTestSignature, personalSignTestAddress, false},
+		{"other address", personalSignTestMessage, personalSignTestSignature, "0x0000000000000000000000000000000000000001", false},
+		{"invalid address", personalSignTestMessage, personalSignTestSignature, "2c7536e3605d9c16a7a3d7b1898e529396a65c23", false},
+		{"invalid hex", personalSignTestMessage, "0xzz", personalSignTestAddress, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyPersonalSign(tt.message, tt.signature, tt.address)
+			if (err == nil) != tt.ok {
+				t.Fatalf("verifyPersonalSign() = %v, want ok=%t", err, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// fakeResult 匹配某条 SQL 时返回的结果：查询返回 columns/rows，执行返回 rowsAffected，err 非空时返回错误
+type fakeResult struct {
+	match        string // SQL 中包含此子串即匹配
+	columns      []string
+	rows         [][]driver.Value
+	rowsAffected int64
+	err          error
+}
+
+// fakeDB 记录执行的 SQL（包括 BEGIN/COMMIT/ROLLBACK），按子串返回预设结果。
+// 未匹配的查询返回空结果，未匹配的执行影响 1 行
+type fakeDB struct {
+	mu         sync.Mutex
+	statements []string
+	results    []fakeResult
+}
+
+var (
+	fakeDrivers   = map[string]*fakeDB{}
+	fakeDriversMu sync.Mutex
+)
+
+func init() {
+	sql.Register("fakedb", fakeDriver{})
+}
+
+// newFakeGormDB 创建使用 fakeDB 的 GORM 连接（MySQL 方言）
+func newFakeGormDB(t *testing.T, results ...fakeResult) (*gorm.DB, *fakeDB) {
+	t.Helper()
+	fake := &fakeDB{results: results}
+	fakeDriversMu.Lock()
+	fakeDrivers[t.Name()] = fake
+	fakeDriversMu.Unlock()
+	t.Cleanup(func() {
+		fakeDriversMu.Lock()
+		delete(fakeDrivers, t.Name())
+		fakeDriversMu.Unlock()
+	})
+
+	sqlDB, err := sql.Open("fakedb", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	db, err := gorm.Open(mysql.New(mysql.Config{Conn: sqlDB, SkipInitializeWithVersion: true}), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, fake
+}
+
+// Statements 返回已执行的语句
+func (f *fakeDB) Statements() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.statements...)
+}
+
+// Index 返回第一条包含 substr 的语句的位置，没有时返回 -1
+func (f *fakeDB) Index(substr string) int {
+	for i, stmt := range f.Statements() {
+		if strings.Contains(stmt, substr) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (f *fakeDB) record(stmt string) *fakeResult {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.statements = append(f.statements, stmt)
+	for i := range f.results {
+		if strings.Contains(stmt, f.results[i].match) {
+			return &f.results[i]
+		}
+	}
+	return nil
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	fakeDriversMu.Lock()
+	defer fakeDriversMu.Unlock()
+	fake, ok := fakeDrivers[name]
+	if !ok {
+		return nil, fmt.Errorf("fakedb %q 未注册", name)
+	}
+	return &fakeConn{db: fake}, nil
+}
+
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, fmt.Errorf("fakedb 不支持预处理语句")
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	c.db.record("BEGIN")
+	return fakeTx{db: c.db}, nil
+}
+
+func (c *fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return c.Begin()
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	result := c.db.record(query)
+	if result == nil {
+		return driver.RowsAffected(1), nil
+	}
+	if result.err != nil {
+		return nil, result.err
+	}
+	return driver.RowsAffected(result.rowsAffected), nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	result := c.db.record(query)
+	if result == nil {
+		return &fakeRows{}, nil
+	}
+	if result.err != nil {
+		return nil, result.err
+	}
+	return &fakeRows{columns: result.columns, rows: result.rows}, nil
+}
+
+type fakeTx struct {
+	db *fakeDB
+}
+
+func (tx fakeTx) Commit() error {
+	tx.db.record("COMMIT")
+	return nil
+}
+
+func (tx fakeTx) Rollback() error {
+	tx.db.record("ROLLBACK")
+	return nil
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+	next    int
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.next])
+	r.next++
+	return nil
+}
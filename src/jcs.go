@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// canonicalizeJSON 按 RFC 8785（JSON Canonicalization Scheme, JCS）规范化任意可 JSON 序列化的值：
+// 对象成员按 UTF-16 码元排序，字符串只转义必需字符，数字使用 ECMAScript 的格式
+func canonicalizeJSON(v interface{}) ([]byte, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var generic interface{}
+	if err := decoder.Decode(&generic); err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	if err := writeCanonicalJSON(&b, generic); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func writeCanonicalJSON(b *bytes.Buffer, v interface{}) error {
+	switch value := v.(type) {
+	case nil:
+		b.WriteString("null")
+	case bool:
+		b.WriteString(strconv.FormatBool(value))
+	case string:
+		writeCanonicalString(b, value)
+	case json.Number:
+		f, err := value.Float64()
+		if err != nil {
+			return err
+		}
+		s, err := formatJCSNumber(f)
+		if err != nil {
+			return err
+		}
+		b.WriteString(s)
+	case []interface{}:
+		b.WriteByte('[')
+		for i, item := range value {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			if err := writeCanonicalJSON(b, item); err != nil {
+				return err
+			}
+		}
+		b.WriteByte(']')
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for k := range value {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool { return lessUTF16(keys[i], keys[j]) })
+
+		b.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			writeCanonicalString(b, k)
+			b.WriteByte(':')
+			if err := writeCanonicalJSON(b, value[k]); err != nil {
+				return err
+			}
+		}
+		b.WriteByte('}')
+	default:
+		return fmt.Errorf("JCS不支持的类型 %T", v)
+	}
+	return nil
+}
+
+// writeCanonicalString 只转义引号、反斜杠和控制字符（与 ECMAScript JSON.stringify 一致）
+func writeCanonicalString(b *bytes.Buffer, s string) {
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(b, `\u%04x`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+}
+
+// formatJCSNumber 按 ECMAScript Number.prototype.toString 的规则格式化 IEEE 754 双精度数
+func formatJCSNumber(f float64) (string, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return "", fmt.Errorf("JCS不支持 NaN 和 Infinity")
+	}
+	if f == 0 {
+		return "0", nil
+	}
+	abs := math.Abs(f)
+	if abs >= 1e-6 && abs < 1e21 {
+		return strconv.FormatFloat(f, 'f', -1, 64), nil
+	}
+
+	// 指数形式: Go 输出 1e-07 / 1e+21，ECMAScript 为 1e-7 / 1e+21
+	s := strconv.FormatFloat(f, 'e', -1, 64)
+	mantissa, exponent, _ := strings.Cut(s, "e")
+	sign := exponent[0]
+	exponent = strings.TrimLeft(exponent[1:], "0")
+	return mantissa + "e" + string(sign) + exponent, nil
+}
+
+// lessUTF16 按 UTF-16 码元比较字符串（RFC 8785 3.2.3）
+func lessUTF16(a, b string) bool {
+	ua, ub := utf16.Encode([]rune(a)), utf16.Encode([]rune(b))
+	for i := 0; i < len(ua) && i < len(ub); i++ {
+		if ua[i] != ub[i] {
+			return ua[i] < ub[i]
+		}
+	}
+	return len(ua) < len(ub)
+}
@@ -0,0 +1,404 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 常用 IRI
+const (
+	rdfType         = "http://www.w3.org/1999/02/22-rdf-syntax-ns#type"
+	xsdString       = "http://www.w3.org/2001/XMLSchema#string"
+	xsdBoolean      = "http://www.w3.org/2001/XMLSchema#boolean"
+	xsdInteger      = "http://www.w3.org/2001/XMLSchema#integer"
+	xsdDateTime     = "http://www.w3.org/2001/XMLSchema#dateTime"
+	secVocab        = "https://w3id.org/security#"
+	credentialsBase = "https://www.w3.org/2018/credentials#"
+	statusVocab     = "https://www.w3.org/ns/credentials/status#"
+)
+
+// jsonldTerm 上下文中的术语定义
+type jsonldTerm struct {
+	ID        string         // 展开后的 IRI，或关键字 @id / @type
+	Type      string         // 类型强制：@id、@vocab 或数据类型 IRI，为空表示不强制
+	Container string         // @graph / @set / @list
+	Context   *jsonldContext // 作用域上下文：类型术语为类型作用域，属性术语为属性作用域
+}
+
+// jsonldContext 本地上下文
+type jsonldContext struct {
+	Vocab string
+	Terms map[string]*jsonldTerm
+}
+
+// vcContextV2Terms https://www.w3.org/ns/credentials/v2 中本服务签发的文档用到的定义（内置，不从网络加载）。
+// 只处理平台自己签发的凭证，未列出的术语按 @vocab 展开
+var vcContextV2Terms = &jsonldContext{
+	Vocab: "https://www.w3.org/ns/credentials/issuer-dependent#",
+	Terms: map[string]*jsonldTerm{
+		"id":          {ID: "@id"},
+		"type":        {ID: "@type"},
+		"description": {ID: "https://schema.org/description"},
+		"name":        {ID: "https://schema.org/name"},
+
+		"VerifiableCredential": {
+			ID: credentialsBase + "VerifiableCredential",
+			Context: &jsonldContext{Terms: map[string]*jsonldTerm{
+				"id":                {ID: "@id"},
+				"type":              {ID: "@type"},
+				"credentialSchema":  {ID: credentialsBase + "credentialSchema", Type: "@id"},
+				"credentialStatus":  {ID: credentialsBase + "credentialStatus", Type: "@id"},
+				"credentialSubject": {ID: credentialsBase + "credentialSubject", Type: "@id"},
+				"description":       {ID: "https://schema.org/description"},
+				"evidence":          {ID: credentialsBase + "evidence", Type: "@id"},
+				"issuer":            {ID: credentialsBase + "issuer", Type: "@id"},
+				"name":              {ID: "https://schema.org/name"},
+				"proof":             {ID: secVocab + "proof", Type: "@id", Container: "@graph"},
+				"refreshService":    {ID: credentialsBase + "refreshService", Type: "@id"},
+				"termsOfUse":        {ID: credentialsBase + "termsOfUse", Type: "@id"},
+				"validFrom":         {ID: credentialsBase + "validFrom", Type: xsdDateTime},
+				"validUntil":        {ID: credentialsBase + "validUntil", Type: xsdDateTime},
+			}},
+		},
+
+		"BitstringStatusListCredential": {ID: statusVocab + "BitstringStatusListCredential"},
+		"BitstringStatusList": {
+			ID: statusVocab + "BitstringStatusList",
+			Context: &jsonldContext{Terms: map[string]*jsonldTerm{
+				"id":            {ID: "@id"},
+				"type":          {ID: "@type"},
+				"encodedList":   {ID: statusVocab + "encodedList", Type: secVocab + "multibase"},
+				"statusPurpose": {ID: statusVocab + "statusPurpose"},
+				"ttl":           {ID: statusVocab + "ttl"},
+			}},
+		},
+		"BitstringStatusListEntry": {
+			ID: statusVocab + "BitstringStatusListEntry",
+			Context: &jsonldContext{Terms: map[string]*jsonldTerm{
+				"id":                   {ID: "@id"},
+				"type":                 {ID: "@type"},
+				"statusListCredential": {ID: statusVocab + "statusListCredential", Type: "@id"},
+				"statusListIndex":      {ID: statusVocab + "statusListIndex"},
+				"statusPurpose":        {ID: statusVocab + "statusPurpose"},
+			}},
+		},
+
+		"DataIntegrityProof": {
+			ID: secVocab + "DataIntegrityProof",
+			Context: &jsonldContext{Terms: map[string]*jsonldTerm{
+				"id":          {ID: "@id"},
+				"type":        {ID: "@type"},
+				"challenge":   {ID: secVocab + "challenge"},
+				"created":     {ID: "http://purl.org/dc/terms/created", Type: xsdDateTime},
+				"cryptosuite": {ID: secVocab + "cryptosuite", Type: secVocab + "cryptosuiteString"},
+				"domain":      {ID: secVocab + "domain"},
+				"expires":     {ID: secVocab + "expiration", Type: xsdDateTime},
+				"nonce":       {ID: secVocab + "nonce"},
+				"proofPurpose": {
+					ID:   secVocab + "proofPurpose",
+					Type: "@vocab",
+					Context: &jsonldContext{Terms: map[string]*jsonldTerm{
+						"id":                   {ID: "@id"},
+						"type":                 {ID: "@type"},
+						"assertionMethod":      {ID: secVocab + "assertionMethod", Type: "@id", Container: "@set"},
+						"authentication":       {ID: secVocab + "authenticationMethod", Type: "@id", Container: "@set"},
+						"capabilityInvocation": {ID: secVocab + "capabilityInvocationMethod", Type: "@id", Container: "@set"},
+						"capabilityDelegation": {ID: secVocab + "capabilityDelegationMethod", Type: "@id", Container: "@set"},
+						"keyAgreement":         {ID: secVocab + "keyAgreementMethod", Type: "@id", Container: "@set"},
+					}},
+				},
+				"proofValue":         {ID: secVocab + "proofValue", Type: secVocab + "multibase"},
+				"verificationMethod": {ID: secVocab + "verificationMethod", Type: "@id"},
+			}},
+		},
+	},
+}
+
+// jsonldActiveContext 处理过程中的活动上下文
+type jsonldActiveContext struct {
+	vocab    string
+	terms    map[string]*jsonldTerm
+	previous *jsonldActiveContext // 类型作用域上下文不向嵌套节点传播，进入嵌套节点时恢复为此上下文
+}
+
+// apply 在活动上下文上叠加本地上下文；propagate 为 false 时（类型作用域）记录叠加前的上下文
+func (a *jsonldActiveContext) apply(local *jsonldContext, propagate bool) *jsonldActiveContext {
+	if local == nil {
+		return a
+	}
+	result := &jsonldActiveContext{vocab: a.vocab, terms: make(map[string]*jsonldTerm, len(a.terms)+len(local.Terms)), previous: a.previous}
+	for name, term := range a.terms {
+		result.terms[name] = term
+	}
+	for name, term := range local.Terms {
+		result.terms[name] = term
+	}
+	if local.Vocab != "" {
+		result.vocab = local.Vocab
+	}
+	if !propagate && result.previous == nil {
+		result.previous = a
+	}
+	return result
+}
+
+// expandVocab 按词汇表展开（属性名、类型、@vocab 类型的值）：术语、绝对 IRI 或 @vocab 前缀
+func (a *jsonldActiveContext) expandVocab(value string) (string, error) {
+	if term, ok := a.terms[value]; ok {
+		return term.ID, nil
+	}
+	if isAbsoluteIRI(value) {
+		return value, nil
+	}
+	if a.vocab == "" {
+		return "", fmt.Errorf("无法展开术语 %q", value)
+	}
+	return a.vocab + value, nil
+}
+
+// isAbsoluteIRI 是否为带 scheme 的绝对 IRI（如 https:、did:、urn:）
+func isAbsoluteIRI(value string) bool {
+	i := strings.Index(value, ":")
+	if i <= 0 {
+		return false
+	}
+	for j, r := range value[:i] {
+		isAlpha := (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+		if !isAlpha && (j == 0 || !(r >= '0' && r <= '9' || r == '+' || r == '-' || r == '.')) {
+			return false
+		}
+	}
+	return true
+}
+
+// jsonldToRDF 将使用 VC 2.0 上下文的 JSON-LD 文档转换为 RDF 数据集（JSON-LD 1.1 Deserialize JSON-LD to RDF）。
+// 只支持本服务签发的文档结构：@context 必须为 VC 2.0 上下文，不支持 @graph、@list 和值对象
+func jsonldToRDF(document interface{}) ([]rdfQuad, error) {
+	raw, err := json.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var generic map[string]interface{}
+	if err := decoder.Decode(&generic); err != nil {
+		return nil, fmt.Errorf("文档必须是 JSON 对象: %v", err)
+	}
+	if err := checkVCContext(generic["@context"]); err != nil {
+		return nil, err
+	}
+
+	c := &jsonldConverter{}
+	active := (&jsonldActiveContext{}).apply(vcContextV2Terms, true)
+	if _, err := c.node(generic, active); err != nil {
+		return nil, err
+	}
+	return c.quads, nil
+}
+
+// checkVCContext 只接受 VC 2.0 上下文（字符串或只含它的数组）
+func checkVCContext(context interface{}) error {
+	switch ctx := context.(type) {
+	case string:
+		if ctx == vcContextV2 {
+			return nil
+		}
+	case []interface{}:
+		if len(ctx) == 1 && ctx[0] == vcContextV2 {
+			return nil
+		}
+	}
+	return fmt.Errorf("不支持的 @context: %v", context)
+}
+
+// jsonldConverter 生成三元组（均在默认图中）
+type jsonldConverter struct {
+	quads      []rdfQuad
+	blankNodes int
+}
+
+func (c *jsonldConverter) newBlankNode() rdfTerm {
+	c.blankNodes++
+	return rdfTerm{Kind: rdfBlankNode, Value: "b" + strconv.Itoa(c.blankNodes-1)}
+}
+
+// node 处理一个节点对象，返回其主语
+func (c *jsonldConverter) node(obj map[string]interface{}, active *jsonldActiveContext) (rdfTerm, error) {
+	// 类型：使用叠加类型作用域上下文之前的上下文展开，并按字典序叠加各类型的作用域上下文
+	var types []string
+	for key, value := range obj {
+		if key == "@context" || c.keyword(active, key) != "@type" {
+			continue
+		}
+		values, ok := value.([]interface{})
+		if !ok {
+			values = []interface{}{value}
+		}
+		for _, v := range values {
+			s, ok := v.(string)
+			if !ok {
+				return rdfTerm{}, fmt.Errorf("type 必须是字符串")
+			}
+			types = append(types, s)
+		}
+	}
+	sort.Strings(types)
+	typeScoped := active
+	for _, t := range types {
+		if term, ok := active.terms[t]; ok && term.Context != nil {
+			typeScoped = typeScoped.apply(term.Context, false)
+		}
+	}
+
+	subject := rdfTerm{}
+	for key, value := range obj {
+		if key == "@context" || c.keyword(typeScoped, key) != "@id" {
+			continue
+		}
+		id, ok := value.(string)
+		if !ok {
+			return rdfTerm{}, fmt.Errorf("id 必须是字符串")
+		}
+		term, err := iriOrBlankNode(id)
+		if err != nil {
+			return rdfTerm{}, err
+		}
+		subject = term
+	}
+	if subject.Kind == "" {
+		subject = c.newBlankNode()
+	}
+
+	for _, t := range types {
+		iri, err := active.expandVocab(t)
+		if err != nil {
+			return rdfTerm{}, err
+		}
+		c.quads = append(c.quads, rdfQuad{Subject: subject, Predicate: rdfTerm{Kind: rdfIRI, Value: rdfType}, Object: rdfTerm{Kind: rdfIRI, Value: iri}})
+	}
+
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if key == "@context" {
+			continue
+		}
+		switch c.keyword(typeScoped, key) {
+		case "@id", "@type":
+			continue
+		}
+		if strings.HasPrefix(key, "@") {
+			return rdfTerm{}, fmt.Errorf("不支持的关键字 %s", key)
+		}
+		term := typeScoped.terms[key]
+		if term == nil {
+			iri, err := typeScoped.expandVocab(key)
+			if err != nil {
+				return rdfTerm{}, err
+			}
+			term = &jsonldTerm{ID: iri}
+		}
+		if term.Container == "@graph" || term.Container == "@list" {
+			return rdfTerm{}, fmt.Errorf("不支持 %s 容器（属性 %s）", term.Container, key)
+		}
+		if err := c.property(subject, term, obj[key], typeScoped); err != nil {
+			return rdfTerm{}, fmt.Errorf("属性 %s: %v", key, err)
+		}
+	}
+	return subject, nil
+}
+
+// keyword 键在活动上下文中映射到的关键字（@id / @type），不是关键字时返回空
+func (c *jsonldConverter) keyword(active *jsonldActiveContext, key string) string {
+	if key == "@id" || key == "@type" {
+		return key
+	}
+	if term, ok := active.terms[key]; ok && strings.HasPrefix(term.ID, "@") {
+		return term.ID
+	}
+	return ""
+}
+
+// property 为属性的每个值生成三元组
+func (c *jsonldConverter) property(subject rdfTerm, term *jsonldTerm, value interface{}, active *jsonldActiveContext) error {
+	predicate := rdfTerm{Kind: rdfIRI, Value: term.ID}
+	values, ok := value.([]interface{})
+	if !ok {
+		values = []interface{}{value}
+	}
+	for _, v := range values {
+		if v == nil {
+			continue
+		}
+		object, err := c.value(term, v, active)
+		if err != nil {
+			return err
+		}
+		c.quads = append(c.quads, rdfQuad{Subject: subject, Predicate: predicate, Object: object})
+	}
+	return nil
+}
+
+// value 将属性值转换为 RDF 项：嵌套节点、IRI 或字面量
+func (c *jsonldConverter) value(term *jsonldTerm, v interface{}, active *jsonldActiveContext) (rdfTerm, error) {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		if _, ok := value["@value"]; ok {
+			return rdfTerm{}, fmt.Errorf("不支持值对象")
+		}
+		// 嵌套节点不继承类型作用域上下文，但应用属性作用域上下文
+		nested := active
+		if nested.previous != nil {
+			nested = nested.previous
+		}
+		return c.node(value, nested.apply(term.Context, true))
+	case string:
+		switch term.Type {
+		case "@id":
+			return iriOrBlankNode(value)
+		case "@vocab":
+			iri, err := active.apply(term.Context, true).expandVocab(value)
+			if err != nil {
+				return rdfTerm{}, err
+			}
+			return rdfTerm{Kind: rdfIRI, Value: iri}, nil
+		}
+		return literal(value, term.Type, xsdString), nil
+	case bool:
+		return literal(strconv.FormatBool(value), term.Type, xsdBoolean), nil
+	case json.Number:
+		f, err := value.Float64()
+		if err != nil || f != math.Trunc(f) || math.Abs(f) >= 1e21 {
+			return rdfTerm{}, fmt.Errorf("不支持的数值 %s（只支持整数）", value)
+		}
+		return literal(strconv.FormatFloat(f, 'f', -1, 64), term.Type, xsdInteger), nil
+	}
+	return rdfTerm{}, fmt.Errorf("不支持的值 %v", v)
+}
+
+// literal 字面量：有类型强制时使用强制的数据类型
+func literal(lexical string, coerced string, datatype string) rdfTerm {
+	if coerced != "" && coerced != "@id" && coerced != "@vocab" {
+		datatype = coerced
+	}
+	return rdfTerm{Kind: rdfLiteral, Value: lexical, Datatype: datatype}
+}
+
+// iriOrBlankNode 节点标识：_: 开头为空白节点，否则必须为绝对 IRI（没有基础 IRI 可用于解析相对 IRI）
+func iriOrBlankNode(id string) (rdfTerm, error) {
+	if strings.HasPrefix(id, "_:") {
+		return rdfTerm{Kind: rdfBlankNode, Value: "x" + id[2:]}, nil
+	}
+	if !isAbsoluteIRI(id) {
+		return rdfTerm{}, fmt.Errorf("%q 不是绝对 IRI", id)
+	}
+	return rdfTerm{Kind: rdfIRI, Value: id}, nil
+}
@@ -0,0 +1,468 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cosmos-link/did-login/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// 平台签名算法（JOSE 名称）
+const (
+	signingAlgES256 = "ES256"
+	signingAlgEdDSA = "EdDSA"
+)
+
+// 平台 DID 文档使用的 JsonWebKey2020 上下文
+const didContextJWS2020 = "https://w3id.org/security/suites/jws-2020/v1"
+
+// signingKeyMetadata 密钥元数据，保存在私钥旁的 <kid>.json 中
+// 创建时间决定哪个密钥是当前密钥，不能取文件修改时间：复制、备份恢复后修改时间会改变
+type signingKeyMetadata struct {
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// signingKey 平台签名密钥，kid 即密钥文件名（不含 .pem）
+type signingKey struct {
+	ID        string
+	Algorithm string
+	Signer    crypto.Signer
+	CreatedAt time.Time
+	RetiredAt time.Time // 被新密钥取代的时间，零值表示未退役
+}
+
+// PublicJWK 公钥的 JWK 表示（RFC 7517），包含 kid、alg 和 use
+func (k *signingKey) PublicJWK() map[string]interface{} {
+	jwk := publicKeyJWK(k.Signer.Public())
+	jwk["kid"] = k.ID
+	jwk["alg"] = k.Algorithm
+	jwk["use"] = "sig"
+	return jwk
+}
+
+// signingMethod 密钥对应的 JWT 签名算法
+func (k *signingKey) signingMethod() jwt.SigningMethod {
+	if k.Algorithm == signingAlgEdDSA {
+		return jwt.SigningMethodEdDSA
+	}
+	return jwt.SigningMethodES256
+}
+
+// SignJWT 签发 JWT，头部 kid 默认为密钥ID，可通过 header 覆盖或追加（如 typ）
+func (k *signingKey) SignJWT(claims jwt.Claims, header map[string]interface{}) (string, error) {
+	token := jwt.NewWithClaims(k.signingMethod(), claims)
+	token.Header["kid"] = k.ID
+	for name, value := range header {
+		token.Header[name] = value
+	}
+	return token.SignedString(k.Signer)
+}
+
+// Sign 对消息签名：ES256 为 SHA-256 后的 ECDSA 签名（r || s 各 32 字节），EdDSA 直接签名消息
+func (k *signingKey) Sign(message []byte) ([]byte, error) {
+	switch priv := k.Signer.(type) {
+	case *ecdsa.PrivateKey:
+		digest := sha256.Sum256(message)
+		r, s, err := ecdsa.Sign(rand.Reader, priv, digest[:])
+		if err != nil {
+			return nil, err
+		}
+		sig := make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+		return sig, nil
+	case ed25519.PrivateKey:
+		return ed25519.Sign(priv, message), nil
+	}
+	return nil, fmt.Errorf("不支持的密钥类型 %T", k.Signer)
+}
+
+// publicKeyJWK 只包含 RFC 7638 指纹所需成员的公钥 JWK
+func publicKeyJWK(pub crypto.PublicKey) map[string]interface{} {
+	switch key := pub.(type) {
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		return map[string]interface{}{
+			"kty": "EC",
+			"crv": key.Curve.Params().Name,
+			"x":   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, size))),
+			"y":   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, size))),
+		}
+	case ed25519.PublicKey:
+		return map[string]interface{}{
+			"kty": "OKP",
+			"crv": "Ed25519",
+			"x":   base64.RawURLEncoding.EncodeToString(key),
+		}
+	}
+	return nil
+}
+
+// jwkThumbprint RFC 7638 JWK 指纹（SHA-256，base64url），用作新生成密钥的 kid
+func jwkThumbprint(pub crypto.PublicKey) string {
+	jwk := publicKeyJWK(pub)
+	var canonical string
+	if jwk["kty"] == "EC" {
+		canonical = fmt.Sprintf(`{"crv":"%s","kty":"EC","x":"%s","y":"%s"}`, jwk["crv"], jwk["x"], jwk["y"])
+	} else {
+		canonical = fmt.Sprintf(`{"crv":"%s","kty":"OKP","x":"%s"}`, jwk["crv"], jwk["x"])
+	}
+	sum := sha256.Sum256([]byte(canonical))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// keyStore 本地密钥库：目录中每个 <kid>.pem 是一个 PKCS#8 私钥，<kid>.json 记录其创建时间
+// current 用于签名，previous 只用于验证（发布在 JWKS 和 DID 文档中，便于依赖方验证轮换前签发的令牌）
+type keyStore struct {
+	mu        sync.RWMutex
+	dir       string
+	algorithm string        // 密钥库为空或轮换时生成的密钥类型
+	retention time.Duration // 退役密钥继续发布的宽限期，0 表示一直发布
+	current   *signingKey
+	previous  []*signingKey
+
+	currentKeyID string    // 最近一次 Load 指定的当前密钥
+	loadedAt     time.Time // 最近一次 Load 的时间
+}
+
+// 遇到未知 kid 时重新读取密钥目录的最小间隔（防止伪造 kid 的请求反复读盘）
+const keyStoreReloadInterval = 30 * time.Second
+
+// 全局平台密钥库（签发凭证，退役密钥一直保留，保证已签发的凭证可以验证）
+var platformKeys = newKeyStore(config.ISSUER_KEYSTORE_PATH, config.ISSUER_KEY_ALGORITHM, 0)
+
+func newKeyStore(dir string, algorithm string, retention time.Duration) *keyStore {
+	return &keyStore{dir: dir, algorithm: algorithm, retention: retention}
+}
+
+// initKeyStore 加载平台签名密钥；密钥库为空时生成一个新密钥
+func initKeyStore() {
+	if err := platformKeys.Load(config.ISSUER_CURRENT_KEY_ID); err != nil {
+		fmt.Printf("Warning: 加载平台签名密钥失败: %v\n", err)
+		return
+	}
+	current := platformKeys.Current()
+	fmt.Printf("✅ 平台 DID %s，当前签名密钥 %s（%s），共 %d 个验证密钥\n",
+		config.ISSUER_DID, current.ID, current.Algorithm, len(platformKeys.VerificationKeys()))
+}
+
+// Load 读取密钥目录。currentKeyID 为空时使用最新创建的密钥签名，其余密钥作为历史验证密钥
+// 比当前密钥旧的密钥视为在下一个（更新的）密钥创建时退役，超过宽限期的密钥会被删除
+func (s *keyStore) Load(currentKeyID string) error {
+	if s.dir == "" {
+		return fmt.Errorf("未配置密钥库目录")
+	}
+	keys, err := readSigningKeys(s.dir)
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		key, err := generateSigningKey(s.dir, s.algorithm)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("密钥库为空，已生成新的签名密钥 %s\n", key.ID)
+		keys = append(keys, key)
+	}
+
+	// 按创建时间从新到旧排列
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.After(keys[j].CreatedAt) })
+	currentIndex := 0
+	if currentKeyID != "" {
+		currentIndex = -1
+		for i, key := range keys {
+			if key.ID == currentKeyID {
+				currentIndex = i
+				break
+			}
+		}
+		if currentIndex < 0 {
+			return fmt.Errorf("密钥库中不存在当前密钥 %s", currentKeyID)
+		}
+	}
+	for i := currentIndex + 1; i < len(keys); i++ {
+		keys[i].RetiredAt = keys[i-1].CreatedAt
+	}
+
+	previous := make([]*signingKey, 0, len(keys)-1)
+	previous = append(previous, keys[:currentIndex]...)
+	previous = append(previous, keys[currentIndex+1:]...)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.current = keys[currentIndex]
+	s.previous = previous
+	s.currentKeyID = currentKeyID
+	s.loadedAt = time.Now()
+	s.pruneLocked(s.loadedAt)
+	return nil
+}
+
+// Rotate 生成新密钥作为当前签名密钥，原密钥退役，宽限期内继续用于验证
+func (s *keyStore) Rotate() (*signingKey, error) {
+	key, err := generateSigningKey(s.dir, s.algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current != nil {
+		// 复制后修改，避免与持有旧指针的读者竞争
+		retired := *s.current
+		retired.RetiredAt = key.CreatedAt
+		s.previous = append([]*signingKey{&retired}, s.previous...)
+	}
+	s.current = key
+	s.pruneLocked(key.CreatedAt)
+	return key, nil
+}
+
+// expired 退役密钥是否已超过宽限期
+func (s *keyStore) expired(key *signingKey, now time.Time) bool {
+	return s.retention > 0 && !key.RetiredAt.IsZero() && !now.Before(key.RetiredAt.Add(s.retention))
+}
+
+// pruneLocked 移除并删除超过宽限期的退役密钥，调用方持有写锁
+func (s *keyStore) pruneLocked(now time.Time) {
+	kept := s.previous[:0]
+	for _, key := range s.previous {
+		if !s.expired(key, now) {
+			kept = append(kept, key)
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.dir, key.ID+".pem")); err != nil && !os.IsNotExist(err) {
+			fmt.Printf("Warning: 删除过期密钥 %s 失败: %v\n", key.ID, err)
+			continue
+		}
+		os.Remove(filepath.Join(s.dir, key.ID+".json"))
+		fmt.Printf("密钥 %s 已超过宽限期，不再发布\n", key.ID)
+	}
+	s.previous = kept
+}
+
+// Current 当前签名密钥
+func (s *keyStore) Current() *signingKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current
+}
+
+// VerificationKeys 所有可用于验证的密钥（当前密钥在前，不含超过宽限期的退役密钥）
+func (s *keyStore) VerificationKeys() []*signingKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.current == nil {
+		return nil
+	}
+	now := time.Now()
+	keys := []*signingKey{s.current}
+	for _, key := range s.previous {
+		if !s.expired(key, now) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Lookup 按 kid 查找验证密钥
+func (s *keyStore) Lookup(kid string) *signingKey {
+	for _, key := range s.VerificationKeys() {
+		if key.ID == kid {
+			return key
+		}
+	}
+	return nil
+}
+
+// LookupOrReload 按 kid 查找验证密钥；找不到时重新读取密钥目录后再查找一次。
+// 多副本共享密钥库时，其他副本刚轮换的密钥不必等到下一次定时重新加载就能验证
+func (s *keyStore) LookupOrReload(kid string) *signingKey {
+	if key := s.Lookup(kid); key != nil || kid == "" {
+		return key
+	}
+	s.mu.RLock()
+	currentKeyID, stale := s.currentKeyID, time.Since(s.loadedAt) >= keyStoreReloadInterval
+	s.mu.RUnlock()
+	if !stale {
+		return nil
+	}
+	if err := s.Load(currentKeyID); err != nil {
+		fmt.Printf("Warning: 重新加载密钥库 %s 失败: %v\n", s.dir, err)
+		return nil
+	}
+	return s.Lookup(kid)
+}
+
+// readSigningKeys 读取目录中的所有 *.pem 私钥
+func readSigningKeys(dir string) ([]*signingKey, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	var keys []*signingKey
+	for _, file := range files {
+		key, err := readSigningKey(file)
+		if err != nil {
+			return nil, fmt.Errorf("读取密钥 %s 失败: %v", file, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func readSigningKey(file string) (*signingKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("不是 PKCS#8 PEM 私钥")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	createdAt, err := readSigningKeyCreatedAt(file)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &signingKey{
+		ID:        strings.TrimSuffix(filepath.Base(file), ".pem"),
+		CreatedAt: createdAt,
+	}
+	switch priv := parsed.(type) {
+	case *ecdsa.PrivateKey:
+		if priv.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("只支持 P-256 曲线")
+		}
+		key.Algorithm, key.Signer = signingAlgES256, priv
+	case ed25519.PrivateKey:
+		key.Algorithm, key.Signer = signingAlgEdDSA, priv
+	default:
+		return nil, fmt.Errorf("不支持的密钥类型 %T", parsed)
+	}
+	return key, nil
+}
+
+// readSigningKeyCreatedAt 从 <kid>.json 读取密钥创建时间
+// 没有元数据的旧密钥使用文件修改时间，并写入元数据，之后不再依赖修改时间
+func readSigningKeyCreatedAt(pemFile string) (time.Time, error) {
+	metaFile := strings.TrimSuffix(pemFile, ".pem") + ".json"
+	data, err := os.ReadFile(metaFile)
+	if err == nil {
+		var meta signingKeyMetadata
+		if err := json.Unmarshal(data, &meta); err != nil || meta.CreatedAt.IsZero() {
+			return time.Time{}, fmt.Errorf("密钥元数据 %s 无效", metaFile)
+		}
+		return meta.CreatedAt, nil
+	}
+	if !os.IsNotExist(err) {
+		return time.Time{}, err
+	}
+
+	info, err := os.Stat(pemFile)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if err := writeSigningKeyMetadata(metaFile, info.ModTime()); err != nil {
+		fmt.Printf("Warning: 写入密钥元数据 %s 失败: %v\n", metaFile, err)
+	}
+	return info.ModTime(), nil
+}
+
+func writeSigningKeyMetadata(metaFile string, createdAt time.Time) error {
+	data, err := json.Marshal(signingKeyMetadata{CreatedAt: createdAt.UTC()})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(metaFile, data, 0600)
+}
+
+// generateSigningKey 生成新密钥并以 <JWK指纹>.pem 保存到密钥库
+func generateSigningKey(dir string, algorithm string) (*signingKey, error) {
+	var signer crypto.Signer
+	var err error
+	switch algorithm {
+	case signingAlgES256:
+		signer, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case signingAlgEdDSA:
+		_, signer, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("不支持的签名算法: %s", algorithm)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(signer)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	kid := jwkThumbprint(signer.Public())
+	createdAt := time.Now()
+	// 先写元数据：其他副本读到私钥时已能取得创建时间
+	if err := writeSigningKeyMetadata(filepath.Join(dir, kid+".json"), createdAt); err != nil {
+		return nil, err
+	}
+	file := filepath.Join(dir, kid+".pem")
+	if err := os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		return nil, err
+	}
+	return &signingKey{ID: kid, Algorithm: algorithm, Signer: signer, CreatedAt: createdAt}, nil
+}
+
+// platformJWKS 平台公钥集合（/.well-known/jwks.json）：凭证签名密钥和登录令牌签名密钥
+func platformJWKS() map[string]interface{} {
+	keys := []interface{}{}
+	for _, key := range platformKeys.VerificationKeys() {
+		keys = append(keys, key.PublicJWK())
+	}
+	for _, key := range tokenKeys.VerificationKeys() {
+		keys = append(keys, key.PublicJWK())
+	}
+	return map[string]interface{}{"keys": keys}
+}
+
+// platformDIDDocument 平台签发者的 DID 文档（/.well-known/did.json）
+// 每个验证密钥对应一个 JsonWebKey2020 验证方法，ID 为 <平台DID>#<kid>
+func platformDIDDocument() *didDocument {
+	did := config.ISSUER_DID
+	doc := &didDocument{
+		Context: []string{didContextV1, didContextJWS2020},
+		ID:      did,
+	}
+	for _, key := range platformKeys.VerificationKeys() {
+		id := did + "#" + key.ID
+		doc.VerificationMethod = append(doc.VerificationMethod, verificationMethod{
+			ID:           id,
+			Type:         "JsonWebKey2020",
+			Controller:   did,
+			PublicKeyJwk: key.PublicJWK(),
+		})
+		doc.AssertionMethod = append(doc.AssertionMethod, id)
+		doc.Authentication = append(doc.Authentication, id)
+	}
+	return doc
+}
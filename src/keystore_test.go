@@ -0,0 +1,141 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestKeyStoreOrdersKeysByStoredCreationTime(t *testing.T) {
+	dir := t.TempDir()
+	older, err := generateSigningKey(dir, signingAlgES256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newer, err := generateSigningKey(dir, signingAlgEdDSA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeSigningKeyMetadata(filepath.Join(dir, older.ID+".json"), time.Now().Add(-48*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	// 模拟复制或备份恢复：旧密钥文件的修改时间比新密钥更晚
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, older.ID+".pem"), future, future); err != nil {
+		t.Fatal(err)
+	}
+
+	store := newKeyStore(dir, signingAlgES256, 0)
+	if err := store.Load(""); err != nil {
+		t.Fatal(err)
+	}
+	if got := store.Current().ID; got != newer.ID {
+		t.Fatalf("当前密钥 = %s, want %s", got, newer.ID)
+	}
+	if keys := store.VerificationKeys(); len(keys) != 2 || keys[1].ID != older.ID {
+		t.Fatalf("验证密钥不正确: %v", keys)
+	}
+}
+
+func TestKeyStoreBackfillsMetadataForLegacyKeys(t *testing.T) {
+	dir := t.TempDir()
+	key, err := generateSigningKey(dir, signingAlgES256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	metaFile := filepath.Join(dir, key.ID+".json")
+	if err := os.Remove(metaFile); err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Now().Add(-24 * time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(filepath.Join(dir, key.ID+".pem"), modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := readSigningKey(filepath.Join(dir, key.ID+".pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.CreatedAt.Equal(modTime) {
+		t.Fatalf("CreatedAt = %s, want %s", loaded.CreatedAt, modTime)
+	}
+	if _, err := os.Stat(metaFile); err != nil {
+		t.Fatalf("未写入密钥元数据: %v", err)
+	}
+
+	// 之后修改时间变化不再影响创建时间
+	now := time.Now()
+	if err := os.Chtimes(filepath.Join(dir, key.ID+".pem"), now, now); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err = readSigningKey(filepath.Join(dir, key.ID+".pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.CreatedAt.Equal(modTime) {
+		t.Fatalf("CreatedAt = %s, want %s", loaded.CreatedAt, modTime)
+	}
+}
+
+func TestKeyStorePruneRemovesMetadata(t *testing.T) {
+	dir := t.TempDir()
+	store := newKeyStore(dir, signingAlgES256, time.Hour)
+	if err := store.Load(""); err != nil {
+		t.Fatal(err)
+	}
+	old := store.Current()
+	if err := writeSigningKeyMetadata(filepath.Join(dir, old.ID+".json"), time.Now().Add(-72*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := generateSigningKey(dir, signingAlgES256); err != nil {
+		t.Fatal(err)
+	}
+	// 新密钥创建于现在，旧密钥从现在起退役，宽限期内仍保留
+	if err := store.Load(""); err != nil {
+		t.Fatal(err)
+	}
+	if store.Lookup(old.ID) == nil {
+		t.Fatal("宽限期内的退役密钥被移除")
+	}
+
+	store.mu.Lock()
+	store.pruneLocked(time.Now().Add(2 * time.Hour))
+	store.mu.Unlock()
+	for _, ext := range []string{".pem", ".json"} {
+		if _, err := os.Stat(filepath.Join(dir, old.ID+ext)); !os.IsNotExist(err) {
+			t.Errorf("过期密钥文件 %s 未删除", old.ID+ext)
+		}
+	}
+}
+
+func TestKeyStoreLookupOrReloadFindsKeyRotatedByAnotherReplica(t *testing.T) {
+	dir := t.TempDir()
+	replicaA := newKeyStore(dir, signingAlgES256, time.Hour)
+	replicaB := newKeyStore(dir, signingAlgES256, time.Hour)
+	if err := replicaA.Load(""); err != nil {
+		t.Fatal(err)
+	}
+	if err := replicaB.Load(""); err != nil {
+		t.Fatal(err)
+	}
+	rotated, err := replicaA.Rotate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 刚加载过，不重新读取目录
+	if replicaB.LookupOrReload(rotated.ID) != nil {
+		t.Fatal("重新加载间隔内不应读取目录")
+	}
+
+	replicaB.mu.Lock()
+	replicaB.loadedAt = time.Now().Add(-keyStoreReloadInterval)
+	replicaB.mu.Unlock()
+	if replicaB.LookupOrReload(rotated.ID) == nil {
+		t.Fatal("未找到其他副本轮换的密钥")
+	}
+	if replicaB.Current().ID != rotated.ID {
+		t.Fatalf("当前密钥 = %s, want %s", replicaB.Current().ID, rotated.ID)
+	}
+}
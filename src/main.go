@@ -1,35 +1,30 @@
 package main
 
 import (
+	"bytes"
 	"crypto/rand"
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/cosmos-link/did-login/config"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 )
 
 var DB *gorm.DB
-var jwtKey = []byte("your_secret_key_2026") // 实际生产请用环境变量
-
-// 存储WebAuthn挑战的临时map (生产环境应该使用Redis)
-var challenges = make(map[string]string)
-
-// JWT 载荷
-type Claims struct {
-	DID      string `json:"did"`
-	UserType string `json:"user_type"`
-	jwt.RegisteredClaims
-}
 
 // 生成随机挑战 - 返回base64url格式（无padding）
 func generateChallenge() string {
@@ -40,8 +35,8 @@ func generateChallenge() string {
 	return strings.TrimRight(encoded, "=")
 }
 
-// 验证WebAuthn注册
-func verifyRegistration(clientDataJSON, attestationObject string, expectedChallenge string) ([]byte, error) {
+// 验证WebAuthn注册，并按证明策略校验认证器的证明语句
+func verifyRegistration(clientDataJSON, attestationObject string, expectedChallenge string, policy userTypePolicy) (*authenticatorData, *attestationResult, error) {
 	// 解析clientDataJSON - 处理base64url格式（可能缺少padding）
 	// 添加padding以确保能正确解码
 	decodedStr := clientDataJSON
@@ -57,69 +52,112 @@ func verifyRegistration(clientDataJSON, attestationObject string, expectedChalle
 		// 如果URLEncoding失败，尝试StdEncoding
 		clientDataBytes, err = base64.StdEncoding.DecodeString(decodedStr)
 		if err != nil {
-			return nil, fmt.Errorf("解析clientDataJSON失败: %v", err)
+			return nil, nil, fmt.Errorf("解析clientDataJSON失败: %v", err)
 		}
 	}
 
 	var clientData struct {
-		Type      string `json:"type"`
-		Challenge string `json:"challenge"`
-		Origin    string `json:"origin"`
+		Type        string `json:"type"`
+		Challenge   string `json:"challenge"`
+		Origin      string `json:"origin"`
+		CrossOrigin bool   `json:"crossOrigin"`
 	}
 
 	if err := json.Unmarshal(clientDataBytes, &clientData); err != nil {
-		return nil, fmt.Errorf("解析clientData失败: %v", err)
+		return nil, nil, fmt.Errorf("解析clientData失败: %v", err)
 	}
 
 	// 验证类型
 	if clientData.Type != "webauthn.create" {
-		return nil, fmt.Errorf("无效的类型: %s", clientData.Type)
+		return nil, nil, fmt.Errorf("无效的类型: %s", clientData.Type)
 	}
 
 	// 验证挑战
 	if clientData.Challenge != expectedChallenge {
 		fmt.Printf("【注册】Challenge不匹配! 从clientData获得: %s (长度%d), 预期: %s (长度%d)\n", clientData.Challenge, len(clientData.Challenge), expectedChallenge, len(expectedChallenge))
-		return nil, fmt.Errorf("挑战不匹配")
+		return nil, nil, fmt.Errorf("挑战不匹配")
 	}
 	fmt.Printf("【注册】Challenge验证成功 ✓\n")
 
-	// 验证来源 - 支持localhost和生产环境
+	// 验证来源 - 必须在配置的允许列表中，且不允许跨域 iframe 调用
 	if clientData.Origin == "" {
-		return nil, fmt.Errorf("来源不能为空")
+		return nil, nil, fmt.Errorf("来源不能为空")
 	}
-	// 基本的来源格式验证，允许http/https协议
-	if !strings.HasPrefix(clientData.Origin, "http://") && !strings.HasPrefix(clientData.Origin, "https://") {
-		return nil, fmt.Errorf("无效的来源协议: %s", clientData.Origin)
+	rpId, err := rpIDForOrigin(clientData.Origin)
+	if err != nil {
+		return nil, nil, err
+	}
+	if clientData.CrossOrigin {
+		return nil, nil, fmt.Errorf("不允许跨域调用")
 	}
 
-	// 解析 attestationObject 提取公钥（简化处理）
-	// 实际生产环境应该完整解析 CBOR 格式
-	// 这里我们返回原始的 attestationObject 作为公钥存储
-	
-	// 处理 base64url padding
-	attestationStr := attestationObject
-	switch len(attestationObject) % 4 {
-	case 2:
-		attestationStr += "=="
-	case 3:
-		attestationStr += "="
+	// 解析 attestationObject（CBOR）并从 authData 中提取凭证公钥
+	attestationBytes, err := decodeWebAuthnBase64(attestationObject)
+	if err != nil {
+		return nil, nil, fmt.Errorf("解析attestationObject失败: %v", err)
 	}
-	
-	attestationBytes, err := base64.URLEncoding.DecodeString(attestationStr)
+
+	att, authData, err := parseAttestationObject(attestationBytes)
 	if err != nil {
-		// 尝试 StdEncoding
-		attestationBytes, err = base64.StdEncoding.DecodeString(attestationStr)
-		if err != nil {
-			return nil, fmt.Errorf("解析attestationObject失败: %v", err)
-		}
+		return nil, nil, err
 	}
-	
-	fmt.Printf("【注册】成功提取公钥信息 (长度: %d bytes) ✓\n", len(attestationBytes))
-	return attestationBytes, nil
+
+	// 验证RP ID Hash
+	expectedRpIdHash := sha256.Sum256([]byte(rpId))
+	if !bytes.Equal(authData.RPIDHash, expectedRpIdHash[:]) {
+		return nil, nil, fmt.Errorf("RP ID Hash不匹配: 期望=%s, 实际Origin=%s", rpId, clientData.Origin)
+	}
+
+	// 检查用户存在标志位
+	if !authData.UserPresent() {
+		return nil, nil, fmt.Errorf("用户不存在")
+	}
+
+	// 策略要求用户验证时检查UV标志位
+	if policy.requiresUserVerification() && !authData.UserVerified() {
+		return nil, nil, fmt.Errorf("认证器未完成用户验证")
+	}
+	if err := policy.checkBackupFlags(authData); err != nil {
+		return nil, nil, err
+	}
+
+	if authData.AttestedCredential == nil {
+		return nil, nil, fmt.Errorf("authData中缺少凭证数据")
+	}
+	key, err := parseCOSEKey(authData.AttestedCredential.CredentialPublicKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := key.PublicKey(); err != nil {
+		return nil, nil, err
+	}
+	if !slices.Contains(parseCOSEAlgorithms(config.WEBAUTHN_ALGORITHMS), key.Alg) {
+		return nil, nil, fmt.Errorf("不接受的签名算法: %d", key.Alg)
+	}
+
+	fmt.Printf("【注册】成功提取COSE公钥 (长度: %d bytes, signCount: %d) ✓\n", len(authData.AttestedCredential.CredentialPublicKey), authData.SignCount)
+
+	// 验证证明语句（签名为 authData || SHA256(clientDataJSON)）
+	clientDataHash := sha256.Sum256(clientDataBytes)
+	result, err := verifyAttestationStatement(att, authData, clientDataHash[:])
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := checkAttestationPolicy(result, policy.attestationPolicy()); err != nil {
+		return nil, nil, err
+	}
+	fmt.Printf("【注册】证明验证通过 (fmt: %s, type: %s, trusted: %t) ✓\n", result.Format, result.Type, result.Trusted)
+
+	return authData, result, nil
 }
 
 // 验证WebAuthn认证
-func verifyAuthentication(clientDataJSON, authenticatorData, signature string, expectedChallenge string) error {
+func verifyAuthentication(clientDataJSON, authenticatorData, signature string, expectedChallenge string, credential *WebAuthnCredential, policy userTypePolicy) (*authenticatorData, error) {
+	// 已撤销的凭证立即拒绝
+	if credential.RevokedAt != nil {
+		return nil, fmt.Errorf("凭证已被撤销")
+	}
+
 	// 解析clientDataJSON - 处理base64url格式（可能缺少padding）
 	decodedStr := clientDataJSON
 	switch len(clientDataJSON) % 4 {
@@ -134,110 +172,102 @@ func verifyAuthentication(clientDataJSON, authenticatorData, signature string, e
 		// 如果URLEncoding失败，尝试StdEncoding
 		clientDataBytes, err = base64.StdEncoding.DecodeString(decodedStr)
 		if err != nil {
-			return fmt.Errorf("解析clientDataJSON失败: %v", err)
+			return nil, fmt.Errorf("解析clientDataJSON失败: %v", err)
 		}
 	}
 
 	var clientData struct {
-		Type      string `json:"type"`
-		Challenge string `json:"challenge"`
-		Origin    string `json:"origin"`
+		Type        string `json:"type"`
+		Challenge   string `json:"challenge"`
+		Origin      string `json:"origin"`
+		CrossOrigin bool   `json:"crossOrigin"`
 	}
 
 	if err := json.Unmarshal(clientDataBytes, &clientData); err != nil {
-		return fmt.Errorf("解析clientData失败: %v", err)
+		return nil, fmt.Errorf("解析clientData失败: %v", err)
 	}
 
 	// 验证类型
 	if clientData.Type != "webauthn.get" {
-		return fmt.Errorf("无效的类型: %s", clientData.Type)
+		return nil, fmt.Errorf("无效的类型: %s", clientData.Type)
 	}
 
 	// 验证挑战
 	if clientData.Challenge != expectedChallenge {
-		return fmt.Errorf("挑战不匹配")
+		return nil, fmt.Errorf("挑战不匹配")
 	}
 
-	// 验证来源 - 支持localhost和生产环境
+	// 验证来源 - 必须在配置的允许列表中，且不允许跨域 iframe 调用
 	if clientData.Origin == "" {
-		return fmt.Errorf("来源不能为空")
+		return nil, fmt.Errorf("来源不能为空")
+	}
+	rpId, err := rpIDForOrigin(clientData.Origin)
+	if err != nil {
+		return nil, err
 	}
-	// 基本的来源格式验证，允许http/https协议
-	if !strings.HasPrefix(clientData.Origin, "http://") && !strings.HasPrefix(clientData.Origin, "https://") {
-		return fmt.Errorf("无效的来源协议: %s", clientData.Origin)
+	if clientData.CrossOrigin {
+		return nil, fmt.Errorf("不允许跨域调用")
 	}
 
-	// 验证authenticatorData - 处理 base64url padding
-	authDataStr := authenticatorData
-	switch len(authenticatorData) % 4 {
-	case 2:
-		authDataStr += "=="
-	case 3:
-		authDataStr += "="
-	}
-	
-	authDataBytes, err := base64.URLEncoding.DecodeString(authDataStr)
+	// 解析authenticatorData
+	authDataBytes, err := decodeWebAuthnBase64(authenticatorData)
 	if err != nil {
-		// 尝试 StdEncoding
-		authDataBytes, err = base64.StdEncoding.DecodeString(authDataStr)
-		if err != nil {
-			return fmt.Errorf("解析authenticatorData失败: %v", err)
-		}
+		return nil, fmt.Errorf("解析authenticatorData失败: %v", err)
 	}
 
-	if len(authDataBytes) < 37 {
-		return fmt.Errorf("authenticatorData太短")
+	authData, err := parseAuthenticatorData(authDataBytes)
+	if err != nil {
+		return nil, err
 	}
 
-	// 动态验证RP ID Hash - 支持localhost和生产环境
-	rpIdHash := authDataBytes[0:32]
-
-	// 从Origin提取RP ID
-	rpId := extractRpIdFromOrigin(clientData.Origin)
+	// 比较RP ID Hash（RP ID 由配置决定，而非客户端声明的来源）
 	expectedRpIdHash := sha256.Sum256([]byte(rpId))
-
-	// 比较RP ID Hash
-	for i := 0; i < 32; i++ {
-		if rpIdHash[i] != expectedRpIdHash[i] {
-			return fmt.Errorf("RP ID Hash不匹配: 期望=%s, 实际Origin=%s", rpId, clientData.Origin)
-		}
+	if !bytes.Equal(authData.RPIDHash, expectedRpIdHash[:]) {
+		return nil, fmt.Errorf("RP ID Hash不匹配: 期望=%s, 实际Origin=%s", rpId, clientData.Origin)
 	}
 
 	// 检查用户存在标志位
-	flags := authDataBytes[32]
-	if flags&0x01 == 0 {
-		return fmt.Errorf("用户不存在")
+	if !authData.UserPresent() {
+		return nil, fmt.Errorf("用户不存在")
 	}
 
-	return nil
-}
+	// 策略要求用户验证时检查UV标志位
+	if policy.requiresUserVerification() && !authData.UserVerified() {
+		return nil, fmt.Errorf("认证器未完成用户验证")
+	}
+
+	// BE 在凭证生命周期内不会改变，变化说明认证器异常
+	if authData.BackupEligible() != credential.BackupEligible {
+		return nil, fmt.Errorf("凭证备份资格(BE)与注册时不一致")
+	}
+	if err := policy.checkBackupFlags(authData); err != nil {
+		return nil, err
+	}
 
-// 从Origin URL中提取RP ID
-func extractRpIdFromOrigin(origin string) string {
-	// 移除协议前缀
-	if strings.HasPrefix(origin, "https://") {
-		origin = origin[8:]
-	} else if strings.HasPrefix(origin, "http://") {
-		origin = origin[7:]
+	// 验证签名: sig over (authenticatorData || SHA256(clientDataJSON))
+	sigBytes, err := decodeWebAuthnBase64(signature)
+	if err != nil {
+		return nil, fmt.Errorf("解析signature失败: %v", err)
 	}
 
-	// 移除端口号
-	if strings.Contains(origin, ":") {
-		origin = strings.Split(origin, ":")[0]
+	key, err := loadStoredCOSEKey(credential.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("读取公钥失败: %v", err)
 	}
 
-	// 移除路径
-	if strings.Contains(origin, "/") {
-		origin = strings.Split(origin, "/")[0]
+	clientDataHash := sha256.Sum256(clientDataBytes)
+	signedData := append(append([]byte{}, authDataBytes...), clientDataHash[:]...)
+	if err := key.Verify(signedData, sigBytes); err != nil {
+		return nil, err
 	}
 
-	return origin
+	return authData, nil
 }
 
 // safeMigrate 安全的数据库迁移函数
 func safeMigrate(db *gorm.DB) error {
 	// 要迁移的模型列表
-	models := []interface{}{&User{}, &Application{}, &AppPermission{}}
+	models := []interface{}{&User{}, &WebAuthnCredential{}, &Application{}, &AppPermission{}, &SecurityEvent{}, &IssuedCredential{}, &RefreshToken{}, &Session{}}
 
 	for _, model := range models {
 		// 获取表名
@@ -246,10 +276,20 @@ func safeMigrate(db *gorm.DB) error {
 		switch typeName {
 		case "*main.User":
 			tableName = "ykt_users"
+		case "*main.WebAuthnCredential":
+			tableName = "ykt_webauthn_credentials"
 		case "*main.Application":
 			tableName = "ykt_applications"
 		case "*main.AppPermission":
 			tableName = "ykt_app_permissions"
+		case "*main.SecurityEvent":
+			tableName = "ykt_security_events"
+		case "*main.IssuedCredential":
+			tableName = "ykt_issued_credentials"
+		case "*main.RefreshToken":
+			tableName = "ykt_refresh_tokens"
+		case "*main.Session":
+			tableName = "ykt_sessions"
 		default:
 			tableName = "unknown"
 		}
@@ -349,10 +389,66 @@ func initDB() {
 	DB = db
 	fmt.Println("✅ Database migration completed")
 
+	// 将旧版 ykt_users 中的单凭证迁移到凭证表
+	migrateLegacyCredentials(db)
+
 	// 初始化示例数据
 	initSeedData(db)
 }
 
+// migrateLegacyCredentials 将 User.CredentialID/PublicKey 中的旧凭证迁移到 ykt_webauthn_credentials
+func migrateLegacyCredentials(db *gorm.DB) {
+	var users []User
+	if err := db.Where("credential_id IS NOT NULL AND LENGTH(credential_id) > 0").Find(&users).Error; err != nil {
+		fmt.Printf("Warning: Failed to load legacy credentials: %v\n", err)
+		return
+	}
+
+	migrated := 0
+	for _, user := range users {
+		credentialId := string(user.CredentialID)
+
+		var count int64
+		db.Model(&WebAuthnCredential{}).Where("credential_id = ?", credentialId).Count(&count)
+		if count > 0 {
+			continue
+		}
+
+		// 早期版本保存的是整个attestationObject，迁移时提取出COSE_Key
+		publicKey, err := normalizeStoredPublicKey(user.PublicKey)
+		if err != nil {
+			fmt.Printf("Warning: Skipping legacy credential of %s: %v\n", user.DID, err)
+			continue
+		}
+
+		credential := WebAuthnCredential{
+			DID:          user.DID,
+			CredentialID: credentialId,
+			PublicKey:    publicKey,
+			SignCount:    user.SignCount,
+			Transports:   "internal",
+		}
+		// 保存的是attestationObject时，同时取出AAGUID和备份标志位（登录时会校验BE是否变化）
+		// parseAttestationObject 不要求AT标志位，缺少凭证数据时AAGUID留空
+		if _, authData, err := parseAttestationObject(user.PublicKey); err == nil {
+			if authData.AttestedCredential != nil {
+				credential.AAGUID = formatAAGUID(authData.AttestedCredential.AAGUID)
+			}
+			credential.BackupEligible = authData.BackupEligible()
+			credential.BackupState = authData.BackupState()
+		}
+		if err := db.Create(&credential).Error; err != nil {
+			fmt.Printf("Warning: Failed to migrate legacy credential of %s: %v\n", user.DID, err)
+			continue
+		}
+		migrated++
+	}
+
+	if migrated > 0 {
+		fmt.Printf("✅ Migrated %d legacy WebAuthn credentials\n", migrated)
+	}
+}
+
 // 初始化示例数据
 func initSeedData(db *gorm.DB) {
 	// 检查 ykt_applications 表是否存在
@@ -487,22 +583,126 @@ func createAppViaAPI(appData map[string]interface{}) bool {
 	}
 }
 
-// 生成 JWT (有效期 7 天)
-func generateToken(did string, userType string) (string, error) {
-	expirationTime := time.Now().Add(7 * 24 * time.Hour)
-	claims := &Claims{
-		DID:      did,
-		UserType: userType,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
-		},
-	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtKey)
+// ensureUserHandle 返回用户的 WebAuthn user handle，不存在时生成并保存
+func ensureUserHandle(user *User) (string, error) {
+	if user.UserHandle != nil && *user.UserHandle != "" {
+		return *user.UserHandle, nil
+	}
+
+	handle := generateChallenge()
+	if err := DB.Model(user).Update("user_handle", handle).Error; err != nil {
+		return "", err
+	}
+	user.UserHandle = &handle
+	return handle, nil
+}
+
+// JWT 鉴权中间件：校验 Authorization: Bearer <token> 及其会话是否已注销，并将 DID、用户类型和会话ID写入上下文
+func authMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "未登录"})
+			return
+		}
+		authenticate(c)
+	}
+}
+
+// 可选鉴权中间件：未携带 Authorization 时按未登录继续处理（上下文中没有 did），携带时与 authMiddleware 相同
+func optionalAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			c.Next()
+			return
+		}
+		authenticate(c)
+	}
+}
+
+// authenticate 校验 Bearer 令牌，通过后写入上下文并继续，否则以 401 中止
+func authenticate(c *gin.Context) {
+	header := c.GetHeader("Authorization")
+	tokenString := strings.TrimPrefix(header, "Bearer ")
+	if tokenString == header {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "未登录"})
+		return
+	}
+
+	claims, err := authTokens.Parse(tokenString)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "令牌无效或已过期"})
+		return
+	}
+	if revokedSessions.Revoked(claims.SessionID) {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "会话已注销，请重新登录"})
+		return
+	}
+
+	c.Set("did", claims.Subject)
+	c.Set("user_type", claims.UserType)
+	c.Set("sid", claims.SessionID)
+	c.Next()
+}
+
+// countActivePasskeys 用户未撤销的通行密钥数量
+func countActivePasskeys(did string) (int64, error) {
+	var count int64
+	err := DB.Model(&WebAuthnCredential{}).Where("did = ? AND revoked_at IS NULL", did).Count(&count).Error
+	return count, err
+}
+
+// 管理接口鉴权：校验 Authorization: Bearer <admin api_token>，未配置令牌时禁用管理接口
+func adminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if config.ADMIN_API_TOKEN == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "管理接口未启用"})
+			return
+		}
+		header := c.GetHeader("Authorization")
+		tokenString := strings.TrimPrefix(header, "Bearer ")
+		if tokenString == header || subtle.ConstantTimeCompare([]byte(tokenString), []byte(config.ADMIN_API_TOKEN)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "管理令牌无效"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// 安全事件类型
+const (
+	securityEventClonedAuthenticator = "cloned_authenticator"
+	securityEventPasswordReset       = "password_reset"
+	securityEventRefreshTokenReuse   = "refresh_token_reuse"
+)
+
+// buildResetPasswordMessage 重置密码时要求钱包签名的消息（EIP-191 personal_sign）
+func buildResetPasswordMessage(did, nonce string, issuedAt time.Time) string {
+	return fmt.Sprintf("%s 请求您签名以重置密码。\n\nDID: %s\nNonce: %s\nIssued At: %s",
+		config.WEBAUTHN_RP_NAME, did, nonce, issuedAt.UTC().Format(time.RFC3339))
+}
+
+// 记录安全事件（失败只打印日志，不影响请求流程）
+func recordSecurityEvent(did, eventType, detail, ip string) {
+	event := SecurityEvent{
+		DID:       did,
+		EventType: eventType,
+		Detail:    detail,
+		IP:        ip,
+	}
+	if err := DB.Create(&event).Error; err != nil {
+		fmt.Printf("记录安全事件失败 (%s, %s): %v\n", eventType, did, err)
+	}
 }
 
 func main() {
+	initTokenService()
 	initDB()
+	initRefreshTokenCleanup()
+	initSessions()
+	initChallengeStore()
+	initMetadataService()
+	initContractVerifier()
+	initKeyStore()
 	r := gin.Default()
 
 	// 添加CORS中间件
@@ -548,54 +748,112 @@ func main() {
 	})
 
 	// 1.5. WebAuthn注册选项生成
-	r.POST("/api/webauthn/register/begin", func(c *gin.Context) {
+	// 已登录时为当前账户添加通行密钥；未登录时只能为尚无通行密钥的新账户注册第一个通行密钥，并需要验证密码
+	r.POST("/api/webauthn/register/begin", optionalAuthMiddleware(), func(c *gin.Context) {
 		var input struct {
-			Email string `json:"email"`
+			Email    string `json:"email"`
+			Password string `json:"password"`
 		}
 		if err := c.ShouldBindJSON(&input); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
+		var user User
+		newAccount := false
+		if did := c.GetString("did"); did != "" {
+			if err := DB.Where("did = ?", did).First(&user).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+				return
+			}
+		} else {
+			if err := DB.Where("email = ?", input.Email).First(&user).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+				return
+			}
+			if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(input.Password)) != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "密码错误"})
+				return
+			}
+			count, err := countActivePasskeys(user.DID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "查询凭证失败"})
+				return
+			}
+			if count > 0 {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "该账户已有通行密钥，添加新的通行密钥需要先登录"})
+				return
+			}
+			newAccount = true
+		}
+
+		// 已注册的凭证放入excludeCredentials，避免同一认证器重复注册
+		var existing []WebAuthnCredential
+		DB.Where("did = ? AND revoked_at IS NULL", user.DID).Find(&existing)
+		excludeCredentials := []gin.H{}
+		for _, cred := range existing {
+			excludeCredentials = append(excludeCredentials, gin.H{
+				"type": "public-key",
+				"id":   cred.CredentialID,
+			})
+		}
+
+		// user.id 使用稳定的随机句柄，无用户名登录时据此找回用户
+		userHandle, err := ensureUserHandle(&user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "生成用户句柄失败"})
+			return
+		}
+
+		// 挑战按会话保存，同一用户并发发起多次注册互不覆盖
 		challenge := generateChallenge()
-		challenges[input.Email] = challenge
-		fmt.Printf("【注册Begin】为用户 %s 生成challenge: %s (长度%d)\n", input.Email, challenge, len(challenge))
+		sessionID := generateChallenge()
+		if err := challengeStore.Save(c.Request.Context(), ceremonyCreate, sessionID, challengeEntry{Challenge: challenge, Subject: user.Email, NewAccount: newAccount}, challengeTTL); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "保存挑战失败"})
+			return
+		}
+		fmt.Printf("【注册Begin】为用户 %s 生成challenge: %s (长度%d)\n", user.Email, challenge, len(challenge))
+
+		// RP ID 取自配置（允许的本地开发来源使用 localhost）
+		rpId := rpIDForRequest(c.GetHeader("Origin"))
 
-		// 动态获取RP ID，支持localhost和生产环境
-		rpId := c.Request.Host
-		// 如果是端口地址，只取域名部分
-		if strings.Contains(rpId, ":") {
-			rpId = strings.Split(rpId, ":")[0]
+		// 认证器选择、用户验证和证明要求取自用户类型策略
+		policy := policyForUserType(user.UserType)
+
+		// 按配置的优先级列出可接受的签名算法
+		pubKeyCredParams := []gin.H{}
+		for _, alg := range parseCOSEAlgorithms(config.WEBAUTHN_ALGORITHMS) {
+			pubKeyCredParams = append(pubKeyCredParams, gin.H{"type": "public-key", "alg": alg})
 		}
 
 		options := gin.H{
 			"challenge": challenge,
 			"rp": gin.H{
-				"name": "DID Portal",
+				"name": config.WEBAUTHN_RP_NAME,
 				"id":   rpId,
 			},
 			"user": gin.H{
-				"id":          base64.URLEncoding.EncodeToString([]byte(input.Email)),
-				"name":        input.Email,
-				"displayName": input.Email,
-			},
-			"pubKeyCredParams": []gin.H{
-				{"type": "public-key", "alg": -7}, // ES256
+				"id":          userHandle,
+				"name":        user.Email,
+				"displayName": user.Email,
 			},
-			"authenticatorSelection": gin.H{
-				"authenticatorAttachment": "platform",
-				"userVerification":        "required",
-			},
-			"timeout": 60000,
+			"pubKeyCredParams":       pubKeyCredParams,
+			"authenticatorSelection": policy.authenticatorSelection(),
+			"attestation":            policy.attestationConveyance(),
+			"excludeCredentials":     excludeCredentials,
+			"timeout":                60000,
+			"session_id":             sessionID,
 		}
 
 		c.JSON(http.StatusOK, options)
 	})
 
-	// 1.6. WebAuthn注册完成
-	r.POST("/api/webauthn/register/finish", func(c *gin.Context) {
+	// 1.6. WebAuthn注册完成（已登录时发起的注册必须以同一账户的令牌完成）
+	r.POST("/api/webauthn/register/finish", optionalAuthMiddleware(), func(c *gin.Context) {
 		var input struct {
-			Email      string `json:"email"`
+			Email      string `json:"email"` // 可选，传入时必须与发起注册的账户一致
+			SessionID  string `json:"session_id"`
+			Nickname   string `json:"nickname"`
 			Credential gin.H  `json:"credential"`
 		}
 		if err := c.ShouldBindJSON(&input); err != nil {
@@ -603,50 +861,209 @@ func main() {
 			return
 		}
 
-		// 验证挑战
-		expectedChallenge, exists := challenges[input.Email]
-		if !exists {
-			// 打印当前所有存储的challenge keys
-			var keys []string
-			for k := range challenges {
-				keys = append(keys, k)
-			}
-			fmt.Printf("【注册Finish】错误: 没有为邮箱 %s 找到challenge。当前存储的keys: %v\n", input.Email, keys)
+		// 客户端提交的凭证字段必须是字符串
+		response, _ := input.Credential["response"].(map[string]interface{})
+		credentialId, okID := input.Credential["id"].(string)
+		clientDataJSON, okClientData := response["clientDataJSON"].(string)
+		attestationObject, okAttestation := response["attestationObject"].(string)
+		if !okID || !okClientData || !okAttestation {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "凭证格式错误"})
+			return
+		}
+
+		// 验证挑战（一次性取出，必须是同一用户发起的注册挑战）
+		entry, err := challengeStore.Consume(c.Request.Context(), ceremonyCreate, input.SessionID)
+		if err != nil || (input.Email != "" && entry.Subject != input.Email) {
+			fmt.Printf("【注册Finish】错误: 没有为邮箱 %s 找到会话 %s 的challenge: %v\n", input.Email, input.SessionID, err)
 			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的挑战"})
 			return
 		}
-		fmt.Printf("【注册Finish】为邮箱 %s 找到存储的challenge: %s (长度%d)\n", input.Email, expectedChallenge, len(expectedChallenge))
-		delete(challenges, input.Email)
+		expectedChallenge := entry.Challenge
+		fmt.Printf("【注册Finish】为邮箱 %s 找到存储的challenge: %s (长度%d)\n", entry.Subject, expectedChallenge, len(expectedChallenge))
 
-		// 真正的WebAuthn验证
-		clientDataJSON := input.Credential["response"].(map[string]interface{})["clientDataJSON"].(string)
-		attestationObject := input.Credential["response"].(map[string]interface{})["attestationObject"].(string)
+		var user User
+		if err := DB.Where("email = ?", entry.Subject).First(&user).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+			return
+		}
+
+		if entry.NewAccount {
+			// 未登录发起的注册：并发发起的多次注册只有第一个能完成
+			count, err := countActivePasskeys(user.DID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "查询凭证失败"})
+				return
+			}
+			if count > 0 {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "该账户已有通行密钥，添加新的通行密钥需要先登录"})
+				return
+			}
+		} else if c.GetString("did") != user.DID {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "未登录"})
+			return
+		}
 
-		publicKey, err := verifyRegistration(clientDataJSON, attestationObject, expectedChallenge)
+		policy := policyForUserType(user.UserType)
+		authData, attestation, err := verifyRegistration(clientDataJSON, attestationObject, expectedChallenge, policy)
 		if err != nil {
 			fmt.Printf("WebAuthn注册验证失败: %v\n", err)
 			c.JSON(http.StatusBadRequest, gin.H{"error": "验证失败: " + err.Error()})
 			return
 		}
 
+		// 按 FIDO 元数据检查认证器型号（吊销/泄露状态、认证等级）
+		if err := checkAuthenticatorMetadata(formatAAGUID(authData.AttestedCredential.AAGUID), policy); err != nil {
+			fmt.Printf("WebAuthn注册被元数据策略拒绝: %v\n", err)
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+
 		fmt.Printf("WebAuthn注册验证成功\n")
 
-		credentialId := input.Credential["id"].(string)
+		// 凭证ID必须与authData中的一致
+		if base64.RawURLEncoding.EncodeToString(authData.AttestedCredential.CredentialID) != strings.TrimRight(credentialId, "=") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "验证失败: 凭证ID不匹配"})
+			return
+		}
+		publicKey := authData.AttestedCredential.CredentialPublicKey
+
+		// 前端通过 getTransports() 上报的传输方式
+		var transports []string
+		if list, ok := response["transports"].([]interface{}); ok {
+			for _, t := range list {
+				if name, ok := t.(string); ok && name != "" {
+					transports = append(transports, name)
+				}
+			}
+		}
+
+		// 策略限制了传输方式时，认证器上报的传输方式必须至少有一项被允许
+		if len(transports) > 0 && len(policy.allowedTransports(transports)) == 0 {
+			c.JSON(http.StatusForbidden, gin.H{"error": "该认证器的传输方式不符合用户类型策略"})
+			return
+		}
+
+		// 新增一条凭证记录（直接保存原始base64url字符串），不覆盖用户已有的其他凭证
+		credential := WebAuthnCredential{
+			DID:             user.DID,
+			CredentialID:    credentialId,
+			PublicKey:       publicKey,
+			SignCount:       authData.SignCount,
+			AAGUID:          formatAAGUID(authData.AttestedCredential.AAGUID),
+			Transports:      strings.Join(transports, ","),
+			BackupEligible:  authData.BackupEligible(),
+			BackupState:     authData.BackupState(),
+			AttestationFmt:  attestation.Format,
+			AttestationType: string(attestation.Type),
+			Nickname:        input.Nickname,
+		}
+
+		var duplicate int64
+		DB.Model(&WebAuthnCredential{}).Where("credential_id = ?", credentialId).Count(&duplicate)
+		if duplicate > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "该凭证已注册"})
+			return
+		}
+
+		fmt.Printf("【注册】保存凭证 - CredentialID: %s (长度: %d), PublicKey长度: %d, AAGUID: %s\n", credentialId, len(credentialId), len(publicKey), credential.AAGUID)
+		if err := DB.Create(&credential).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "保存凭证失败"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"verified": true})
+	})
+
+	// 1.7. 凭证管理：列出当前用户已注册的通行密钥
+	r.GET("/api/webauthn/credentials", authMiddleware(), func(c *gin.Context) {
+		var credentials []WebAuthnCredential
+		if err := DB.Where("did = ? AND revoked_at IS NULL", c.GetString("did")).Order("created_at").Find(&credentials).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败"})
+			return
+		}
+
+		list := []gin.H{}
+		for _, cred := range credentials {
+			list = append(list, gin.H{
+				"id":              cred.ID,
+				"credential_id":   cred.CredentialID,
+				"nickname":        cred.Nickname,
+				"aaguid":          cred.AAGUID,
+				"transports":      cred.TransportList(),
+				"backup_eligible": cred.BackupEligible,
+				"backup_state":    cred.BackupState,
+				"sync_status":     cred.SyncStatus(),
+				"created_at":      cred.CreatedAt,
+				"last_used_at":    cred.LastUsedAt,
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{"credentials": list})
+	})
+
+	// 1.8. 凭证管理：重命名通行密钥
+	r.PUT("/api/webauthn/credentials/:id", authMiddleware(), func(c *gin.Context) {
+		var input struct {
+			Nickname string `json:"nickname"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		nickname := strings.TrimSpace(input.Nickname)
+		if nickname == "" || len([]rune(nickname)) > 50 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "名称不能为空且不超过50个字符"})
+			return
+		}
+
+		var credential WebAuthnCredential
+		if err := DB.Where("id = ? AND did = ? AND revoked_at IS NULL", c.Param("id"), c.GetString("did")).First(&credential).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "凭证不存在"})
+			return
+		}
+
+		if err := DB.Model(&credential).Update("nickname", nickname).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "重命名失败"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "重命名成功", "id": credential.ID, "nickname": nickname})
+	})
+
+	// 1.9. 凭证管理：撤销通行密钥
+	r.DELETE("/api/webauthn/credentials/:id", authMiddleware(), func(c *gin.Context) {
+		did := c.GetString("did")
+
+		var credential WebAuthnCredential
+		if err := DB.Where("id = ? AND did = ? AND revoked_at IS NULL", c.Param("id"), did).First(&credential).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "凭证不存在"})
+			return
+		}
 
-		// 更新用户的WebAuthn信息
+		// 策略要求至少保留一个认证器时，禁止撤销最后一个
 		var user User
-		if err := DB.Where("email = ?", input.Email).First(&user).Error; err != nil {
+		if err := DB.Where("did = ?", did).First(&user).Error; err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
 			return
 		}
+		if policyForUserType(user.UserType).RequireAuthenticator {
+			var active int64
+			DB.Model(&WebAuthnCredential{}).Where("did = ? AND revoked_at IS NULL", did).Count(&active)
+			if active <= 1 {
+				c.JSON(http.StatusConflict, gin.H{"error": "当前用户类型要求至少保留一个认证器"})
+				return
+			}
+		}
 
-		// 存储凭证ID（直接保存原始base64url字符串）和公钥
-		user.CredentialID = []byte(credentialId)
-		user.PublicKey = publicKey
-		fmt.Printf("【注册】保存凭证 - CredentialID: %s (长度: %d), PublicKey长度: %d\n", credentialId, len(credentialId), len(publicKey))
-		DB.Save(&user)
+		// 软删除：保留记录以便审计，验证时立即拒绝
+		if err := DB.Model(&credential).Update("revoked_at", time.Now()).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "撤销失败"})
+			return
+		}
 
-		c.JSON(http.StatusOK, gin.H{"verified": true})
+		fmt.Printf("【凭证管理】用户 %s 撤销凭证 %s\n", did, credential.CredentialID)
+		c.JSON(http.StatusOK, gin.H{"message": "凭证已撤销", "id": credential.ID})
 	})
 
 	// 2. 登录接口 (第一阶段：Email+密码)
@@ -677,12 +1094,7 @@ func main() {
 		}
 
 		// 生成JWT令牌
-		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-			"email": user.Email,
-			"did":   user.DID,
-			"exp":   time.Now().Add(time.Hour * 24).Unix(),
-		})
-		tokenString, err := token.SignedString([]byte("your-secret-key"))
+		tokens, err := issueTokenPair(&user, c.ClientIP(), c.Request.UserAgent(), amrPassword)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "生成令牌失败"})
 			return
@@ -690,11 +1102,13 @@ func main() {
 
 		// 返回完整的用户信息，可用于备用认证
 		c.JSON(http.StatusOK, gin.H{
-			"message":   "基础验证通过",
-			"token":     tokenString,
-			"did":       user.DID,
-			"user_type": user.UserType,
-			"email":     user.Email,
+			"message":       "基础验证通过",
+			"token":         tokens.AccessToken,
+			"refresh_token": tokens.RefreshToken,
+			"expires_in":    tokens.ExpiresIn,
+			"did":           user.DID,
+			"user_type":     user.UserType,
+			"email":         user.Email,
 		})
 	})
 
@@ -708,49 +1122,74 @@ func main() {
 			return
 		}
 
-		// 获取用户的凭证信息
-		var user User
-		if err := DB.Where("email = ?", input.Email).First(&user).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
-			return
-		}
+		allowCredentials := []gin.H{}
+		userVerification := strictestUserVerification()
 
-		if len(user.CredentialID) == 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "用户未注册指纹"})
-			return
+		// 无用户名登录时 allowCredentials 为空，由认证器列出可发现凭证
+		if input.Email != "" {
+			// 获取用户的凭证信息
+			var user User
+			if err := DB.Where("email = ?", input.Email).First(&user).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+				return
+			}
+
+			var credentials []WebAuthnCredential
+			DB.Where("did = ? AND revoked_at IS NULL", user.DID).Find(&credentials)
+			if len(credentials) == 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "用户未注册指纹"})
+				return
+			}
+
+			policy := policyForUserType(user.UserType)
+			userVerification = policy.UserVerification
+
+			for _, cred := range credentials {
+				allowed := gin.H{
+					"type": "public-key",
+					"id":   cred.CredentialID, // 直接使用保存的base64url字符串
+				}
+				// 只提示策略允许的传输方式；未记录传输方式时交给浏览器决定（包括 hybrid）
+				if transports := policy.allowedTransports(cred.TransportList()); len(transports) > 0 {
+					allowed["transports"] = transports
+				} else if len(cred.TransportList()) > 0 {
+					continue
+				}
+				allowCredentials = append(allowCredentials, allowed)
+			}
+			if len(allowCredentials) == 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "没有符合用户类型策略的凭证"})
+				return
+			}
 		}
 
 		challenge := generateChallenge()
-		challenges[input.Email] = challenge
-
-		// 动态获取RP ID，支持localhost和生产环境
-		rpId := c.Request.Host
-		// 如果是端口地址，只取域名部分
-		if strings.Contains(rpId, ":") {
-			rpId = strings.Split(rpId, ":")[0]
+		sessionID := generateChallenge()
+		if err := challengeStore.Save(c.Request.Context(), ceremonyGet, sessionID, challengeEntry{Challenge: challenge, Subject: input.Email}, challengeTTL); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "保存挑战失败"})
+			return
 		}
 
+		// RP ID 取自配置（允许的本地开发来源使用 localhost）
+		rpId := rpIDForRequest(c.GetHeader("Origin"))
+
 		options := gin.H{
-			"challenge": challenge,
-			"timeout":   60000,
-			"rpId":      rpId,
-			"allowCredentials": []gin.H{
-				{
-					"type":       "public-key",
-					"id":         string(user.CredentialID),  // 直接使用保存的base64url字符串
-					"transports": []string{"internal"},
-				},
-			},
-			"userVerification": "required",
+			"challenge":        challenge,
+			"timeout":          60000,
+			"rpId":             rpId,
+			"allowCredentials": allowCredentials,
+			"userVerification": userVerification,
+			"session_id":       sessionID,
 		}
 
 		c.JSON(http.StatusOK, options)
 	})
 
-	// 3. WebAuthn 验证完成并下发 7 天 JWT
+	// 3. WebAuthn 验证完成并下发访问令牌（默认 15 分钟）和刷新令牌
 	r.POST("/api/login/verify-webauthn", func(c *gin.Context) {
 		var input struct {
 			Email      string `json:"email"`
+			SessionID  string `json:"session_id"`
 			Credential gin.H  `json:"credential"`
 		}
 
@@ -761,41 +1200,368 @@ func main() {
 
 		fmt.Printf("WebAuthn验证请求: Email=%s\n", input.Email)
 
-		// 验证挑战
-		expectedChallenge, exists := challenges[input.Email]
-		if !exists {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的挑战"})
+		// 客户端提交的凭证字段必须是字符串
+		response, _ := input.Credential["response"].(map[string]interface{})
+		clientDataJSON, okClientData := response["clientDataJSON"].(string)
+		authenticatorData, okAuthData := response["authenticatorData"].(string)
+		signature, okSignature := response["signature"].(string)
+		if !okClientData || !okAuthData || !okSignature {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "凭证格式错误"})
 			return
 		}
-		delete(challenges, input.Email)
 
-		// 真正的WebAuthn验证
-		clientDataJSON := input.Credential["response"].(map[string]interface{})["clientDataJSON"].(string)
-		authenticatorData := input.Credential["response"].(map[string]interface{})["authenticatorData"].(string)
-		signature := input.Credential["response"].(map[string]interface{})["signature"].(string)
+		// 验证挑战（一次性取出，必须与发起登录时的邮箱一致；无用户名登录时为空）
+		entry, err := challengeStore.Consume(c.Request.Context(), ceremonyGet, input.SessionID)
+		if err != nil || entry.Subject != input.Email {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的挑战"})
+			return
+		}
+		expectedChallenge := entry.Challenge
+
+		credentialId, _ := input.Credential["id"].(string)
+		var credential WebAuthnCredential
+		if credentialId == "" || DB.Where("credential_id = ?", credentialId).First(&credential).Error != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "验证失败: 凭证不存在"})
+			return
+		}
+
+		var user User
+		if input.Email != "" {
+			if err := DB.Where("email = ?", input.Email).First(&user).Error; err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "用户不存在"})
+				return
+			}
+		} else {
+			// 无用户名登录：通过 userHandle 找回用户
+			userHandle, _ := response["userHandle"].(string)
+			userHandle = strings.TrimRight(userHandle, "=")
+			query := DB.Where("did = ?", credential.DID)
+			if userHandle != "" {
+				query = DB.Where("user_handle = ?", userHandle)
+			}
+			if err := query.First(&user).Error; err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "用户不存在"})
+				return
+			}
+		}
+
+		// 断言使用的凭证必须是该用户已注册的凭证
+		if credential.DID != user.DID {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "验证失败: 凭证不属于该用户"})
+			return
+		}
 
-		if err := verifyAuthentication(clientDataJSON, authenticatorData, signature, expectedChallenge); err != nil {
+		// 真正的WebAuthn验证
+		authData, err := verifyAuthentication(clientDataJSON, authenticatorData, signature, expectedChallenge, &credential, policyForUserType(user.UserType))
+		if err != nil {
 			fmt.Printf("WebAuthn认证验证失败: %v\n", err)
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "验证失败: " + err.Error()})
 			return
 		}
 
-		fmt.Printf("WebAuthn认证验证成功\n")
+		// 签名计数器校验：始终返回0的认证器不支持计数器，跳过检查
+		if authData.SignCount != 0 || credential.SignCount != 0 {
+			if authData.SignCount <= credential.SignCount {
+				fmt.Printf("【登录】签名计数器未递增: DID=%s, 存储=%d, 收到=%d\n", user.DID, credential.SignCount, authData.SignCount)
+				recordSecurityEvent(user.DID, securityEventClonedAuthenticator,
+					fmt.Sprintf("credential=%s stored_count=%d received_count=%d", credentialId, credential.SignCount, authData.SignCount), c.ClientIP())
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "验证失败: 签名计数器异常，认证器可能已被克隆"})
+				return
+			}
+		}
+
+		// 条件更新，防止并发的两次断言使用同一个计数器；同时刷新备份状态（BS 可能随时变化）
+		now := time.Now()
+		result := DB.Model(&WebAuthnCredential{}).
+			Where("id = ? AND sign_count = ?", credential.ID, credential.SignCount).
+			Updates(map[string]interface{}{"sign_count": authData.SignCount, "backup_state": authData.BackupState(), "last_used_at": now})
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "更新签名计数器失败"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			recordSecurityEvent(user.DID, securityEventClonedAuthenticator,
+				fmt.Sprintf("credential=%s concurrent assertion, received_count=%d", credentialId, authData.SignCount), c.ClientIP())
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "验证失败: 签名计数器异常，认证器可能已被克隆"})
+			return
+		}
+
+		tokens, err := issueTokenPair(&user, c.ClientIP(), c.Request.UserAgent(), amrWebAuthn)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "生成令牌失败"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"token":         tokens.AccessToken,
+			"refresh_token": tokens.RefreshToken,
+			"expires_in":    tokens.ExpiresIn,
+			"user_type":     user.UserType,
+			"did":           user.DID,
+		})
+	})
+
+	// 3.1. Sign-In with Ethereum：签发 nonce，传入地址时同时返回完整的待签名消息
+	r.POST("/api/login/siwe/nonce", func(c *gin.Context) {
+		var input struct {
+			Address string `json:"address"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		subject := ""
+		if input.Address != "" {
+			address, err := normalizeEthereumAddress(input.Address)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			subject = address
+		}
+
+		nonce := generateSIWENonce()
+		if err := challengeStore.Save(c.Request.Context(), ceremonySIWE, nonce, challengeEntry{Challenge: nonce, Subject: subject}, challengeTTL); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "保存挑战失败"})
+			return
+		}
+
+		response := gin.H{"nonce": nonce}
+		if input.Address != "" {
+			message, err := newSIWEMessage(input.Address, c.GetHeader("Origin"), nonce, time.Now())
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			response["message"] = message.String()
+		}
+		c.JSON(http.StatusOK, response)
+	})
+
+	// 3.2. Sign-In with Ethereum：验证签名并下发访问令牌（默认 15 分钟）和刷新令牌
+	r.POST("/api/login/siwe/verify", func(c *gin.Context) {
+		var input struct {
+			Message   string `json:"message" binding:"required"`
+			Signature string `json:"signature" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		message, err := parseSIWEMessage(input.Message)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := message.Validate(time.Now()); err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+
+		// nonce 一次性使用；签发时指定了地址的必须一致
+		address, err := normalizeEthereumAddress(message.Address)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		entry, err := challengeStore.Consume(c.Request.Context(), ceremonySIWE, message.Nonce)
+		if err != nil || (entry.Subject != "" && entry.Subject != address) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "无效的nonce"})
+			return
+		}
+
+		if err := verifyDIDSignature(c.Request.Context(), input.Message, input.Signature, address); err != nil {
+			fmt.Printf("【SIWE】签名验证失败: %s, %v\n", address, err)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "签名验证失败"})
+			return
+		}
 
 		var user User
-		if err := DB.Where("email = ?", input.Email).First(&user).Error; err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "用户不存在"})
+		if err := DB.Where("did = ?", address).First(&user).Error; err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "该地址未注册DID"})
+			return
+		}
+
+		tokens, err := issueTokenPair(&user, c.ClientIP(), c.Request.UserAgent(), amrSIWE)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "生成令牌失败"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"token":         tokens.AccessToken,
+			"refresh_token": tokens.RefreshToken,
+			"expires_in":    tokens.ExpiresIn,
+			"user_type":     user.UserType,
+			"did":           user.DID,
+		})
+	})
+
+	// 3.3. 可验证展示登录（OpenID4VP 风格）：下发 nonce 和展示定义
+	r.POST("/api/login/vp/request", func(c *gin.Context) {
+		nonce := generateChallenge()
+		if err := challengeStore.Save(c.Request.Context(), ceremonyPresentation, nonce, challengeEntry{Challenge: nonce}, challengeTTL); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "保存挑战失败"})
 			return
 		}
 
-		token, _ := generateToken(user.DID, user.UserType)
 		c.JSON(http.StatusOK, gin.H{
-			"token":     token,
-			"user_type": user.UserType,
-			"did":       user.DID,
+			"response_type":           "vp_token",
+			"response_mode":           "direct_post",
+			"response_uri":            "/api/login/vp/submit",
+			"client_id":               config.ISSUER_DID,
+			"nonce":                   nonce,
+			"presentation_definition": presentationDefinition(),
+		})
+	})
+
+	// 3.4. 可验证展示登录：验证持有者签名和用户类型凭证，下发访问令牌（默认 15 分钟）和刷新令牌
+	r.POST("/api/login/vp/submit", func(c *gin.Context) {
+		var input struct {
+			VPToken string `json:"vp_token" form:"vp_token" binding:"required"`
+		}
+		if err := c.ShouldBind(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		result, err := verifyPresentationJWT(c.Request.Context(), input.VPToken, time.Now())
+		if err != nil {
+			fmt.Printf("【VP】展示验证失败: %v\n", err)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+
+		// nonce 一次性使用，防止展示被重放
+		if _, err := challengeStore.Consume(c.Request.Context(), ceremonyPresentation, result.Nonce); err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "无效的nonce"})
+			return
+		}
+
+		// 持有者 DID 必须对应已注册的用户（did:ethr:<chain>:<address>）
+		address := result.Holder[strings.LastIndex(result.Holder, ":")+1:]
+		var user User
+		if err := DB.Where("did = ?", address).First(&user).Error; err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "持有者DID未注册"})
+			return
+		}
+		if holder, err := normalizeDID(user.DID); err != nil || holder != result.Holder {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "持有者DID未注册"})
+			return
+		}
+		if result.Credential.CredentialSubject["userType"] != user.UserType {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "凭证中的用户类型已失效，请重新申请凭证"})
+			return
+		}
+
+		tokens, err := issueTokenPair(&user, c.ClientIP(), c.Request.UserAgent(), amrPresentation)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "生成令牌失败"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"token":         tokens.AccessToken,
+			"refresh_token": tokens.RefreshToken,
+			"expires_in":    tokens.ExpiresIn,
+			"user_type":     user.UserType,
+			"did":           user.DID,
+		})
+	})
+
+	// 3.5. 刷新令牌：用刷新令牌换取新的访问令牌和刷新令牌，旧刷新令牌作废
+	r.POST("/api/token/refresh", func(c *gin.Context) {
+		var input struct {
+			RefreshToken string `json:"refresh_token" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		tokens, user, err := refreshTokenPair(input.RefreshToken, c.ClientIP())
+		if errors.Is(err, errRefreshTokenInvalid) || errors.Is(err, errRefreshTokenReused) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			fmt.Printf("【刷新令牌】失败: %v\n", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "刷新令牌失败"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"token":         tokens.AccessToken,
+			"refresh_token": tokens.RefreshToken,
+			"expires_in":    tokens.ExpiresIn,
+			"user_type":     user.UserType,
+			"did":           user.DID,
 		})
 	})
 
+	// 3.6. 退出登录：注销当前会话，访问令牌和刷新令牌立即失效
+	r.POST("/api/logout", authMiddleware(), func(c *gin.Context) {
+		sessionID := c.GetString("sid")
+		if err := revokeSession(DB, sessionID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "退出登录失败"})
+			return
+		}
+		fmt.Printf("【会话】用户 %s 退出登录，会话 %s\n", c.GetString("did"), sessionID)
+		c.JSON(http.StatusOK, gin.H{"message": "已退出登录"})
+	})
+
+	// 3.7. 列出当前用户的有效会话（登录设备）
+	r.GET("/api/sessions", authMiddleware(), func(c *gin.Context) {
+		var sessions []Session
+		if err := DB.Where("did = ? AND revoked_at IS NULL AND expires_at > ?", c.GetString("did"), time.Now()).
+			Order("last_seen_at desc").Find(&sessions).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询会话失败"})
+			return
+		}
+
+		current := c.GetString("sid")
+		result := make([]gin.H, 0, len(sessions))
+		for _, session := range sessions {
+			result = append(result, gin.H{
+				"id":           session.ID,
+				"device":       session.Device,
+				"ip":           session.IP,
+				"user_agent":   session.UserAgent,
+				"auth_method":  session.AuthMethod,
+				"created_at":   session.CreatedAt,
+				"last_seen_at": session.LastSeenAt,
+				"expires_at":   session.ExpiresAt,
+				"current":      session.ID == current,
+			})
+		}
+		c.JSON(http.StatusOK, gin.H{"sessions": result})
+	})
+
+	// 3.8. 注销指定会话（如丢失的设备）
+	r.DELETE("/api/sessions/:id", authMiddleware(), func(c *gin.Context) {
+		var session Session
+		if err := DB.Where("id = ? AND did = ?", c.Param("id"), c.GetString("did")).First(&session).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "会话不存在"})
+			return
+		}
+		if err := revokeSession(DB, session.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "注销会话失败"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "会话已注销", "id": session.ID})
+	})
+
+	// 3.9. 退出所有设备：注销当前用户的全部会话（包括当前会话）
+	r.POST("/api/sessions/revoke-all", authMiddleware(), func(c *gin.Context) {
+		did := c.GetString("did")
+		count, err := revokeSessionsForDID(DB, did)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "注销会话失败"})
+			return
+		}
+		fmt.Printf("【会话】用户 %s 退出所有设备，共注销 %d 个会话\n", did, count)
+		c.JSON(http.StatusOK, gin.H{"message": "已退出所有设备", "revoked": count})
+	})
+
 	// 4. 获取 App 列表
 	r.GET("/api/apps", func(c *gin.Context) {
 		// 从查询参数获取 userType
@@ -910,23 +1676,82 @@ func main() {
 		})
 	})
 
-	// 6. 密码重置接口（通过 DID）
+	// 6. 密码重置挑战：返回需要用助记词对应私钥签名的消息
+	r.POST("/api/reset-password/challenge", func(c *gin.Context) {
+		var input struct {
+			DID string `json:"did"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		address, err := normalizeEthereumAddress(input.DID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var user User
+		if err := DB.Where("did = ?", input.DID).First(&user).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "DID 不存在"})
+			return
+		}
+
+		issuedAt := time.Now()
+		message := buildResetPasswordMessage(user.DID, generateChallenge(), issuedAt)
+		sessionID := generateChallenge()
+		if err := challengeStore.Save(c.Request.Context(), ceremonyResetPassword, sessionID, challengeEntry{Challenge: message, Subject: address}, challengeTTL); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "保存挑战失败"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":    message,
+			"session_id": sessionID,
+			"expires_at": issuedAt.Add(challengeTTL),
+		})
+	})
+
+	// 6.1. 密码重置接口（通过 DID，需要对挑战消息的 personal_sign 签名证明持有私钥）
 	r.POST("/api/reset-password", func(c *gin.Context) {
 		var input struct {
 			DID         string `json:"did"`
 			NewPassword string `json:"new_password"`
+			SessionID   string `json:"session_id"`
+			Signature   string `json:"signature"`
 		}
 		if err := c.ShouldBindJSON(&input); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
+		address, err := normalizeEthereumAddress(input.DID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// 挑战一次性取出，必须是为同一 DID 签发的
+		entry, err := challengeStore.Consume(c.Request.Context(), ceremonyResetPassword, input.SessionID)
+		if err != nil || entry.Subject != address {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的挑战"})
+			return
+		}
+
 		var user User
 		if err := DB.Where("did = ?", input.DID).First(&user).Error; err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "DID 不存在"})
 			return
 		}
 
+		// 签名者必须是该 DID（普通账户按地址恢复，合约钱包按 EIP-1271 验证）
+		if err := verifyDIDSignature(c.Request.Context(), entry.Challenge, input.Signature, user.DID); err != nil {
+			fmt.Printf("【重置密码】签名验证失败: DID=%s, %v\n", user.DID, err)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "签名验证失败"})
+			return
+		}
+
 		// 生成新密码哈希
 		hash, err := bcrypt.GenerateFromPassword([]byte(input.NewPassword), 14)
 		if err != nil {
@@ -941,6 +1766,13 @@ func main() {
 			return
 		}
 
+		recordSecurityEvent(user.DID, securityEventPasswordReset, "signed reset challenge", c.ClientIP())
+
+		// 重置密码后所有设备需要重新登录
+		if _, err := revokeSessionsForDID(DB, user.DID); err != nil {
+			fmt.Printf("【重置密码】注销会话失败: DID=%s, %v\n", user.DID, err)
+		}
+
 		c.JSON(http.StatusOK, gin.H{
 			"message": "密码重置成功",
 			"email":   user.Email,
@@ -948,5 +1780,257 @@ func main() {
 		})
 	})
 
+	// 7. DID 解析：Accept 为 application/did+json（或 did+ld+json）时返回 DID 文档，否则返回完整的解析结果
+	// did:web 需要服务端发起 HTTP 请求，按客户端 IP 限流
+	r.GET("/api/did/:did", rateLimitMiddleware(config.DID_RESOLVE_RATE_PER_MINUTE, time.Minute), func(c *gin.Context) {
+		accept := c.GetHeader("Accept")
+		doc, err := didResolver.Resolve(c.Request.Context(), c.Param("did"))
+		if err != nil {
+			status, code := http.StatusBadGateway, "internalError"
+			switch {
+			case errors.Is(err, errInvalidDID):
+				status, code = http.StatusBadRequest, err.Error()
+			case errors.Is(err, errDIDNotFound):
+				status, code = http.StatusNotFound, err.Error()
+			case errors.Is(err, errDIDMethodNotSupported):
+				status, code = http.StatusNotImplemented, err.Error()
+			case errors.Is(err, errDIDWebHostNotAllowed):
+				status, code = http.StatusForbidden, err.Error()
+			}
+			c.JSON(status, gin.H{
+				"didDocument":           nil,
+				"didResolutionMetadata": gin.H{"error": code, "message": err.Error()},
+				"didDocumentMetadata":   gin.H{},
+			})
+			return
+		}
+
+		for _, contentType := range []string{"application/did+ld+json", "application/did+json"} {
+			if strings.Contains(accept, contentType) {
+				data, _ := json.Marshal(doc)
+				c.Data(http.StatusOK, contentType, data)
+				return
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"didDocument":           doc,
+			"didResolutionMetadata": gin.H{"contentType": "application/did+json"},
+			"didDocumentMetadata":   gin.H{},
+		})
+	})
+
+	// 8. 平台签发者的 did:web 文档和 JWKS，供依赖方验证平台签发的令牌和凭证
+	r.GET("/.well-known/did.json", func(c *gin.Context) {
+		data, _ := json.Marshal(platformDIDDocument())
+		c.Header("Cache-Control", "public, max-age=300")
+		c.Data(http.StatusOK, "application/did+json", data)
+	})
+
+	r.GET("/.well-known/jwks.json", func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, platformJWKS())
+	})
+
+	// 9. 签发用户类型可验证凭证：证明当前 DID 是已验证的企业/个人/社区/机构/政府账户
+	r.POST("/api/credentials/issue", authMiddleware(), func(c *gin.Context) {
+		var input struct {
+			Format string `json:"format"` // jwt_vc_json（默认）/ ldp_vc
+		}
+		if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if input.Format == "" {
+			input.Format = credentialFormatJWT
+		}
+		if input.Format != credentialFormatJWT && input.Format != credentialFormatLDP {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的凭证格式: " + input.Format})
+			return
+		}
+
+		key := platformKeys.Current()
+		if key == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "平台签名密钥不可用"})
+			return
+		}
+
+		// 用户类型以数据库为准，不信任令牌中的声明
+		var user User
+		if err := DB.Where("did = ?", c.GetString("did")).First(&user).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+			return
+		}
+
+		now := time.Now()
+		vc, err := newUserTypeCredential(&user, now)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// 先保存签发记录，按记录ID分配状态列表位置，再签名；签名失败时回滚
+		var credential interface{}
+		err = DB.Transaction(func(tx *gorm.DB) error {
+			record := IssuedCredential{
+				CredentialID: vc.ID,
+				DID:          user.DID,
+				UserType:     user.UserType,
+				Format:       input.Format,
+				KeyID:        key.ID,
+				ExpiresAt:    now.Add(credentialTTL()),
+			}
+			if err := tx.Create(&record).Error; err != nil {
+				return err
+			}
+			record.StatusList, record.StatusIndex = allocateStatusIndex(record.ID)
+			if err := tx.Model(&record).Updates(map[string]interface{}{"status_list": record.StatusList, "status_index": record.StatusIndex}).Error; err != nil {
+				return err
+			}
+			vc.CredentialStatus = credentialStatusEntries(record.StatusList, record.StatusIndex)
+
+			if input.Format == credentialFormatJWT {
+				credential, err = signCredentialJWT(vc, key)
+				return err
+			}
+			credential = vc
+			return addDataIntegrityProof(vc, key, now)
+		})
+		if err != nil {
+			fmt.Printf("签发凭证失败: %s, %v\n", user.DID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "签发凭证失败"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"format":        input.Format,
+			"credential":    credential,
+			"credential_id": vc.ID,
+		})
+	})
+
+	// 10. 凭证状态列表（Bitstring Status List），公开访问
+	// 默认返回内嵌 Data Integrity 证明的凭证；Accept 为 application/vc+jwt 时返回 JWT-VC
+	r.GET("/api/credentials/status/:purpose/:list", func(c *gin.Context) {
+		list, err := strconv.ParseUint(c.Param("list"), 10, 32)
+		if err != nil || list == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "状态列表不存在"})
+			return
+		}
+		if _, err := statusListColumn(c.Param("purpose")); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "状态列表不存在"})
+			return
+		}
+
+		entry, err := statusLists.Get(c.Param("purpose"), uint(list))
+		if errors.Is(err, errStatusListNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "状态列表不存在"})
+			return
+		}
+		if err != nil {
+			fmt.Printf("生成状态列表失败: %v\n", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "生成状态列表失败"})
+			return
+		}
+
+		c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(statusListCacheTTL.Seconds())))
+		if strings.Contains(c.GetHeader("Accept"), "application/vc+jwt") {
+			c.Data(http.StatusOK, "application/vc+jwt", []byte(entry.jwt))
+			return
+		}
+		data, _ := json.Marshal(entry.credential)
+		c.Data(http.StatusOK, "application/vc+ld+json", data)
+	})
+
+	// 10.1. 管理接口：设置凭证状态位（撤销不可恢复，暂停可解除）
+	r.POST("/api/admin/credentials/status", adminMiddleware(), func(c *gin.Context) {
+		var input struct {
+			CredentialID string `json:"credential_id" binding:"required"`
+			Purpose      string `json:"purpose" binding:"required"` // revocation / suspension
+			Value        *bool  `json:"value" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		record, err := setCredentialStatus(DB, input.CredentialID, input.Purpose, *input.Value, statusReasonAdmin)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "凭证不存在"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"credential_id": record.CredentialID,
+			"purpose":       input.Purpose,
+			"value":         *input.Value,
+			"status_list":   statusListURL(input.Purpose, record.StatusList),
+			"status_index":  record.StatusIndex,
+		})
+	})
+
+	// 11. 管理接口：立即轮换登录令牌签名密钥（如怀疑密钥泄露），原密钥在宽限期内继续发布
+	r.POST("/api/admin/keys/rotate", adminMiddleware(), func(c *gin.Context) {
+		key, err := rotateTokenKey()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "轮换密钥失败: " + err.Error()})
+			return
+		}
+
+		keys := []gin.H{}
+		for _, k := range tokenKeys.VerificationKeys() {
+			entry := gin.H{"kid": k.ID, "alg": k.Algorithm, "created_at": k.CreatedAt}
+			if !k.RetiredAt.IsZero() {
+				entry["retired_at"] = k.RetiredAt
+			}
+			keys = append(keys, entry)
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"kid":               key.ID,
+			"alg":               key.Algorithm,
+			"verification_keys": keys,
+		})
+	})
+
+	// 12. 管理接口：变更用户类型，同时撤销该用户已签发的凭证并注销所有会话
+	r.PUT("/api/admin/users/:did/user-type", adminMiddleware(), func(c *gin.Context) {
+		var input struct {
+			UserType string `json:"user_type" binding:"required,max=20"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		err := changeUserType(DB, c.Param("did"), input.UserType)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "变更用户类型失败"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"did": c.Param("did"), "user_type": input.UserType})
+	})
+
+	// 12.1. 管理接口：删除账户，同时撤销该用户已签发的凭证并注销所有会话
+	r.DELETE("/api/admin/users/:did", adminMiddleware(), func(c *gin.Context) {
+		err := deleteAccount(DB, c.Param("did"))
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "删除账户失败"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "账户已删除", "did": c.Param("did")})
+	})
+
 	r.Run(":60208")
 }
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// setupAuthTest 测试期间使用临时的令牌签名密钥和空的会话黑名单
+func setupAuthTest(t *testing.T) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	savedKeys, savedRevoked := authTokens.keys, revokedSessions
+	t.Cleanup(func() { authTokens.keys, revokedSessions = savedKeys, savedRevoked })
+	revokedSessions = &sessionDenyList{revoked: map[string]time.Time{}}
+	authTokens.keys = newKeyStore(t.TempDir(), signingAlgES256, 0)
+	if err := authTokens.keys.Load(""); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOptionalAuthMiddleware(t *testing.T) {
+	setupAuthTest(t)
+
+	valid, err := authTokens.Issue("did:ethr:0x1:0xabc", "个人", "session-valid", amrPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoked, err := authTokens.Issue("did:ethr:0x1:0xabc", "个人", "session-revoked", amrPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revokedSessions.Add("session-revoked")
+
+	r := gin.New()
+	r.POST("/optional", optionalAuthMiddleware(), func(c *gin.Context) {
+		c.String(http.StatusOK, c.GetString("did"))
+	})
+	r.POST("/required", authMiddleware(), func(c *gin.Context) {
+		c.String(http.StatusOK, c.GetString("did"))
+	})
+
+	tests := []struct {
+		path   string
+		header string
+		status int
+		did    string
+	}{
+		{"/optional", "", http.StatusOK, ""},
+		{"/optional", "Bearer " + valid, http.StatusOK, "did:ethr:0x1:0xabc"},
+		{"/optional", "Bearer invalid", http.StatusUnauthorized, ""},
+		{"/optional", "Basic abc", http.StatusUnauthorized, ""},
+		{"/optional", "Bearer " + revoked, http.StatusUnauthorized, ""},
+		{"/required", "", http.StatusUnauthorized, ""},
+		{"/required", "Bearer " + valid, http.StatusOK, "did:ethr:0x1:0xabc"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, tt.path, nil)
+		if tt.header != "" {
+			req.Header.Set("Authorization", tt.header)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tt.status {
+			t.Errorf("%s %q: status = %d, want %d", tt.path, tt.header, w.Code, tt.status)
+			continue
+		}
+		if tt.status == http.StatusOK && w.Body.String() != tt.did {
+			t.Errorf("%s %q: did = %q, want %q", tt.path, tt.header, w.Body.String(), tt.did)
+		}
+	}
+}
+
+// 注销按 sid 生效：同一会话刷新后签发的令牌（jti 不同）全部被拒绝，其他会话不受影响
+func TestAuthMiddlewareRejectsEveryTokenOfRevokedSession(t *testing.T) {
+	setupAuthTest(t)
+
+	var sameSession []string
+	for i := 0; i < 2; i++ {
+		token, err := authTokens.Issue("did:ethr:0x1:0xabc", "个人", "session-a", amrPassword)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sameSession = append(sameSession, token)
+	}
+	otherSession, err := authTokens.Issue("did:ethr:0x1:0xabc", "个人", "session-b", amrPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revokedSessions.Add("session-a")
+
+	r := gin.New()
+	r.GET("/me", authMiddleware(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	status := func(token string) int {
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+	for i, token := range sameSession {
+		if got := status(token); got != http.StatusUnauthorized {
+			t.Errorf("已注销会话的第 %d 个令牌: status = %d, want 401", i+1, got)
+		}
+	}
+	if got := status(otherSession); got != http.StatusOK {
+		t.Errorf("其他会话的令牌: status = %d, want 200", got)
+	}
+}
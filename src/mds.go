@@ -0,0 +1,326 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cosmos-link/did-login/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// FIDO MDS3 认证器状态（FIDO Metadata Service v3.0 §3.1.4）
+const (
+	mdsStatusNotFIDOCertified          = "NOT_FIDO_CERTIFIED"
+	mdsStatusFIDOCertified             = "FIDO_CERTIFIED"
+	mdsStatusUserVerificationBypass    = "USER_VERIFICATION_BYPASS"
+	mdsStatusAttestationKeyCompromise  = "ATTESTATION_KEY_COMPROMISE"
+	mdsStatusUserKeyRemoteCompromise   = "USER_KEY_REMOTE_COMPROMISE"
+	mdsStatusUserKeyPhysicalCompromise = "USER_KEY_PHYSICAL_COMPROMISE"
+	mdsStatusRevoked                   = "REVOKED"
+)
+
+// 作为最新状态时拒绝注册的状态
+var mdsRejectedStatuses = map[string]bool{
+	mdsStatusUserVerificationBypass:    true,
+	mdsStatusAttestationKeyCompromise:  true,
+	mdsStatusUserKeyRemoteCompromise:   true,
+	mdsStatusUserKeyPhysicalCompromise: true,
+	mdsStatusRevoked:                   true,
+}
+
+// 认证等级，数值越大等级越高；FIDO_CERTIFIED 等同于 L1
+var mdsCertificationLevels = map[string]int{
+	mdsStatusFIDOCertified:  1,
+	"FIDO_CERTIFIED_L1":     1,
+	"FIDO_CERTIFIED_L1plus": 2,
+	"FIDO_CERTIFIED_L2":     3,
+	"FIDO_CERTIFIED_L2plus": 4,
+	"FIDO_CERTIFIED_L3":     5,
+	"FIDO_CERTIFIED_L3plus": 6,
+}
+
+// metadataStatusReport MDS3 statusReports 中的一项
+type metadataStatusReport struct {
+	Status        string `json:"status"`
+	EffectiveDate string `json:"effectiveDate"`
+}
+
+// metadataStatement 只保留用到的字段
+type metadataStatement struct {
+	Description                 string   `json:"description"`
+	AttestationRootCertificates []string `json:"attestationRootCertificates"` // base64 DER
+}
+
+// metadataEntry MDS3 blob 中的一个认证器条目
+type metadataEntry struct {
+	AAGUID                 string                 `json:"aaguid"`
+	MetadataStatement      metadataStatement      `json:"metadataStatement"`
+	StatusReports          []metadataStatusReport `json:"statusReports"`
+	TimeOfLastStatusChange string                 `json:"timeOfLastStatusChange"`
+}
+
+// metadataBlobPayload MDS3 blob 的 JWT 载荷
+type metadataBlobPayload struct {
+	LegalHeader string          `json:"legalHeader"`
+	No          int             `json:"no"`
+	NextUpdate  string          `json:"nextUpdate"`
+	Entries     []metadataEntry `json:"entries"`
+	jwt.RegisteredClaims
+}
+
+// LatestStatus 按生效日期取最新状态
+func (e *metadataEntry) LatestStatus() string {
+	if len(e.StatusReports) == 0 {
+		return ""
+	}
+	reports := append([]metadataStatusReport{}, e.StatusReports...)
+	sort.SliceStable(reports, func(i, j int) bool {
+		return reports[i].EffectiveDate < reports[j].EffectiveDate
+	})
+	return reports[len(reports)-1].Status
+}
+
+// CertificationLevel 状态报告中出现过的最高认证等级（0 表示未认证）
+// 最新状态为 NOT_FIDO_CERTIFIED 时视为认证已失效
+func (e *metadataEntry) CertificationLevel() int {
+	if e.LatestStatus() == mdsStatusNotFIDOCertified {
+		return 0
+	}
+	level := 0
+	for _, report := range e.StatusReports {
+		if l := mdsCertificationLevels[report.Status]; l > level {
+			level = l
+		}
+	}
+	return level
+}
+
+// RejectedStatus 最新状态为吊销、密钥泄露等时返回该状态，否则为空。
+// 只看最新的状态报告：厂商修复后会发布新的状态（如 FIDO_CERTIFIED、UPDATE_AVAILABLE），早先的报告不再生效
+func (e *metadataEntry) RejectedStatus() string {
+	if status := e.LatestStatus(); mdsRejectedStatuses[status] {
+		return status
+	}
+	return ""
+}
+
+// RootCertificates 该型号声明的证明根证书
+func (e *metadataEntry) RootCertificates() []*x509.Certificate {
+	var certs []*x509.Certificate
+	for _, encoded := range e.MetadataStatement.AttestationRootCertificates {
+		der, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			continue
+		}
+		if cert, err := x509.ParseCertificate(der); err == nil {
+			certs = append(certs, cert)
+		}
+	}
+	return certs
+}
+
+// metadataStore 已验证的 MDS3 blob，按 AAGUID 索引
+type metadataStore struct {
+	mu      sync.RWMutex
+	entries map[string]*metadataEntry
+	serial  int       // blob 序号，只接受不小于当前序号的 blob
+	modTime time.Time // 已加载文件的修改时间
+}
+
+// 全局元数据存储，未配置 blob 时为空
+var metadataService = &metadataStore{}
+
+// initMetadataService 加载 MDS3 blob，并按配置的间隔检查文件是否更新
+func initMetadataService() {
+	path := config.WEBAUTHN_MDS_BLOB_PATH
+	if path == "" {
+		fmt.Println("未配置 MDS blob，跳过认证器元数据加载")
+		return
+	}
+
+	if err := metadataService.Refresh(path); err != nil {
+		fmt.Printf("Warning: 加载 MDS blob 失败: %v\n", err)
+	}
+
+	interval := time.Duration(config.WEBAUTHN_MDS_REFRESH_MINUTES) * time.Minute
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := metadataService.Refresh(path); err != nil {
+				fmt.Printf("Warning: 刷新 MDS blob 失败: %v\n", err)
+			}
+		}
+	}()
+}
+
+// Refresh 文件有变化时重新加载并验证 blob；验证失败时保留原有数据
+func (s *metadataStore) Refresh(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	s.mu.RLock()
+	unchanged := s.entries != nil && info.ModTime().Equal(s.modTime)
+	s.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	roots, err := loadMDSRootCertificates(config.WEBAUTHN_MDS_ROOT_CERT)
+	if err != nil {
+		return err
+	}
+	payload, err := parseMetadataBlob(strings.TrimSpace(string(data)), roots)
+	if err != nil {
+		return err
+	}
+
+	s.mu.RLock()
+	serial := s.serial
+	s.mu.RUnlock()
+	if payload.No < serial {
+		return fmt.Errorf("MDS blob序号 %d 小于当前序号 %d", payload.No, serial)
+	}
+
+	entries := make(map[string]*metadataEntry, len(payload.Entries))
+	for i := range payload.Entries {
+		entry := &payload.Entries[i]
+		// 仅支持 FIDO2 认证器（按 AAGUID 索引），U2F/UAF 条目忽略
+		if entry.AAGUID == "" {
+			continue
+		}
+		entries[strings.ToLower(entry.AAGUID)] = entry
+	}
+
+	s.mu.Lock()
+	s.entries = entries
+	s.serial = payload.No
+	s.modTime = info.ModTime()
+	s.mu.Unlock()
+
+	fmt.Printf("✓ 已加载 MDS blob #%d，共 %d 个认证器条目（下次更新: %s）\n", payload.No, len(entries), payload.NextUpdate)
+	if next, err := time.Parse("2006-01-02", payload.NextUpdate); err == nil && time.Now().After(next.Add(24*time.Hour)) {
+		fmt.Printf("Warning: MDS blob 已过期（nextUpdate: %s），请更新文件\n", payload.NextUpdate)
+	}
+	return nil
+}
+
+// Loaded 是否已加载 blob
+func (s *metadataStore) Loaded() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.entries != nil
+}
+
+// Lookup 按 AAGUID（UUID 格式）查找条目
+func (s *metadataStore) Lookup(aaguid string) *metadataEntry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.entries[strings.ToLower(aaguid)]
+}
+
+// loadMDSRootCertificates 读取 MDS 签名根证书（PEM）
+func loadMDSRootCertificates(path string) (*x509.CertPool, error) {
+	if path == "" {
+		return nil, fmt.Errorf("未配置 MDS 根证书")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取 MDS 根证书失败: %v", err)
+	}
+
+	pool := x509.NewCertPool()
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("解析 MDS 根证书失败: %v", err)
+		}
+		pool.AddCert(cert)
+	}
+	return pool, nil
+}
+
+// parseMetadataBlob 验证 blob 的 JWS 签名：签名证书取自 x5c 头部，并必须链接到 MDS 根证书
+func parseMetadataBlob(blob string, roots *x509.CertPool) (*metadataBlobPayload, error) {
+	payload := &metadataBlobPayload{}
+	_, err := jwt.ParseWithClaims(blob, payload, func(token *jwt.Token) (interface{}, error) {
+		x5c, ok := token.Header["x5c"].([]interface{})
+		if !ok || len(x5c) == 0 {
+			return nil, fmt.Errorf("MDS blob缺少x5c")
+		}
+
+		var chain []*x509.Certificate
+		for i, item := range x5c {
+			encoded, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("x5c[%d]格式无效", i)
+			}
+			der, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				return nil, fmt.Errorf("x5c[%d]解码失败: %v", i, err)
+			}
+			cert, err := x509.ParseCertificate(der)
+			if err != nil {
+				return nil, fmt.Errorf("解析x5c[%d]失败: %v", i, err)
+			}
+			chain = append(chain, cert)
+		}
+
+		intermediates := x509.NewCertPool()
+		for _, cert := range chain[1:] {
+			intermediates.AddCert(cert)
+		}
+		if _, err := chain[0].Verify(x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: intermediates,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		}); err != nil {
+			return nil, fmt.Errorf("MDS签名证书链验证失败: %v", err)
+		}
+		return chain[0].PublicKey, nil
+	}, jwt.WithValidMethods([]string{"RS256", "ES256", "PS256"}))
+	if err != nil {
+		return nil, fmt.Errorf("MDS blob验证失败: %v", err)
+	}
+	return payload, nil
+}
+
+// checkAuthenticatorMetadata 按用户类型策略检查认证器型号的元数据状态
+func checkAuthenticatorMetadata(aaguid string, policy userTypePolicy) error {
+	entry := metadataService.Lookup(aaguid)
+
+	if entry != nil {
+		if status := entry.RejectedStatus(); status != "" {
+			return fmt.Errorf("认证器型号 %s 状态为 %s，不允许注册", aaguid, status)
+		}
+	}
+
+	if policy.MinCertificationLevel == "" {
+		return nil
+	}
+	if !metadataService.Loaded() {
+		return fmt.Errorf("认证器元数据不可用，无法校验认证等级")
+	}
+	if entry == nil {
+		return fmt.Errorf("认证器型号 %s 不在FIDO元数据中", aaguid)
+	}
+	if entry.CertificationLevel() < mdsCertificationLevels[policy.MinCertificationLevel] {
+		return fmt.Errorf("认证器型号 %s 未达到认证等级 %s", aaguid, policy.MinCertificationLevel)
+	}
+	return nil
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cosmos-link/did-login/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	mdsTestAAGUIDCertified = "ee882879-721c-4913-9775-3dfcce97072a"
+	mdsTestAAGUIDRevoked   = "0bb43545-fd2c-4185-87dd-feb0b2916ace"
+)
+
+// mdsTestPayload 固定的 MDS3 blob 载荷：一个已认证型号和一个已吊销型号
+func mdsTestPayload(no int) *metadataBlobPayload {
+	return &metadataBlobPayload{
+		LegalHeader: "did-login test blob",
+		No:          no,
+		NextUpdate:  time.Now().AddDate(0, 1, 0).Format("2006-01-02"),
+		Entries: []metadataEntry{
+			{
+				AAGUID:            mdsTestAAGUIDCertified,
+				MetadataStatement: metadataStatement{Description: "Test Key L1"},
+				StatusReports:     []metadataStatusReport{{Status: "FIDO_CERTIFIED_L1", EffectiveDate: "2024-01-10"}},
+			},
+			{
+				AAGUID:            mdsTestAAGUIDRevoked,
+				MetadataStatement: metadataStatement{Description: "Test Key Revoked"},
+				StatusReports: []metadataStatusReport{
+					{Status: "FIDO_CERTIFIED_L2", EffectiveDate: "2023-05-01"},
+					{Status: mdsStatusRevoked, EffectiveDate: "2024-03-01"},
+				},
+			},
+			{MetadataStatement: metadataStatement{Description: "U2F only"}}, // 没有 AAGUID 的条目忽略
+		},
+	}
+}
+
+// signMDSTestBlob 用 signer 签名 blob，x5c 为 signer 及中间证书
+func signMDSTestBlob(t *testing.T, payload *metadataBlobPayload, signer *testCert, chain ...*testCert) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, payload)
+	x5c := []string{base64.StdEncoding.EncodeToString(signer.cert.Raw)}
+	for _, c := range chain {
+		x5c = append(x5c, base64.StdEncoding.EncodeToString(c.cert.Raw))
+	}
+	token.Header["x5c"] = x5c
+	blob, err := token.SignedString(signer.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return blob
+}
+
+// mdsTestPKI 根证书 -> 中间证书 -> blob 签名证书
+func mdsTestPKI(t *testing.T) (root, intermediate, leaf *testCert) {
+	root = newTestCert(t, testCertTemplate("MDS Test Root", true), nil)
+	intermediate = newTestCert(t, testCertTemplate("MDS Test CA", true), root)
+	leaf = newTestCert(t, testCertTemplate("MDS Test Signer", false), intermediate)
+	return root, intermediate, leaf
+}
+
+func TestParseMetadataBlob(t *testing.T) {
+	root, intermediate, leaf := mdsTestPKI(t)
+	roots := x509.NewCertPool()
+	roots.AddCert(root.cert)
+
+	payload, err := parseMetadataBlob(signMDSTestBlob(t, mdsTestPayload(7), leaf, intermediate), roots)
+	if err != nil {
+		t.Fatalf("有效的 blob 被拒绝: %v", err)
+	}
+	if payload.No != 7 || len(payload.Entries) != 3 {
+		t.Fatalf("载荷解析错误: no=%d entries=%d", payload.No, len(payload.Entries))
+	}
+
+	otherRoot := newTestCert(t, testCertTemplate("Other Root", true), nil)
+	otherRoots := x509.NewCertPool()
+	otherRoots.AddCert(otherRoot.cert)
+
+	expiredTemplate := testCertTemplate("MDS Expired Signer", false)
+	expiredTemplate.NotBefore = time.Now().Add(-48 * time.Hour)
+	expiredTemplate.NotAfter = time.Now().Add(-time.Hour)
+	expired := newTestCert(t, expiredTemplate, intermediate)
+
+	valid := signMDSTestBlob(t, mdsTestPayload(7), leaf, intermediate)
+	// 用另一把密钥签名，但 x5c 仍是合法的签名证书
+	impostorKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	impostor := signMDSTestBlob(t, mdsTestPayload(7), &testCert{cert: leaf.cert, key: impostorKey}, intermediate)
+
+	tests := []struct {
+		name  string
+		blob  string
+		roots *x509.CertPool
+	}{
+		{"wrong root", valid, otherRoots},
+		{"missing intermediate", signMDSTestBlob(t, mdsTestPayload(7), leaf), roots},
+		{"bad signature", impostor, roots},
+		{"tampered payload", tamperJWTPayload(t, valid), roots},
+		{"expired leaf", signMDSTestBlob(t, mdsTestPayload(7), expired, intermediate), roots},
+		{"missing x5c", stripJWTHeader(t, valid), roots},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseMetadataBlob(tt.blob, tt.roots); err == nil {
+				t.Fatal("无效的 blob 被接受")
+			}
+		})
+	}
+}
+
+// tamperJWTPayload 替换 JWT 载荷（保留原签名）
+func tamperJWTPayload(t *testing.T, token string) string {
+	t.Helper()
+	parts := strings.Split(token, ".")
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload = []byte(strings.Replace(string(payload), mdsStatusRevoked, mdsStatusFIDOCertified, 1))
+	parts[1] = base64.RawURLEncoding.EncodeToString(payload)
+	return strings.Join(parts, ".")
+}
+
+// stripJWTHeader 替换为不含 x5c 的头部
+func stripJWTHeader(t *testing.T, token string) string {
+	t.Helper()
+	parts := strings.Split(token, ".")
+	parts[0] = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"ES256","typ":"JWT"}`))
+	return strings.Join(parts, ".")
+}
+
+// loadMDSTestStore 把 blob 写入临时文件，通过 Refresh 加载，并替换全局元数据存储
+func loadMDSTestStore(t *testing.T) *metadataStore {
+	t.Helper()
+	root, intermediate, leaf := mdsTestPKI(t)
+	blobPath := filepath.Join(t.TempDir(), "blob.jwt")
+	if err := os.WriteFile(blobPath, []byte(signMDSTestBlob(t, mdsTestPayload(7), leaf, intermediate)+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	savedRoot, savedStore := config.WEBAUTHN_MDS_ROOT_CERT, metadataService
+	t.Cleanup(func() { config.WEBAUTHN_MDS_ROOT_CERT, metadataService = savedRoot, savedStore })
+	config.WEBAUTHN_MDS_ROOT_CERT = writeTestCertPEM(t, root)
+
+	store := &metadataStore{}
+	if err := store.Refresh(blobPath); err != nil {
+		t.Fatal(err)
+	}
+	metadataService = store
+
+	// 序号更小的 blob 不能替换已加载的数据
+	older := filepath.Join(t.TempDir(), "older.jwt")
+	if err := os.WriteFile(older, []byte(signMDSTestBlob(t, mdsTestPayload(6), leaf, intermediate)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Refresh(older); err == nil {
+		t.Fatal("接受了序号回退的 blob")
+	}
+	return store
+}
+
+func TestMetadataStoreLookup(t *testing.T) {
+	store := loadMDSTestStore(t)
+	if !store.Loaded() {
+		t.Fatal("blob 未加载")
+	}
+	entry := store.Lookup(strings.ToUpper(mdsTestAAGUIDCertified))
+	if entry == nil || entry.MetadataStatement.Description != "Test Key L1" {
+		t.Fatalf("按 AAGUID 查找失败: %+v", entry)
+	}
+	if store.Lookup("00000000-0000-0000-0000-000000000000") != nil {
+		t.Fatal("不存在的 AAGUID 返回了条目")
+	}
+	if len(store.entries) != 2 {
+		t.Fatalf("条目数 = %d, want 2", len(store.entries))
+	}
+}
+
+func TestMetadataEntryRejectedStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		reports []metadataStatusReport
+		want    string
+	}{
+		{"certified", []metadataStatusReport{{Status: "FIDO_CERTIFIED_L1", EffectiveDate: "2024-01-01"}}, ""},
+		{"revoked", []metadataStatusReport{
+			{Status: "FIDO_CERTIFIED_L1", EffectiveDate: "2023-01-01"},
+			{Status: mdsStatusRevoked, EffectiveDate: "2024-01-01"},
+		}, mdsStatusRevoked},
+		// 按生效日期而不是数组顺序判断
+		{"key compromise listed first", []metadataStatusReport{
+			{Status: mdsStatusAttestationKeyCompromise, EffectiveDate: "2024-06-01"},
+			{Status: "FIDO_CERTIFIED_L1", EffectiveDate: "2023-01-01"},
+		}, mdsStatusAttestationKeyCompromise},
+		// 厂商修复后发布的新状态取代早先的问题报告
+		{"bypass fixed by update", []metadataStatusReport{
+			{Status: "FIDO_CERTIFIED_L1", EffectiveDate: "2022-01-01"},
+			{Status: mdsStatusUserVerificationBypass, EffectiveDate: "2023-01-01"},
+			{Status: "UPDATE_AVAILABLE", EffectiveDate: "2023-03-01"},
+		}, ""},
+		{"compromise superseded by recertification", []metadataStatusReport{
+			{Status: "FIDO_CERTIFIED_L2", EffectiveDate: "2024-02-01"},
+			{Status: mdsStatusUserKeyRemoteCompromise, EffectiveDate: "2023-11-15"},
+		}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := &metadataEntry{StatusReports: tt.reports}
+			if got := entry.RejectedStatus(); got != tt.want {
+				t.Fatalf("RejectedStatus() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckAuthenticatorMetadata(t *testing.T) {
+	loadMDSTestStore(t)
+	metadataService.entries["11111111-2222-3333-4444-555555555555"] = &metadataEntry{
+		AAGUID: "11111111-2222-3333-4444-555555555555",
+		StatusReports: []metadataStatusReport{
+			{Status: "FIDO_CERTIFIED_L1", EffectiveDate: "2022-01-01"},
+			{Status: mdsStatusNotFIDOCertified, EffectiveDate: "2024-01-01"},
+		},
+	}
+	unknown := "00000000-0000-0000-0000-000000000000"
+	requireL2 := userTypePolicy{MinCertificationLevel: "FIDO_CERTIFIED_L2"}
+
+	tests := []struct {
+		name   string
+		aaguid string
+		policy userTypePolicy
+		ok     bool
+	}{
+		{"certified, government", mdsTestAAGUIDCertified, policyForUserType("政府"), true},
+		{"certified, default policy", mdsTestAAGUIDCertified, defaultUserTypePolicy, true},
+		{"revoked, default policy", mdsTestAAGUIDRevoked, defaultUserTypePolicy, false},
+		{"revoked, institution", mdsTestAAGUIDRevoked, policyForUserType("机构"), false},
+		{"certification withdrawn", "11111111-2222-3333-4444-555555555555", policyForUserType("政府"), false},
+		{"below minimum level", mdsTestAAGUIDCertified, requireL2, false},
+		{"unknown, default policy", unknown, defaultUserTypePolicy, true},
+		{"unknown, government", unknown, policyForUserType("政府"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkAuthenticatorMetadata(tt.aaguid, tt.policy)
+			if (err == nil) != tt.ok {
+				t.Fatalf("checkAuthenticatorMetadata() = %v, want ok=%t", err, tt.ok)
+			}
+		})
+	}
+
+	// 未加载 blob 时，要求认证等级的用户类型一律拒绝
+	metadataService = &metadataStore{}
+	if err := checkAuthenticatorMetadata(mdsTestAAGUIDCertified, policyForUserType("政府")); err == nil {
+		t.Fatal("未加载元数据时接受了政府用户的认证器")
+	}
+}
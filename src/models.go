@@ -1,6 +1,7 @@
 package main
 
 import (
+	"strings"
 	"time"
 )
 
@@ -10,10 +11,12 @@ type User struct {
 	Email        string    `gorm:"type:varchar(255);uniqueIndex;not null"`
 	PasswordHash string    `gorm:"type:varchar(255);not null"`
 	UserType     string    `gorm:"type:varchar(20);not null;index"` // 企业, 个人, 社区, 机构, 政府
+	UserHandle   *string   `gorm:"type:varchar(64);uniqueIndex"`    // WebAuthn user.id（随机不透明句柄）
+	EmailVerifiedAt *time.Time // 邮箱验证时间，为空表示未验证
 	
-	// WebAuthn 指纹相关字段
+	// WebAuthn 指纹相关字段（旧版单凭证，已迁移至 ykt_webauthn_credentials，仅保留兼容）
 	CredentialID []byte    `gorm:"type:blob"`              // 凭证ID（base64编码后的数据）
-	PublicKey    []byte    `gorm:"type:blob"`              // 指纹公钥
+	PublicKey    []byte    `gorm:"type:blob"`              // 指纹公钥（COSE_Key，CBOR编码）
 	SignCount    uint32    `gorm:"default:0"`               // 签名计数器(防重放攻击)
 	
 	CreatedAt    time.Time `gorm:"autoCreateTime"`
@@ -25,6 +28,56 @@ func (User) TableName() string {
 	return "ykt_users"
 }
 
+// WebAuthnCredential WebAuthn凭证表 - 每个用户可注册多个通行密钥
+type WebAuthnCredential struct {
+	ID              uint       `gorm:"primaryKey;autoIncrement"`
+	DID             string     `gorm:"column:did;size:100;not null;index"`     // 关联 User.DID
+	CredentialID    string     `gorm:"type:varchar(255);uniqueIndex;not null"` // 凭证ID（base64url字符串）
+	PublicKey       []byte     `gorm:"type:blob;not null"`                     // COSE_Key（CBOR编码）
+	SignCount       uint32     `gorm:"default:0"`                              // 签名计数器(防重放攻击)
+	AAGUID          string     `gorm:"column:aaguid;type:varchar(36)"`         // 认证器型号标识
+	Transports      string     `gorm:"type:varchar(255)"`                      // 传输方式，逗号分隔（internal,usb,hybrid...）
+	BackupEligible  bool       `gorm:"default:false"`                          // BE: 凭证可被同步备份
+	BackupState     bool       `gorm:"default:false"`                          // BS: 凭证当前已同步备份
+	AttestationFmt  string     `gorm:"type:varchar(32)"`                       // 注册时的证明格式（packed, tpm, none...）
+	AttestationType string     `gorm:"type:varchar(16)"`                       // 证明类型（none/self/basic/anonca）
+	Nickname        string     `gorm:"type:varchar(100)"`                      // 用户自定义名称
+	CreatedAt       time.Time  `gorm:"autoCreateTime"`
+	LastUsedAt      *time.Time // 最近一次登录使用时间
+	RevokedAt       *time.Time `gorm:"index"` // 撤销时间，非空表示已撤销
+}
+
+// TableName 指定表名
+func (WebAuthnCredential) TableName() string {
+	return "ykt_webauthn_credentials"
+}
+
+// 凭证同步状态
+const (
+	syncStatusDeviceBound = "device_bound" // 不可备份，仅存在于当前设备
+	syncStatusNotSynced   = "not_synced"   // 可备份，但尚未同步
+	syncStatusSynced      = "synced"       // 已同步到云端（iCloud/Google 等）
+)
+
+// SyncStatus 根据 BE/BS 标志位返回同步状态
+func (c *WebAuthnCredential) SyncStatus() string {
+	switch {
+	case !c.BackupEligible:
+		return syncStatusDeviceBound
+	case c.BackupState:
+		return syncStatusSynced
+	}
+	return syncStatusNotSynced
+}
+
+// TransportList 返回传输方式列表
+func (c *WebAuthnCredential) TransportList() []string {
+	if c.Transports == "" {
+		return nil
+	}
+	return strings.Split(c.Transports, ",")
+}
+
 // Application App信息表
 type Application struct {
 	AppID         uint      `gorm:"primaryKey;autoIncrement"`
@@ -54,3 +107,78 @@ type AppPermission struct {
 func (AppPermission) TableName() string {
 	return "ykt_app_permissions"
 }
+
+// SecurityEvent 安全事件表（疑似克隆认证器、凭证重放等）
+type SecurityEvent struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement"`
+	DID       string    `gorm:"column:did;size:100;index"`
+	EventType string    `gorm:"type:varchar(50);not null;index"`
+	Detail    string    `gorm:"type:text"`
+	IP        string    `gorm:"type:varchar(64)"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// TableName 指定表名
+func (SecurityEvent) TableName() string {
+	return "ykt_security_events"
+}
+
+// IssuedCredential 平台签发的可验证凭证记录（用于审计和撤销）
+type IssuedCredential struct {
+	ID           uint       `gorm:"primaryKey;autoIncrement"`
+	CredentialID string     `gorm:"type:varchar(64);uniqueIndex;not null"` // 凭证ID（urn:uuid:...）
+	DID          string     `gorm:"column:did;size:100;not null;index"`    // 关联 User.DID
+	UserType     string     `gorm:"type:varchar(20);not null"`             // 签发时的用户类型
+	Format       string     `gorm:"type:varchar(16);not null"`             // jwt_vc_json / ldp_vc
+	KeyID        string     `gorm:"type:varchar(100);not null"`            // 签名密钥 kid
+	CreatedAt    time.Time  `gorm:"autoCreateTime"`
+	ExpiresAt    time.Time  `gorm:"not null"`
+	StatusList   uint       `gorm:"index:idx_status_position"` // 状态列表编号（Bitstring Status List），0 表示没有状态
+	StatusIndex  int        `gorm:"index:idx_status_position"` // 在状态列表中的位置
+	RevokedAt    *time.Time `gorm:"index"`                     // 撤销时间，非空表示已撤销
+	SuspendedAt  *time.Time // 暂停时间，非空表示已暂停
+	StatusReason string     `gorm:"type:varchar(32)"` // 最近一次状态变更原因
+}
+
+// TableName 指定表名
+func (IssuedCredential) TableName() string {
+	return "ykt_issued_credentials"
+}
+
+// RefreshToken 刷新令牌（只保存哈希）。同一次登录（会话）签发的刷新令牌属于同一个轮换族，
+// 每次刷新作废旧令牌并签发新令牌；已使用的令牌再次出现说明被盗用，撤销整个族
+type RefreshToken struct {
+	ID        uint       `gorm:"primaryKey;autoIncrement"`
+	TokenHash string     `gorm:"type:char(64);uniqueIndex;not null"` // SHA-256（hex）
+	FamilyID  string     `gorm:"type:varchar(64);not null;index"`    // 轮换族ID，即会话ID
+	DID       string     `gorm:"column:did;size:100;not null;index"` // 关联 User.DID
+	AMR       string     `gorm:"column:amr;type:varchar(64)"`        // 登录方式，逗号分隔，刷新后沿用
+	CreatedAt time.Time  `gorm:"autoCreateTime"`
+	ExpiresAt time.Time  `gorm:"not null;index"`
+	UsedAt    *time.Time // 已用于刷新的时间，非空表示已轮换
+	RevokedAt *time.Time // 撤销时间，非空表示已撤销
+}
+
+// TableName 指定表名
+func (RefreshToken) TableName() string {
+	return "ykt_refresh_tokens"
+}
+
+// Session 登录会话：一次登录及其后续刷新，ID 即访问令牌中的 sid
+type Session struct {
+	ID         string     `gorm:"primaryKey;type:varchar(64)"`        // 会话ID（登录时生成的令牌ID）
+	DID        string     `gorm:"column:did;size:100;not null;index"` // 关联 User.DID
+	Device     string     `gorm:"type:varchar(100)"`                  // 从 User-Agent 识别的设备
+	IP         string     `gorm:"type:varchar(64)"`                   // 登录或最近一次刷新的 IP
+	UserAgent  string     `gorm:"type:varchar(512)"`
+	AuthMethod string     `gorm:"type:varchar(64)"` // 登录方式（amr），逗号分隔
+	CreatedAt  time.Time  `gorm:"autoCreateTime"`
+	LastSeenAt time.Time  // 最近一次刷新时间
+	ExpiresAt  time.Time  `gorm:"not null;index"` // 随刷新令牌续期
+	RevokedAt  *time.Time `gorm:"index"`          // 注销时间，非空表示已注销
+}
+
+// TableName 指定表名
+func (Session) TableName() string {
+	return "ykt_sessions"
+}
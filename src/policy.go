@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/cosmos-link/did-login/config"
+)
+
+// userTypePolicy 按用户类型区分的认证器策略
+type userTypePolicy struct {
+	RequireAuthenticator  bool   // 是否必须保留至少一个通行密钥
+	AttestationPolicy     string // 证明策略（none/self/trusted），为空时使用配置的默认策略
+	MinCertificationLevel string // 要求的最低FIDO认证等级（如 FIDO_CERTIFIED_L1），为空表示不要求
+	RequireDeviceBound    bool   // 是否只允许设备绑定（不可同步备份）的凭证
+
+	// 认证器选择
+	AuthenticatorAttachment string   // platform / cross-platform，为空表示不限制
+	ResidentKey             string   // required / preferred / discouraged
+	UserVerification        string   // required / preferred / discouraged
+	Transports              []string // 允许的传输方式（internal, usb, nfc, ble, hybrid），为空表示不限制
+}
+
+// 用户验证要求（WebAuthn UserVerificationRequirement）
+const (
+	userVerificationRequired    = "required"
+	userVerificationPreferred   = "preferred"
+	userVerificationDiscouraged = "discouraged"
+)
+
+// 默认策略：未在表中出现的用户类型使用此策略
+// 允许漫游安全密钥和跨设备（hybrid）登录
+var defaultUserTypePolicy = userTypePolicy{
+	ResidentKey:      "required",
+	UserVerification: userVerificationPreferred,
+}
+
+// 用户类型策略表（企业, 个人, 社区, 机构, 政府）
+// 政府、机构用户必须使用经过FIDO认证、能链接到受信任根证书的认证器，并且每次都要验证用户
+// 政府用户的凭证不能同步到云端（iCloud 钥匙串、Google 密码管理器等）
+var userTypePolicies = map[string]userTypePolicy{
+	"机构": {
+		RequireAuthenticator:  true,
+		AttestationPolicy:     attestationPolicyTrusted,
+		MinCertificationLevel: "FIDO_CERTIFIED_L1",
+		ResidentKey:           "required",
+		UserVerification:      userVerificationRequired,
+	},
+	"政府": {
+		RequireAuthenticator:  true,
+		AttestationPolicy:     attestationPolicyTrusted,
+		MinCertificationLevel: "FIDO_CERTIFIED_L1",
+		ResidentKey:           "required",
+		UserVerification:      userVerificationRequired,
+		Transports:            []string{"internal", "usb", "nfc"},
+		RequireDeviceBound:    true,
+	},
+}
+
+// policyForUserType 获取用户类型对应的策略
+func policyForUserType(userType string) userTypePolicy {
+	if policy, ok := userTypePolicies[userType]; ok {
+		return policy
+	}
+	return defaultUserTypePolicy
+}
+
+// attestationPolicy 该用户类型实际使用的证明策略
+func (p userTypePolicy) attestationPolicy() string {
+	if p.AttestationPolicy != "" {
+		return p.AttestationPolicy
+	}
+	return config.WEBAUTHN_ATTESTATION_POLICY
+}
+
+// attestationConveyance 注册选项中的 attestation 参数
+// 策略需要校验证明时，至少请求 direct，否则认证器只会返回 none
+func (p userTypePolicy) attestationConveyance() string {
+	conveyance := config.WEBAUTHN_ATTESTATION
+	if p.attestationPolicy() != attestationPolicyNone && (conveyance == "" || conveyance == "none") {
+		return "direct"
+	}
+	if conveyance == "" {
+		return "none"
+	}
+	return conveyance
+}
+
+// requiresUserVerification 是否要求 authenticatorData 中设置 UV 标志位
+func (p userTypePolicy) requiresUserVerification() bool {
+	return p.UserVerification == userVerificationRequired
+}
+
+// checkBackupFlags 策略要求设备绑定凭证时，拒绝可同步备份（BE=1）的凭证
+func (p userTypePolicy) checkBackupFlags(authData *authenticatorData) error {
+	if p.RequireDeviceBound && authData.BackupEligible() {
+		return fmt.Errorf("该用户类型只允许使用设备绑定的凭证，不允许可同步的通行密钥")
+	}
+	return nil
+}
+
+// authenticatorSelection 注册选项中的 authenticatorSelection
+func (p userTypePolicy) authenticatorSelection() map[string]interface{} {
+	selection := map[string]interface{}{
+		"residentKey":        p.ResidentKey,
+		"requireResidentKey": p.ResidentKey == "required",
+		"userVerification":   p.UserVerification,
+	}
+	if p.AuthenticatorAttachment != "" {
+		selection["authenticatorAttachment"] = p.AuthenticatorAttachment
+	}
+	return selection
+}
+
+// allowedTransports 过滤出策略允许的传输方式
+// 凭证未记录传输方式时返回策略允许的列表（可能为空，表示由浏览器决定）
+func (p userTypePolicy) allowedTransports(transports []string) []string {
+	if len(transports) == 0 {
+		return p.Transports
+	}
+	if len(p.Transports) == 0 {
+		return transports
+	}
+
+	var allowed []string
+	for _, t := range transports {
+		if slices.Contains(p.Transports, t) {
+			allowed = append(allowed, t)
+		}
+	}
+	return allowed
+}
+
+// strictestUserVerification 无用户名登录时尚不知道用户类型，按所有策略中最严格的要求请求用户验证
+func strictestUserVerification() string {
+	if defaultUserTypePolicy.requiresUserVerification() {
+		return userVerificationRequired
+	}
+	for _, policy := range userTypePolicies {
+		if policy.requiresUserVerification() {
+			return userVerificationRequired
+		}
+	}
+	return defaultUserTypePolicy.UserVerification
+}
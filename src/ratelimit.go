@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 限流表中最多保留的客户端数，超过时先清理过期窗口
+const maxRateLimitClients = 10000
+
+// rateLimiter 按客户端的固定窗口限流（进程内计数，多副本部署时每个副本单独计数）
+type rateLimiter struct {
+	mu      sync.Mutex
+	limit   int
+	window  time.Duration
+	clients map[string]*rateWindow
+}
+
+// rateWindow 一个客户端在当前窗口内的请求数
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+func newRateLimiter(limit int, window time.Duration) *rateLimiter {
+	return &rateLimiter{limit: limit, window: window, clients: map[string]*rateWindow{}}
+}
+
+// Allow 记录一次请求，超过限额时返回 false 和距离窗口结束的时间
+func (l *rateLimiter) Allow(key string) (bool, time.Duration) {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	w, ok := l.clients[key]
+	if !ok || now.Sub(w.start) >= l.window {
+		if !ok && len(l.clients) >= maxRateLimitClients {
+			l.pruneLocked(now)
+		}
+		w = &rateWindow{start: now}
+		l.clients[key] = w
+	}
+	if w.count >= l.limit {
+		return false, w.start.Add(l.window).Sub(now)
+	}
+	w.count++
+	return true, 0
+}
+
+// pruneLocked 删除已结束的窗口；仍然过多时清空（宁可放过，也不无限增长）
+func (l *rateLimiter) pruneLocked(now time.Time) {
+	for key, w := range l.clients {
+		if now.Sub(w.start) >= l.window {
+			delete(l.clients, key)
+		}
+	}
+	if len(l.clients) >= maxRateLimitClients {
+		l.clients = map[string]*rateWindow{}
+	}
+}
+
+// rateLimitMiddleware 按客户端 IP 限流，超过限额返回 429；limit 为 0 时不限流
+func rateLimitMiddleware(limit int, window time.Duration) gin.HandlerFunc {
+	if limit <= 0 {
+		return func(c *gin.Context) { c.Next() }
+	}
+	limiter := newRateLimiter(limit, window)
+	return func(c *gin.Context) {
+		ok, retryAfter := limiter.Allow(c.ClientIP())
+		if !ok {
+			c.Header("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "请求过于频繁，请稍后再试"})
+			return
+		}
+		c.Next()
+	}
+}
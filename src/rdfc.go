@@ -0,0 +1,365 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RDF 项的种类
+const (
+	rdfIRI       = "iri"
+	rdfBlankNode = "blank"
+	rdfLiteral   = "literal"
+)
+
+// 哈希 N 度四元组的调用次数上限，防止特意构造的数据集（大量对称空白节点）耗尽 CPU
+const maxRDFCNDegreeCalls = 10000
+
+// rdfTerm RDF 项：IRI、空白节点（Value 为不含 _: 的标签）或字面量
+type rdfTerm struct {
+	Kind     string
+	Value    string
+	Datatype string // 字面量的数据类型，xsd:string 在序列化时省略
+}
+
+// rdfQuad RDF 四元组，Graph 为零值表示默认图
+type rdfQuad struct {
+	Subject   rdfTerm
+	Predicate rdfTerm
+	Object    rdfTerm
+	Graph     rdfTerm
+}
+
+// writeNQuadsTerm 按规范 N-Quads 格式写出一个 RDF 项
+func writeNQuadsTerm(b *strings.Builder, t rdfTerm) {
+	switch t.Kind {
+	case rdfIRI:
+		b.WriteString("<" + t.Value + ">")
+	case rdfBlankNode:
+		b.WriteString("_:" + t.Value)
+	case rdfLiteral:
+		b.WriteByte('"')
+		for _, r := range t.Value {
+			switch r {
+			case '"':
+				b.WriteString(`\"`)
+			case '\\':
+				b.WriteString(`\\`)
+			case '\n':
+				b.WriteString(`\n`)
+			case '\r':
+				b.WriteString(`\r`)
+			default:
+				b.WriteRune(r)
+			}
+		}
+		b.WriteByte('"')
+		if t.Datatype != "" && t.Datatype != xsdString {
+			b.WriteString("^^<" + t.Datatype + ">")
+		}
+	}
+}
+
+// serializeNQuad 规范 N-Quads 的一行（含换行符）
+func serializeNQuad(q rdfQuad) string {
+	var b strings.Builder
+	writeNQuadsTerm(&b, q.Subject)
+	b.WriteByte(' ')
+	writeNQuadsTerm(&b, q.Predicate)
+	b.WriteByte(' ')
+	writeNQuadsTerm(&b, q.Object)
+	if q.Graph.Kind != "" {
+		b.WriteByte(' ')
+		writeNQuadsTerm(&b, q.Graph)
+	}
+	b.WriteString(" .\n")
+	return b.String()
+}
+
+// sha256Hex 十六进制 SHA-256
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// rdfcIssuer 标识符发放器：按首次请求的顺序为空白节点发放 <前缀><序号>
+type rdfcIssuer struct {
+	prefix  string
+	counter int
+	issued  map[string]string
+	order   []string // 按发放顺序记录的原标识符
+}
+
+func newRDFCIssuer(prefix string) *rdfcIssuer {
+	return &rdfcIssuer{prefix: prefix, issued: map[string]string{}}
+}
+
+// Issue 返回已发放的标识符，未发放时发放新的
+func (i *rdfcIssuer) Issue(existing string) string {
+	if id, ok := i.issued[existing]; ok {
+		return id
+	}
+	id := i.prefix + strconv.Itoa(i.counter)
+	i.counter++
+	i.issued[existing] = id
+	i.order = append(i.order, existing)
+	return id
+}
+
+func (i *rdfcIssuer) clone() *rdfcIssuer {
+	c := &rdfcIssuer{prefix: i.prefix, counter: i.counter, issued: make(map[string]string, len(i.issued)), order: append([]string(nil), i.order...)}
+	for k, v := range i.issued {
+		c.issued[k] = v
+	}
+	return c
+}
+
+// rdfcState 规范化状态（RDFC-1.0 4.2）
+type rdfcState struct {
+	blankNodeQuads map[string][]rdfQuad // 空白节点 -> 出现该节点的四元组
+	canonical      *rdfcIssuer
+	nDegreeCalls   int
+}
+
+// canonicalizeRDF RDF 数据集规范化（W3C RDFC-1.0），返回排序后的规范 N-Quads
+func canonicalizeRDF(quads []rdfQuad) (string, error) {
+	state := &rdfcState{blankNodeQuads: map[string][]rdfQuad{}, canonical: newRDFCIssuer("c14n")}
+	for _, q := range quads {
+		for _, t := range []rdfTerm{q.Subject, q.Object, q.Graph} {
+			if t.Kind != rdfBlankNode {
+				continue
+			}
+			list := state.blankNodeQuads[t.Value]
+			if len(list) == 0 || list[len(list)-1] != q {
+				state.blankNodeQuads[t.Value] = append(list, q)
+			}
+		}
+	}
+
+	// 一度哈希唯一的空白节点直接按哈希顺序发放规范标识符
+	hashToBlankNodes := map[string][]string{}
+	for node := range state.blankNodeQuads {
+		hash := state.hashFirstDegreeQuads(node)
+		hashToBlankNodes[hash] = append(hashToBlankNodes[hash], node)
+	}
+	hashes := make([]string, 0, len(hashToBlankNodes))
+	for hash := range hashToBlankNodes {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+	var shared []string
+	for _, hash := range hashes {
+		if len(hashToBlankNodes[hash]) > 1 {
+			shared = append(shared, hash)
+			continue
+		}
+		state.canonical.Issue(hashToBlankNodes[hash][0])
+	}
+
+	// 一度哈希相同的空白节点按 N 度哈希区分
+	for _, hash := range shared {
+		type pathResult struct {
+			hash   string
+			issuer *rdfcIssuer
+		}
+		var results []pathResult
+		nodes := hashToBlankNodes[hash]
+		sort.Strings(nodes)
+		for _, node := range nodes {
+			if _, ok := state.canonical.issued[node]; ok {
+				continue
+			}
+			temporary := newRDFCIssuer("b")
+			temporary.Issue(node)
+			h, issuer, err := state.hashNDegreeQuads(node, temporary)
+			if err != nil {
+				return "", err
+			}
+			results = append(results, pathResult{h, issuer})
+		}
+		sort.SliceStable(results, func(i, j int) bool { return results[i].hash < results[j].hash })
+		for _, result := range results {
+			for _, existing := range result.issuer.order {
+				state.canonical.Issue(existing)
+			}
+		}
+	}
+
+	lines := make([]string, 0, len(quads))
+	seen := map[string]bool{}
+	relabel := func(t rdfTerm) rdfTerm {
+		if t.Kind == rdfBlankNode {
+			t.Value = state.canonical.issued[t.Value]
+		}
+		return t
+	}
+	for _, q := range quads {
+		line := serializeNQuad(rdfQuad{Subject: relabel(q.Subject), Predicate: q.Predicate, Object: relabel(q.Object), Graph: relabel(q.Graph)})
+		if !seen[line] {
+			seen[line] = true
+			lines = append(lines, line)
+		}
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, ""), nil
+}
+
+// hashFirstDegreeQuads 一度哈希：参考节点记为 _:a，其他空白节点记为 _:z（RDFC-1.0 4.6）
+func (s *rdfcState) hashFirstDegreeQuads(node string) string {
+	replace := func(t rdfTerm) rdfTerm {
+		if t.Kind == rdfBlankNode {
+			if t.Value == node {
+				t.Value = "a"
+			} else {
+				t.Value = "z"
+			}
+		}
+		return t
+	}
+	var lines []string
+	for _, q := range s.blankNodeQuads[node] {
+		lines = append(lines, serializeNQuad(rdfQuad{Subject: replace(q.Subject), Predicate: q.Predicate, Object: replace(q.Object), Graph: replace(q.Graph)}))
+	}
+	sort.Strings(lines)
+	return sha256Hex(strings.Join(lines, ""))
+}
+
+// hashRelatedBlankNode 相关空白节点的哈希（RDFC-1.0 4.7），position 为 s / o / g
+func (s *rdfcState) hashRelatedBlankNode(related string, q rdfQuad, issuer *rdfcIssuer, position string) string {
+	var identifier string
+	if id, ok := s.canonical.issued[related]; ok {
+		identifier = "_:" + id
+	} else if id, ok := issuer.issued[related]; ok {
+		identifier = "_:" + id
+	} else {
+		identifier = s.hashFirstDegreeQuads(related)
+	}
+	input := position
+	if position != "g" {
+		input += "<" + q.Predicate.Value + ">"
+	}
+	return sha256Hex(input + identifier)
+}
+
+// hashNDegreeQuads N 度哈希（RDFC-1.0 4.8）：遍历相关空白节点的所有排列，选择字典序最小的路径
+func (s *rdfcState) hashNDegreeQuads(node string, issuer *rdfcIssuer) (string, *rdfcIssuer, error) {
+	s.nDegreeCalls++
+	if s.nDegreeCalls > maxRDFCNDegreeCalls {
+		return "", nil, fmt.Errorf("RDF 规范化计算量超出上限")
+	}
+
+	hashToRelated := map[string][]string{}
+	for _, q := range s.blankNodeQuads[node] {
+		for _, c := range []struct {
+			term     rdfTerm
+			position string
+		}{{q.Subject, "s"}, {q.Object, "o"}, {q.Graph, "g"}} {
+			if c.term.Kind != rdfBlankNode || c.term.Value == node {
+				continue
+			}
+			hash := s.hashRelatedBlankNode(c.term.Value, q, issuer, c.position)
+			hashToRelated[hash] = append(hashToRelated[hash], c.term.Value)
+		}
+	}
+	relatedHashes := make([]string, 0, len(hashToRelated))
+	for hash := range hashToRelated {
+		relatedHashes = append(relatedHashes, hash)
+	}
+	sort.Strings(relatedHashes)
+
+	var data strings.Builder
+	for _, relatedHash := range relatedHashes {
+		data.WriteString(relatedHash)
+		chosenPath := ""
+		var chosenIssuer *rdfcIssuer
+
+		var permErr error
+		permute(hashToRelated[relatedHash], func(permutation []string) bool {
+			issuerCopy := issuer.clone()
+			path := ""
+			var recursion []string
+			skip := func() bool {
+				return chosenPath != "" && len(path) >= len(chosenPath) && path > chosenPath
+			}
+			for _, related := range permutation {
+				if id, ok := s.canonical.issued[related]; ok {
+					path += "_:" + id
+				} else {
+					if _, ok := issuerCopy.issued[related]; !ok {
+						recursion = append(recursion, related)
+					}
+					path += "_:" + issuerCopy.Issue(related)
+				}
+				if skip() {
+					return true
+				}
+			}
+			for _, related := range recursion {
+				hash, resultIssuer, err := s.hashNDegreeQuads(related, issuerCopy)
+				if err != nil {
+					permErr = err
+					return false
+				}
+				path += "_:" + issuerCopy.Issue(related) + "<" + hash + ">"
+				issuerCopy = resultIssuer
+				if skip() {
+					return true
+				}
+			}
+			if chosenPath == "" || path < chosenPath {
+				chosenPath = path
+				chosenIssuer = issuerCopy
+			}
+			return true
+		})
+		if permErr != nil {
+			return "", nil, permErr
+		}
+		data.WriteString(chosenPath)
+		issuer = chosenIssuer
+	}
+	return sha256Hex(data.String()), issuer, nil
+}
+
+// permute 依次以 items 的每个排列调用 visit（Heap 算法），visit 返回 false 时停止
+func permute(items []string, visit func([]string) bool) {
+	a := append([]string(nil), items...)
+	c := make([]int, len(a))
+	if !visit(append([]string(nil), a...)) {
+		return
+	}
+	for i := 0; i < len(a); {
+		if c[i] < i {
+			if i%2 == 0 {
+				a[0], a[i] = a[i], a[0]
+			} else {
+				a[c[i]], a[i] = a[i], a[c[i]]
+			}
+			if !visit(append([]string(nil), a...)) {
+				return
+			}
+			c[i]++
+			i = 0
+		} else {
+			c[i] = 0
+			i++
+		}
+	}
+}
+
+// canonicalizeJSONLD 将 JSON-LD 文档转换为 RDF 并按 RDFC-1.0 规范化
+func canonicalizeJSONLD(document interface{}) ([]byte, error) {
+	quads, err := jsonldToRDF(document)
+	if err != nil {
+		return nil, err
+	}
+	canonical, err := canonicalizeRDF(quads)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(canonical), nil
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func iri(v string) rdfTerm   { return rdfTerm{Kind: rdfIRI, Value: v} }
+func blank(v string) rdfTerm { return rdfTerm{Kind: rdfBlankNode, Value: v} }
+
+func TestCanonicalizeJSONLDProofConfig(t *testing.T) {
+	proofConfig := dataIntegrityProof{
+		Context:            []string{vcContextV2},
+		Type:               "DataIntegrityProof",
+		Cryptosuite:        cryptosuiteECDSARDFC,
+		Created:            "2026-01-01T00:00:00Z",
+		VerificationMethod: "did:web:example.com#key-1",
+		ProofPurpose:       "assertionMethod",
+	}
+	got, err := canonicalizeJSONLD(proofConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `_:c14n0 <http://purl.org/dc/terms/created> "2026-01-01T00:00:00Z"^^<http://www.w3.org/2001/XMLSchema#dateTime> .
+_:c14n0 <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <https://w3id.org/security#DataIntegrityProof> .
+_:c14n0 <https://w3id.org/security#cryptosuite> "ecdsa-rdfc-2019"^^<https://w3id.org/security#cryptosuiteString> .
+_:c14n0 <https://w3id.org/security#proofPurpose> <https://w3id.org/security#assertionMethod> .
+_:c14n0 <https://w3id.org/security#verificationMethod> <did:web:example.com#key-1> .
+`
+	if string(got) != want {
+		t.Fatalf("规范化结果:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestCanonicalizeJSONLDCredential(t *testing.T) {
+	vc := &verifiableCredential{
+		Context:   []string{vcContextV2},
+		ID:        "urn:uuid:0b8a5a4e-7c1f-4f57-9d0a-3f2a2b6a1c11",
+		Type:      []string{"VerifiableCredential", credentialTypeUserType},
+		Issuer:    "did:web:example.com",
+		ValidFrom: "2026-01-01T00:00:00Z",
+		CredentialSubject: map[string]interface{}{
+			"id":            "did:ethr:0x1:0xabc",
+			"userType":      "企业",
+			"emailVerified": true,
+		},
+	}
+	got, err := canonicalizeJSONLD(vc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 类型作用域上下文不传播到 credentialSubject，其中的属性按 @vocab 展开
+	want := `<did:ethr:0x1:0xabc> <https://www.w3.org/ns/credentials/issuer-dependent#emailVerified> "true"^^<http://www.w3.org/2001/XMLSchema#boolean> .
+<did:ethr:0x1:0xabc> <https://www.w3.org/ns/credentials/issuer-dependent#userType> "企业" .
+<urn:uuid:0b8a5a4e-7c1f-4f57-9d0a-3f2a2b6a1c11> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <https://www.w3.org/2018/credentials#VerifiableCredential> .
+<urn:uuid:0b8a5a4e-7c1f-4f57-9d0a-3f2a2b6a1c11> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <https://www.w3.org/ns/credentials/issuer-dependent#UserTypeCredential> .
+<urn:uuid:0b8a5a4e-7c1f-4f57-9d0a-3f2a2b6a1c11> <https://www.w3.org/2018/credentials#credentialSubject> <did:ethr:0x1:0xabc> .
+<urn:uuid:0b8a5a4e-7c1f-4f57-9d0a-3f2a2b6a1c11> <https://www.w3.org/2018/credentials#issuer> <did:web:example.com> .
+<urn:uuid:0b8a5a4e-7c1f-4f57-9d0a-3f2a2b6a1c11> <https://www.w3.org/2018/credentials#validFrom> "2026-01-01T00:00:00Z"^^<http://www.w3.org/2001/XMLSchema#dateTime> .
+`
+	if string(got) != want {
+		t.Fatalf("规范化结果:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestCanonicalizeJSONLDRejectsUnsupportedDocuments(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  map[string]interface{}
+	}{
+		{"other context", map[string]interface{}{"@context": "https://www.w3.org/2018/credentials/v1", "id": "urn:x"}},
+		{"relative id", map[string]interface{}{"@context": vcContextV2, "id": "relative"}},
+		{"fractional number", map[string]interface{}{"@context": vcContextV2, "id": "urn:x", "score": 1.5}},
+		{"value object", map[string]interface{}{"@context": vcContextV2, "id": "urn:x", "score": map[string]interface{}{"@value": "1"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := canonicalizeJSONLD(tt.doc); err == nil {
+				t.Fatal("不支持的文档被接受")
+			}
+		})
+	}
+}
+
+func TestCanonicalizeRDFEscapesLiterals(t *testing.T) {
+	got, err := canonicalizeRDF([]rdfQuad{{
+		Subject:   iri("urn:x"),
+		Predicate: iri("urn:p"),
+		Object:    rdfTerm{Kind: rdfLiteral, Value: "a\"b\\c\nd\re\tf", Datatype: xsdString},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "<urn:x> <urn:p> \"a\\\"b\\\\c\\nd\\re\tf\" .\n"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+// 同构的数据集（空白节点标签和四元组顺序不同）规范化结果必须相同
+func TestCanonicalizeRDFIsInvariantUnderRelabeling(t *testing.T) {
+	p, q := iri("urn:p"), iri("urn:q")
+	datasets := map[string][][]rdfQuad{
+		// 三个空白节点组成的环：一度哈希全部相同，需要 N 度哈希区分
+		"cycle": {
+			{{Subject: blank("a"), Predicate: p, Object: blank("b")}, {Subject: blank("b"), Predicate: p, Object: blank("c")}, {Subject: blank("c"), Predicate: p, Object: blank("a")}},
+			{{Subject: blank("z"), Predicate: p, Object: blank("x")}, {Subject: blank("y"), Predicate: p, Object: blank("z")}, {Subject: blank("x"), Predicate: p, Object: blank("y")}},
+		},
+		// 两个结构相同、只在字面量上不同的分支
+		"branches": {
+			{
+				{Subject: blank("root"), Predicate: p, Object: blank("l")},
+				{Subject: blank("root"), Predicate: p, Object: blank("r")},
+				{Subject: blank("l"), Predicate: q, Object: rdfTerm{Kind: rdfLiteral, Value: "left"}},
+				{Subject: blank("r"), Predicate: q, Object: rdfTerm{Kind: rdfLiteral, Value: "right"}},
+			},
+			{
+				{Subject: blank("n2"), Predicate: q, Object: rdfTerm{Kind: rdfLiteral, Value: "right"}},
+				{Subject: blank("n0"), Predicate: p, Object: blank("n2")},
+				{Subject: blank("n1"), Predicate: q, Object: rdfTerm{Kind: rdfLiteral, Value: "left"}},
+				{Subject: blank("n0"), Predicate: p, Object: blank("n1")},
+			},
+		},
+	}
+	for name, variants := range datasets {
+		t.Run(name, func(t *testing.T) {
+			first, err := canonicalizeRDF(variants[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, variant := range variants[1:] {
+				got, err := canonicalizeRDF(variant)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != first {
+					t.Fatalf("规范化结果不一致:\n%s\n%s", first, got)
+				}
+			}
+			if strings.Contains(first, "_:a") || strings.Contains(first, "_:n") || !strings.Contains(first, "_:c14n0") {
+				t.Fatalf("空白节点未重新标记:\n%s", first)
+			}
+		})
+	}
+}
+
+func TestDataIntegrityProofRDFC(t *testing.T) {
+	saved := platformKeys
+	defer func() { platformKeys = saved }()
+
+	for _, algorithm := range []string{signingAlgES256, signingAlgEdDSA} {
+		t.Run(algorithm, func(t *testing.T) {
+			platformKeys = newKeyStore(t.TempDir(), algorithm, 0)
+			if err := platformKeys.Load(""); err != nil {
+				t.Fatal(err)
+			}
+			key := platformKeys.Current()
+			now := time.Now()
+
+			vc := &verifiableCredential{
+				Context:           []string{vcContextV2},
+				ID:                newCredentialID(),
+				Type:              []string{"VerifiableCredential", credentialTypeUserType},
+				Issuer:            "did:web:example.com",
+				ValidFrom:         now.UTC().Format(time.RFC3339),
+				CredentialSubject: map[string]interface{}{"id": "did:ethr:0x1:0xabc", "userType": "个人", "emailVerified": false},
+				CredentialStatus:  credentialStatusEntries(1, 42),
+			}
+			if err := addDataIntegrityProof(vc, key, now); err != nil {
+				t.Fatal(err)
+			}
+			if want := cryptosuitesForAlgorithm(algorithm)[0]; vc.Proof.Cryptosuite != want {
+				t.Fatalf("cryptosuite = %s, want %s", vc.Proof.Cryptosuite, want)
+			}
+			if err := verifyDataIntegrityProof(vc); err != nil {
+				t.Fatalf("验证证明失败: %v", err)
+			}
+
+			// 文档内容变化会改变 RDF 数据集，证明失效
+			vc.CredentialSubject["userType"] = "企业"
+			if err := verifyDataIntegrityProof(vc); err == nil {
+				t.Fatal("篡改后的凭证验证通过")
+			}
+			vc.CredentialSubject["userType"] = "个人"
+
+			// 密码套件与密钥算法不符
+			other := cryptosuitesForAlgorithm(signingAlgES256)[0]
+			if algorithm == signingAlgES256 {
+				other = cryptosuitesForAlgorithm(signingAlgEdDSA)[0]
+			}
+			proof := *vc.Proof
+			vc.Proof.Cryptosuite = other
+			if err := verifyDataIntegrityProof(vc); err == nil {
+				t.Fatal("密码套件与密钥不匹配时验证通过")
+			}
+			vc.Proof = &proof
+		})
+	}
+}
+
+func TestDataIntegrityProofLegacyJCS(t *testing.T) {
+	saved := platformKeys
+	defer func() { platformKeys = saved }()
+	platformKeys = newKeyStore(t.TempDir(), signingAlgES256, 0)
+	if err := platformKeys.Load(""); err != nil {
+		t.Fatal(err)
+	}
+	key := platformKeys.Current()
+
+	vc := &verifiableCredential{
+		Context:           []string{vcContextV2},
+		ID:                newCredentialID(),
+		Type:              []string{"VerifiableCredential", credentialTypeUserType},
+		Issuer:            "did:web:example.com",
+		ValidFrom:         "2026-01-01T00:00:00Z",
+		CredentialSubject: map[string]interface{}{"id": "did:ethr:0x1:0xabc", "userType": "个人"},
+	}
+	if err := addDataIntegrityProof(vc, key, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	// 以早期的 ecdsa-jcs-2019 重新签名
+	vc.Proof.Cryptosuite = cryptosuiteECDSAJCS
+	vc.Proof.ProofValue = ""
+	proof := vc.Proof
+	hashData, err := dataIntegrityHashData(vc, proof)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := key.Sign(hashData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof.ProofValue = "z" + base58Encode(sig)
+
+	if err := verifyDataIntegrityProof(vc); err != nil {
+		t.Fatalf("早期 JCS 证明验证失败: %v", err)
+	}
+}
+
+func TestStatusListCredentialCanonicalizes(t *testing.T) {
+	encoded, err := encodeStatusList([]int{3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	listURL := statusListURL(statusPurposeRevocation, 1)
+	vc := &verifiableCredential{
+		Context:   []string{vcContextV2},
+		ID:        listURL,
+		Type:      []string{"VerifiableCredential", "BitstringStatusListCredential"},
+		Issuer:    "did:web:example.com",
+		ValidFrom: "2026-01-01T00:00:00Z",
+		CredentialSubject: map[string]interface{}{
+			"id":            listURL + "#list",
+			"type":          "BitstringStatusList",
+			"statusPurpose": statusPurposeRevocation,
+			"encodedList":   encoded,
+		},
+	}
+	got, err := canonicalizeJSONLD(vc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"<" + listURL + "#list> <https://www.w3.org/ns/credentials/status#encodedList> \"" + encoded + "\"^^<https://w3id.org/security#multibase> .\n",
+		"<" + listURL + "#list> <https://www.w3.org/ns/credentials/status#statusPurpose> \"revocation\" .\n",
+		"<" + listURL + "> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <https://www.w3.org/ns/credentials/status#BitstringStatusListCredential> .\n",
+	} {
+		if !strings.Contains(string(got), want) {
+			t.Errorf("缺少 %q:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cosmos-link/did-login/config"
+	"gorm.io/gorm"
+)
+
+// 刷新失败的原因
+var (
+	errRefreshTokenInvalid = errors.New("刷新令牌无效或已过期")
+	errRefreshTokenReused  = errors.New("刷新令牌已被使用，已撤销该登录的所有令牌")
+)
+
+// tokenPair 登录或刷新后返回给客户端的令牌
+type tokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    int // 访问令牌有效期（秒）
+}
+
+// hashRefreshToken 刷新令牌只以 SHA-256 哈希保存（令牌本身是高熵随机串，不需要加盐）
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// refreshTokenTTL 刷新令牌有效期
+func refreshTokenTTL() time.Duration {
+	return time.Duration(config.JWT_REFRESH_TTL_DAYS) * 24 * time.Hour
+}
+
+// newRefreshToken 在轮换族中创建一个刷新令牌，返回明文令牌
+func newRefreshToken(tx *gorm.DB, familyID string, did string, amr []string) (string, error) {
+	token := generateChallenge()
+	record := RefreshToken{
+		TokenHash: hashRefreshToken(token),
+		FamilyID:  familyID,
+		DID:       did,
+		AMR:       strings.Join(amr, ","),
+		ExpiresAt: time.Now().Add(refreshTokenTTL()),
+	}
+	if err := tx.Create(&record).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// issueTokenPair 登录成功后创建会话，签发访问令牌和会话轮换族的第一个刷新令牌
+func issueTokenPair(user *User, ip string, userAgent string, amr ...string) (*tokenPair, error) {
+	if user.DID == "" {
+		return nil, fmt.Errorf("用户未绑定 DID")
+	}
+	var session *Session
+	var refreshToken string
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if session, err = createSession(tx, user.DID, ip, userAgent, amr); err != nil {
+			return err
+		}
+		refreshToken, err = newRefreshToken(tx, session.ID, user.DID, amr)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	accessToken, err := authTokens.Issue(user.DID, user.UserType, session.ID, amr...)
+	if err != nil {
+		return nil, err
+	}
+	return &tokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(authTokens.ttl.Seconds()),
+	}, nil
+}
+
+// refreshTokenPair 用刷新令牌换取新的令牌对，旧刷新令牌随即作废
+// 已作废的令牌再次使用时注销整个会话（撤销轮换族），返回 errRefreshTokenReused
+func refreshTokenPair(presented string, ip string) (*tokenPair, *User, error) {
+	var record RefreshToken
+	if err := DB.Where("token_hash = ?", hashRefreshToken(presented)).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, errRefreshTokenInvalid
+		}
+		return nil, nil, err
+	}
+	if record.RevokedAt != nil || time.Now().After(record.ExpiresAt) {
+		return nil, nil, errRefreshTokenInvalid
+	}
+
+	var user User
+	var refreshToken string
+	reused := false
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		// 条件更新保证并发请求中只有一个能使用该令牌
+		result := tx.Model(&RefreshToken{}).
+			Where("id = ? AND used_at IS NULL AND revoked_at IS NULL", record.ID).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			reused = true
+			return nil
+		}
+
+		// 轮换族ID即会话ID，会话已注销时不能再续期
+		now := time.Now()
+		result = tx.Model(&Session{}).
+			Where("id = ? AND revoked_at IS NULL", record.FamilyID).
+			Updates(map[string]interface{}{"last_seen_at": now, "ip": ip, "expires_at": now.Add(refreshTokenTTL())})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		if err := tx.Where("did = ?", record.DID).First(&user).Error; err != nil {
+			return err
+		}
+		var err error
+		refreshToken, err = newRefreshToken(tx, record.FamilyID, record.DID, strings.Split(record.AMR, ","))
+		return err
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, errRefreshTokenInvalid
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if reused {
+		if err := revokeSession(DB, record.FamilyID); err != nil {
+			return nil, nil, err
+		}
+		recordSecurityEvent(record.DID, securityEventRefreshTokenReuse,
+			fmt.Sprintf("family=%s token_id=%d", record.FamilyID, record.ID), ip)
+		return nil, nil, errRefreshTokenReused
+	}
+
+	// 用户类型以数据库中的当前值为准
+	accessToken, err := authTokens.Issue(user.DID, user.UserType, record.FamilyID, strings.Split(record.AMR, ",")...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &tokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(authTokens.ttl.Seconds()),
+	}, &user, nil
+}
+
+// revokeRefreshTokenFamily 撤销轮换族中所有未撤销的刷新令牌
+func revokeRefreshTokenFamily(tx *gorm.DB, familyID string) error {
+	result := tx.Model(&RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	fmt.Printf("已撤销刷新令牌族 %s 的 %d 个令牌\n", familyID, result.RowsAffected)
+	return nil
+}
+
+// revokeRefreshTokensForDID 撤销某个 DID 的所有刷新令牌
+func revokeRefreshTokensForDID(tx *gorm.DB, did string) error {
+	return tx.Model(&RefreshToken{}).
+		Where("did = ? AND revoked_at IS NULL", did).
+		Update("revoked_at", time.Now()).Error
+}
+
+// initRefreshTokenCleanup 定期删除已过期的刷新令牌
+func initRefreshTokenCleanup() {
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			result := DB.Where("expires_at < ?", time.Now()).Delete(&RefreshToken{})
+			if result.Error != nil {
+				fmt.Printf("Warning: 清理过期刷新令牌失败: %v\n", result.Error)
+			}
+		}
+	}()
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 拒绝列表从数据库重新加载的间隔（多副本部署时其他副本注销的会话最迟在此时间后被拒绝）
+const sessionDenyListRefresh = 30 * time.Second
+
+// sessionDenyList 已注销会话的缓存。访问令牌最长有效期为 authTokens.ttl，
+// 因此只需要记住最近 ttl 内注销的会话；更早注销的会话只能通过刷新令牌续期，而刷新令牌已一并撤销。
+//
+// 列表按会话ID（令牌中的 sid）而不是 jti 记录：一次登录在刷新时会签发多个访问令牌（jti 各不相同），
+// 注销的对象是整个登录会话，按 sid 拒绝即可覆盖该会话已签发和正在有效期内的所有令牌，
+// 不需要为每个签发的 jti 写一条记录。单个 jti 不会被单独注销
+type sessionDenyList struct {
+	mu      sync.RWMutex
+	revoked map[string]time.Time // 会话ID -> 可以从列表中移除的时间
+}
+
+var revokedSessions = &sessionDenyList{revoked: map[string]time.Time{}}
+
+// Revoked 会话是否已注销
+func (d *sessionDenyList) Revoked(sessionID string) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	_, ok := d.revoked[sessionID]
+	return ok
+}
+
+// Add 本副本注销会话后立即加入列表，不等待下一次重新加载
+func (d *sessionDenyList) Add(sessionIDs ...string) {
+	until := time.Now().Add(authTokens.ttl)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, id := range sessionIDs {
+		d.revoked[id] = until
+	}
+}
+
+// Reload 从数据库加载最近 ttl 内注销的会话，替换整个列表
+func (d *sessionDenyList) Reload() error {
+	var sessions []Session
+	if err := DB.Select("id", "revoked_at").
+		Where("revoked_at > ?", time.Now().Add(-authTokens.ttl)).
+		Find(&sessions).Error; err != nil {
+		return err
+	}
+	revoked := make(map[string]time.Time, len(sessions))
+	for _, session := range sessions {
+		revoked[session.ID] = session.RevokedAt.Add(authTokens.ttl)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	// 保留本副本刚加入、尚未写入或尚未读到的记录
+	now := time.Now()
+	for id, until := range d.revoked {
+		if _, ok := revoked[id]; !ok && now.Before(until) {
+			revoked[id] = until
+		}
+	}
+	d.revoked = revoked
+	return nil
+}
+
+// initSessions 加载已注销会话列表，并定期重新加载、清理过期会话
+func initSessions() {
+	if err := revokedSessions.Reload(); err != nil {
+		fmt.Printf("Warning: 加载已注销会话失败: %v\n", err)
+	}
+	go func() {
+		ticker := time.NewTicker(sessionDenyListRefresh)
+		defer ticker.Stop()
+		lastCleanup := time.Now()
+		for range ticker.C {
+			if err := revokedSessions.Reload(); err != nil {
+				fmt.Printf("Warning: 重新加载已注销会话失败: %v\n", err)
+			}
+			if time.Since(lastCleanup) < time.Hour {
+				continue
+			}
+			lastCleanup = time.Now()
+			if err := DB.Where("expires_at < ?", time.Now()).Delete(&Session{}).Error; err != nil {
+				fmt.Printf("Warning: 清理过期会话失败: %v\n", err)
+			}
+		}
+	}()
+}
+
+// createSession 登录成功时创建会话，会话ID同时作为刷新令牌的轮换族ID
+func createSession(tx *gorm.DB, did string, ip string, userAgent string, amr []string) (*Session, error) {
+	now := time.Now()
+	if len(userAgent) > 512 {
+		userAgent = userAgent[:512]
+	}
+	session := &Session{
+		ID:         newTokenID(),
+		DID:        did,
+		Device:     describeDevice(userAgent),
+		IP:         ip,
+		UserAgent:  userAgent,
+		AuthMethod: strings.Join(amr, ","),
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(refreshTokenTTL()),
+	}
+	if err := tx.Create(session).Error; err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// revokeSession 注销单个会话：撤销其刷新令牌，并拒绝已签发的访问令牌
+func revokeSession(tx *gorm.DB, sessionID string) error {
+	if err := tx.Model(&Session{}).
+		Where("id = ? AND revoked_at IS NULL", sessionID).
+		Update("revoked_at", time.Now()).Error; err != nil {
+		return err
+	}
+	if err := revokeRefreshTokenFamily(tx, sessionID); err != nil {
+		return err
+	}
+	revokedSessions.Add(sessionID)
+	return nil
+}
+
+// revokeSessionsForDID 注销某个 DID 的所有会话（退出所有设备、重置密码、删除账户时），返回注销的会话数
+func revokeSessionsForDID(tx *gorm.DB, did string) (int, error) {
+	var ids []string
+	if err := tx.Model(&Session{}).
+		Where("did = ? AND revoked_at IS NULL", did).
+		Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	if len(ids) > 0 {
+		if err := tx.Model(&Session{}).
+			Where("id IN ?", ids).
+			Update("revoked_at", time.Now()).Error; err != nil {
+			return 0, err
+		}
+	}
+	if err := revokeRefreshTokensForDID(tx, did); err != nil {
+		return 0, err
+	}
+	revokedSessions.Add(ids...)
+	return len(ids), nil
+}
+
+// describeDevice 从 User-Agent 粗略识别设备，仅用于在会话列表中展示
+func describeDevice(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+	if ua == "" {
+		return "未知设备"
+	}
+
+	platform := "未知系统"
+	for _, p := range []struct{ marker, name string }{
+		{"iphone", "iPhone"},
+		{"ipad", "iPad"},
+		{"android", "Android"},
+		{"windows", "Windows"},
+		{"mac os", "macOS"},
+		{"cros", "ChromeOS"},
+		{"linux", "Linux"},
+	} {
+		if strings.Contains(ua, p.marker) {
+			platform = p.name
+			break
+		}
+	}
+
+	// 顺序有意义：Edge、Opera 的 UA 同时包含 Chrome，Chrome 的 UA 同时包含 Safari
+	browser := "其他客户端"
+	for _, b := range []struct{ marker, name string }{
+		{"edg/", "Edge"},
+		{"opr/", "Opera"},
+		{"firefox/", "Firefox"},
+		{"chrome/", "Chrome"},
+		{"safari/", "Safari"},
+		{"curl/", "curl"},
+	} {
+		if strings.Contains(ua, b.marker) {
+			browser = b.name
+			break
+		}
+	}
+	return browser + " / " + platform
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cosmos-link/did-login/config"
+)
+
+// SIWE 消息中允许的时钟偏差
+const siweClockSkew = time.Minute
+
+// SIWE 消息有效期（与挑战有效期一致）
+const siweMessageTTL = challengeTTL
+
+const siweHeaderSuffix = " wants you to sign in with your Ethereum account:"
+
+// siweMessage EIP-4361 Sign-In with Ethereum 消息
+type siweMessage struct {
+	Domain         string
+	Address        string // EIP-55 校验和格式
+	Statement      string
+	URI            string
+	Version        string
+	ChainID        int64
+	Nonce          string
+	IssuedAt       string
+	ExpirationTime string
+	NotBefore      string
+	RequestID      string
+	Resources      []string
+}
+
+// generateSIWENonce 生成字母数字组成的随机 nonce（EIP-4361 要求至少 8 位字母数字）
+func generateSIWENonce() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// toChecksumAddress EIP-55 大小写校验和地址
+func toChecksumAddress(address string) (string, error) {
+	normalized, err := normalizeEthereumAddress(address)
+	if err != nil {
+		return "", err
+	}
+	lower := normalized[2:]
+	hash := hex.EncodeToString(keccak256([]byte(lower)))
+
+	out := []byte(lower)
+	for i, ch := range out {
+		if ch >= 'a' && ch <= 'f' && hash[i] >= '8' {
+			out[i] = ch - 'a' + 'A'
+		}
+	}
+	return "0x" + string(out), nil
+}
+
+// String 按 EIP-4361 格式生成待签名消息
+func (m *siweMessage) String() string {
+	var b strings.Builder
+	b.WriteString(m.Domain + siweHeaderSuffix + "\n")
+	b.WriteString(m.Address + "\n\n")
+	if m.Statement != "" {
+		b.WriteString(m.Statement + "\n")
+	}
+	b.WriteString("\n")
+
+	b.WriteString("URI: " + m.URI + "\n")
+	b.WriteString("Version: " + m.Version + "\n")
+	b.WriteString("Chain ID: " + strconv.FormatInt(m.ChainID, 10) + "\n")
+	b.WriteString("Nonce: " + m.Nonce + "\n")
+	b.WriteString("Issued At: " + m.IssuedAt)
+	if m.ExpirationTime != "" {
+		b.WriteString("\nExpiration Time: " + m.ExpirationTime)
+	}
+	if m.NotBefore != "" {
+		b.WriteString("\nNot Before: " + m.NotBefore)
+	}
+	if m.RequestID != "" {
+		b.WriteString("\nRequest ID: " + m.RequestID)
+	}
+	if len(m.Resources) > 0 {
+		b.WriteString("\nResources:")
+		for _, resource := range m.Resources {
+			b.WriteString("\n- " + resource)
+		}
+	}
+	return b.String()
+}
+
+// parseSIWEMessage 解析 EIP-4361 消息
+func parseSIWEMessage(text string) (*siweMessage, error) {
+	lines := strings.Split(text, "\n")
+	if len(lines) < 8 {
+		return nil, fmt.Errorf("SIWE消息格式无效")
+	}
+
+	m := &siweMessage{}
+	if !strings.HasSuffix(lines[0], siweHeaderSuffix) {
+		return nil, fmt.Errorf("SIWE消息头无效")
+	}
+	m.Domain = strings.TrimSuffix(lines[0], siweHeaderSuffix)
+	if m.Domain == "" {
+		return nil, fmt.Errorf("SIWE消息缺少domain")
+	}
+	m.Address = lines[1]
+	if lines[2] != "" {
+		return nil, fmt.Errorf("SIWE消息格式无效: 地址后应为空行")
+	}
+
+	// 可选的 statement 之后必须是一个空行
+	i := 3
+	if lines[i] != "" {
+		m.Statement = lines[i]
+		i++
+	}
+	if i >= len(lines) || lines[i] != "" {
+		return nil, fmt.Errorf("SIWE消息格式无效: statement后应为空行")
+	}
+	i++
+
+	// 必需字段按固定顺序出现，可选字段随后
+	fields := []struct {
+		name     string
+		required bool
+		target   *string
+	}{
+		{"URI", true, &m.URI},
+		{"Version", true, &m.Version},
+		{"Chain ID", true, nil},
+		{"Nonce", true, &m.Nonce},
+		{"Issued At", true, &m.IssuedAt},
+		{"Expiration Time", false, &m.ExpirationTime},
+		{"Not Before", false, &m.NotBefore},
+		{"Request ID", false, &m.RequestID},
+	}
+	for _, field := range fields {
+		prefix := field.name + ": "
+		if i < len(lines) && strings.HasPrefix(lines[i], prefix) {
+			value := strings.TrimPrefix(lines[i], prefix)
+			if field.target != nil {
+				*field.target = value
+			} else {
+				chainID, err := strconv.ParseInt(value, 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("SIWE消息Chain ID无效: %s", value)
+				}
+				m.ChainID = chainID
+			}
+			i++
+		} else if field.required {
+			return nil, fmt.Errorf("SIWE消息缺少%s", field.name)
+		}
+	}
+
+	if i < len(lines) {
+		if lines[i] != "Resources:" {
+			return nil, fmt.Errorf("SIWE消息包含无法识别的内容: %s", lines[i])
+		}
+		for i++; i < len(lines); i++ {
+			if !strings.HasPrefix(lines[i], "- ") {
+				return nil, fmt.Errorf("SIWE消息resources格式无效")
+			}
+			m.Resources = append(m.Resources, strings.TrimPrefix(lines[i], "- "))
+		}
+	}
+
+	// 地址必须是 EIP-55 校验和格式
+	checksummed, err := toChecksumAddress(m.Address)
+	if err != nil {
+		return nil, err
+	}
+	if checksummed != m.Address {
+		return nil, fmt.Errorf("SIWE消息地址不是EIP-55格式: %s", m.Address)
+	}
+	if m.Version != "1" {
+		return nil, fmt.Errorf("不支持的SIWE版本: %s", m.Version)
+	}
+	if len(m.Nonce) < 8 {
+		return nil, fmt.Errorf("SIWE消息nonce太短")
+	}
+	for _, ch := range m.Nonce {
+		if !(ch >= '0' && ch <= '9' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z') {
+			return nil, fmt.Errorf("SIWE消息nonce必须为字母数字")
+		}
+	}
+	return m, nil
+}
+
+// newSIWEMessage 为指定地址和来源生成登录消息
+func newSIWEMessage(address, origin, nonce string, now time.Time) (*siweMessage, error) {
+	checksummed, err := toChecksumAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := rpIDForOrigin(origin); err != nil {
+		return nil, err
+	}
+	u, _ := url.Parse(origin)
+
+	return &siweMessage{
+		Domain:         u.Host,
+		Address:        checksummed,
+		Statement:      config.SIWE_STATEMENT,
+		URI:            origin,
+		Version:        "1",
+		ChainID:        int64(config.SIWE_CHAIN_ID),
+		Nonce:          nonce,
+		IssuedAt:       now.UTC().Format(time.RFC3339),
+		ExpirationTime: now.Add(siweMessageTTL).UTC().Format(time.RFC3339),
+		Resources:      config.SIWE_RESOURCES,
+	}, nil
+}
+
+// Validate 校验消息的域名、来源、链ID和时间窗口（签名另行验证）
+func (m *siweMessage) Validate(now time.Time) error {
+	u, err := url.Parse(m.URI)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("SIWE消息URI无效: %s", m.URI)
+	}
+	// URI 的来源必须在允许列表中，domain 必须与 URI 的主机一致
+	if _, err := rpIDForOrigin(u.Scheme + "://" + u.Host); err != nil {
+		return err
+	}
+	if m.Domain != u.Host {
+		return fmt.Errorf("SIWE消息domain %s 与URI %s 不一致", m.Domain, m.URI)
+	}
+	if m.ChainID != int64(config.SIWE_CHAIN_ID) {
+		return fmt.Errorf("不支持的链ID: %d", m.ChainID)
+	}
+
+	issuedAt, err := time.Parse(time.RFC3339, m.IssuedAt)
+	if err != nil {
+		return fmt.Errorf("SIWE消息Issued At无效")
+	}
+	if issuedAt.After(now.Add(siweClockSkew)) {
+		return fmt.Errorf("SIWE消息签发时间在未来")
+	}
+	if m.ExpirationTime != "" {
+		expiration, err := time.Parse(time.RFC3339, m.ExpirationTime)
+		if err != nil {
+			return fmt.Errorf("SIWE消息Expiration Time无效")
+		}
+		if now.After(expiration) {
+			return fmt.Errorf("SIWE消息已过期")
+		}
+	}
+	if m.NotBefore != "" {
+		notBefore, err := time.Parse(time.RFC3339, m.NotBefore)
+		if err != nil {
+			return fmt.Errorf("SIWE消息Not Before无效")
+		}
+		if now.Add(siweClockSkew).Before(notBefore) {
+			return fmt.Errorf("SIWE消息尚未生效")
+		}
+	}
+	return nil
+}
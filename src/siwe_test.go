@@ -0,0 +1,191 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cosmos-link/did-login/config"
+)
+
+// siweSpecExample EIP-4361 规范中的示例消息
+const siweSpecExample = `service.invalid wants you to sign in with your Ethereum account:
+0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2
+
+I accept the ServiceOrg Terms of Service: https://service.invalid/tos
+
+URI: https://service.invalid/login
+Version: 1
+Chain ID: 1
+Nonce: 32891756
+Issued At: 2021-09-30T16:25:24Z
+Resources:
+- ipfs://bafybeiemxf5abjwjbikoz4mc3a3dla6ual3jsgpdr4cjr3oz3evfyavhwq/
+- https://example.com/my-web2-claim.json`
+
+var siweSpecIssuedAt = time.Date(2021, 9, 30, 16, 25, 24, 0, time.UTC)
+
+// withSIWEConfig 测试期间只允许 service.invalid 来源，链ID为 1
+func withSIWEConfig(t *testing.T) {
+	t.Helper()
+	savedOrigins, savedRPID := config.WEBAUTHN_ALLOWED_ORIGINS, config.WEBAUTHN_RP_ID
+	savedLocalhost, savedChainID := config.WEBAUTHN_ALLOW_LOCALHOST, config.SIWE_CHAIN_ID
+	t.Cleanup(func() {
+		config.WEBAUTHN_ALLOWED_ORIGINS, config.WEBAUTHN_RP_ID = savedOrigins, savedRPID
+		config.WEBAUTHN_ALLOW_LOCALHOST, config.SIWE_CHAIN_ID = savedLocalhost, savedChainID
+	})
+	config.WEBAUTHN_ALLOWED_ORIGINS = []string{"https://service.invalid"}
+	config.WEBAUTHN_RP_ID = "service.invalid"
+	config.WEBAUTHN_ALLOW_LOCALHOST = false
+	config.SIWE_CHAIN_ID = 1
+}
+
+func TestParseSIWEMessageSpecExample(t *testing.T) {
+	m, err := parseSIWEMessage(siweSpecExample)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := siweMessage{
+		Domain:    "service.invalid",
+		Address:   "0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2",
+		Statement: "I accept the ServiceOrg Terms of Service: https://service.invalid/tos",
+		URI:       "https://service.invalid/login",
+		Version:   "1",
+		ChainID:   1,
+		Nonce:     "32891756",
+		IssuedAt:  "2021-09-30T16:25:24Z",
+		Resources: []string{
+			"ipfs://bafybeiemxf5abjwjbikoz4mc3a3dla6ual3jsgpdr4cjr3oz3evfyavhwq/",
+			"https://example.com/my-web2-claim.json",
+		},
+	}
+	if m.Domain != want.Domain || m.Address != want.Address || m.Statement != want.Statement ||
+		m.URI != want.URI || m.Version != want.Version || m.ChainID != want.ChainID ||
+		m.Nonce != want.Nonce || m.IssuedAt != want.IssuedAt ||
+		strings.Join(m.Resources, " ") != strings.Join(want.Resources, " ") {
+		t.Fatalf("解析结果错误:\n got %+v\nwant %+v", *m, want)
+	}
+	if m.String() != siweSpecExample {
+		t.Fatalf("重新生成的消息与原文不一致:\n%s", m.String())
+	}
+}
+
+func TestParseSIWEMessage(t *testing.T) {
+	optional := strings.Replace(siweSpecExample, "Issued At: 2021-09-30T16:25:24Z",
+		"Issued At: 2021-09-30T16:25:24Z\nExpiration Time: 2021-09-30T16:30:24Z\nNot Before: 2021-09-30T16:25:24Z\nRequest ID: req-1", 1)
+	noStatement := strings.Replace(siweSpecExample, "I accept the ServiceOrg Terms of Service: https://service.invalid/tos\n\n", "\n", 1)
+
+	tests := []struct {
+		name    string
+		message string
+		ok      bool
+	}{
+		{"spec example", siweSpecExample, true},
+		{"optional fields", optional, true},
+		{"without statement", noStatement, true},
+		{"without resources", siweSpecExample[:strings.Index(siweSpecExample, "\nResources:")], true},
+		{"missing uri", strings.Replace(siweSpecExample, "URI: https://service.invalid/login\n", "", 1), false},
+		{"missing version", strings.Replace(siweSpecExample, "Version: 1\n", "", 1), false},
+		{"missing chain id", strings.Replace(siweSpecExample, "Chain ID: 1\n", "", 1), false},
+		{"missing nonce", strings.Replace(siweSpecExample, "Nonce: 32891756\n", "", 1), false},
+		{"missing issued at", strings.Replace(siweSpecExample, "Issued At: 2021-09-30T16:25:24Z\n", "", 1), false},
+		{"reordered fields", strings.Replace(siweSpecExample, "Version: 1\nChain ID: 1", "Chain ID: 1\nVersion: 1", 1), false},
+		{"reordered optional fields", strings.Replace(optional, "Expiration Time: 2021-09-30T16:30:24Z\nNot Before: 2021-09-30T16:25:24Z",
+			"Not Before: 2021-09-30T16:25:24Z\nExpiration Time: 2021-09-30T16:30:24Z", 1), false},
+		{"unknown field", strings.Replace(siweSpecExample, "Resources:", "Foo: bar\nResources:", 1), false},
+		{"bad header", strings.Replace(siweSpecExample, "wants you to sign in", "wants you to log in", 1), false},
+		{"missing domain", strings.TrimPrefix(siweSpecExample, "service.invalid"), false},
+		{"lowercase address", strings.Replace(siweSpecExample, "0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2", "0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2", 1), false},
+		{"invalid address", strings.Replace(siweSpecExample, "0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2", "0xC02aaA39", 1), false},
+		{"unsupported version", strings.Replace(siweSpecExample, "Version: 1", "Version: 2", 1), false},
+		{"invalid chain id", strings.Replace(siweSpecExample, "Chain ID: 1", "Chain ID: mainnet", 1), false},
+		{"short nonce", strings.Replace(siweSpecExample, "Nonce: 32891756", "Nonce: 1234", 1), false},
+		{"non alphanumeric nonce", strings.Replace(siweSpecExample, "Nonce: 32891756", "Nonce: 3289-1756", 1), false},
+		{"bad resource", strings.Replace(siweSpecExample, "- https://example.com", "https://example.com", 1), false},
+		{"too short", "service.invalid wants you to sign in with your Ethereum account:", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseSIWEMessage(tt.message)
+			if (err == nil) != tt.ok {
+				t.Fatalf("parseSIWEMessage() = %v, want ok=%t", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestSIWEMessageValidate(t *testing.T) {
+	withSIWEConfig(t)
+	parse := func(message string) *siweMessage {
+		m, err := parseSIWEMessage(message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+	withTimes := func(expiration, notBefore string) *siweMessage {
+		m := parse(siweSpecExample)
+		m.ExpirationTime, m.NotBefore = expiration, notBefore
+		return m
+	}
+
+	tests := []struct {
+		name    string
+		message *siweMessage
+		now     time.Time
+		ok      bool
+	}{
+		{"spec example", parse(siweSpecExample), siweSpecIssuedAt.Add(time.Minute), true},
+		{"wrong domain", parse(strings.Replace(siweSpecExample, "service.invalid wants", "evil.invalid wants", 1)), siweSpecIssuedAt, false},
+		{"uri not allowed", parse(strings.Replace(siweSpecExample, "URI: https://service.invalid/login", "URI: https://evil.invalid/login", 1)), siweSpecIssuedAt, false},
+		{"wrong chain id", parse(strings.Replace(siweSpecExample, "Chain ID: 1", "Chain ID: 137", 1)), siweSpecIssuedAt, false},
+		{"issued in the future", parse(siweSpecExample), siweSpecIssuedAt.Add(-2 * siweClockSkew), false},
+		{"issued within clock skew", parse(siweSpecExample), siweSpecIssuedAt.Add(-siweClockSkew / 2), true},
+		{"before expiration", withTimes("2021-09-30T16:30:24Z", ""), siweSpecIssuedAt.Add(4 * time.Minute), true},
+		{"expired", withTimes("2021-09-30T16:30:24Z", ""), siweSpecIssuedAt.Add(6 * time.Minute), false},
+		{"invalid expiration", withTimes("tomorrow", ""), siweSpecIssuedAt, false},
+		{"not yet valid", withTimes("", "2021-09-30T16:35:24Z"), siweSpecIssuedAt, false},
+		{"valid after not before", withTimes("", "2021-09-30T16:35:24Z"), siweSpecIssuedAt.Add(10 * time.Minute), true},
+		{"not before within clock skew", withTimes("", "2021-09-30T16:25:54Z"), siweSpecIssuedAt, true},
+		{"invalid not before", withTimes("", "soon"), siweSpecIssuedAt, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.message.Validate(tt.now)
+			if (err == nil) != tt.ok {
+				t.Fatalf("Validate() = %v, want ok=%t", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestNewSIWEMessage(t *testing.T) {
+	withSIWEConfig(t)
+	nonce := generateSIWENonce()
+	m, err := newSIWEMessage("0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2", "https://service.invalid", nonce, siweSpecIssuedAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Address != "0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2" {
+		t.Fatalf("地址不是EIP-55格式: %s", m.Address)
+	}
+
+	// 生成的消息能被解析并通过校验
+	parsed, err := parseSIWEMessage(m.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Nonce != nonce || parsed.Domain != "service.invalid" {
+		t.Fatalf("解析结果错误: %+v", parsed)
+	}
+	if err := parsed.Validate(siweSpecIssuedAt); err != nil {
+		t.Fatal(err)
+	}
+	if err := parsed.Validate(siweSpecIssuedAt.Add(siweMessageTTL + time.Second)); err == nil {
+		t.Fatal("过期的消息通过了校验")
+	}
+
+	if _, err := newSIWEMessage("0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2", "https://evil.invalid", nonce, siweSpecIssuedAt); err == nil {
+		t.Fatal("不允许的来源生成了消息")
+	}
+}
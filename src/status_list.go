@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cosmos-link/did-login/config"
+	"gorm.io/gorm"
+)
+
+// 状态列表用途（W3C Bitstring Status List）
+const (
+	statusPurposeRevocation = "revocation" // 撤销，不可恢复
+	statusPurposeSuspension = "suspension" // 暂停，可恢复
+)
+
+// 每个状态列表的位数（规范要求至少 16KB，即 131072 位，保证群体隐私）
+const statusListSize = 131072
+
+// 状态列表凭证缓存时间（多副本部署时其他副本的变更最迟在此时间后生效）
+const statusListCacheTTL = time.Minute
+
+// 缓存的状态列表凭证数量上限（每个列表按用途各缓存一份），超出时淘汰最早生成的
+const maxStatusListCacheEntries = 64
+
+// errStatusListNotFound 列表编号超出已分配的范围
+var errStatusListNotFound = errors.New("状态列表不存在")
+
+// statusListPurposes 支持的用途
+var statusListPurposes = []string{statusPurposeRevocation, statusPurposeSuspension}
+
+// 状态变更原因
+const (
+	statusReasonUserTypeChanged = "user_type_changed"
+	statusReasonAccountDeleted  = "account_deleted"
+	statusReasonAdmin           = "admin"
+)
+
+// allocateStatusIndex 按签发记录ID分配状态列表位置：列表编号从 1 开始，每个列表 statusListSize 位
+func allocateStatusIndex(recordID uint) (list uint, index int) {
+	n := int(recordID - 1)
+	return uint(n/statusListSize) + 1, n % statusListSize
+}
+
+// highestStatusList 已分配的最大状态列表编号，由签发记录的自增ID推算；没有签发记录时为 0
+func highestStatusList(db *gorm.DB) (uint, error) {
+	var maxID uint
+	if err := db.Model(&IssuedCredential{}).Select("COALESCE(MAX(id), 0)").Scan(&maxID).Error; err != nil {
+		return 0, err
+	}
+	if maxID == 0 {
+		return 0, nil
+	}
+	list, _ := allocateStatusIndex(maxID)
+	return list, nil
+}
+
+// statusListURL 状态列表凭证的公开地址
+func statusListURL(purpose string, list uint) string {
+	return fmt.Sprintf("%s/api/credentials/status/%s/%d", config.ISSUER_BASE_URL, purpose, list)
+}
+
+// credentialStatusEntries 凭证的 credentialStatus：撤销和暂停各一项，使用同一个位置
+func credentialStatusEntries(list uint, index int) []interface{} {
+	entries := make([]interface{}, 0, len(statusListPurposes))
+	for _, purpose := range statusListPurposes {
+		listURL := statusListURL(purpose, list)
+		entries = append(entries, map[string]interface{}{
+			"id":                   listURL + "#" + strconv.Itoa(index),
+			"type":                 "BitstringStatusListEntry",
+			"statusPurpose":        purpose,
+			"statusListIndex":      strconv.Itoa(index),
+			"statusListCredential": listURL,
+		})
+	}
+	return entries
+}
+
+// encodeStatusList 设置指定位置的位（索引 0 为第一个字节的最高位），GZIP 压缩后以 multibase base64url 编码
+func encodeStatusList(indexes []int) (string, error) {
+	bits := make([]byte, statusListSize/8)
+	for _, index := range indexes {
+		if index < 0 || index >= statusListSize {
+			continue
+		}
+		bits[index/8] |= 0x80 >> (index % 8)
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(bits); err != nil {
+		return "", err
+	}
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+	return "u" + base64.RawURLEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// statusListColumn 用途对应的时间字段，非空即表示置位
+func statusListColumn(purpose string) (string, error) {
+	switch purpose {
+	case statusPurposeRevocation:
+		return "revoked_at", nil
+	case statusPurposeSuspension:
+		return "suspended_at", nil
+	}
+	return "", fmt.Errorf("不支持的状态用途: %s", purpose)
+}
+
+// buildStatusListCredential 从签发记录生成（未签名的）状态列表凭证
+func buildStatusListCredential(purpose string, list uint, now time.Time) (*verifiableCredential, error) {
+	column, err := statusListColumn(purpose)
+	if err != nil {
+		return nil, err
+	}
+	var indexes []int
+	if err := DB.Model(&IssuedCredential{}).
+		Where("status_list = ? AND "+column+" IS NOT NULL", list).
+		Pluck("status_index", &indexes).Error; err != nil {
+		return nil, err
+	}
+	encoded, err := encodeStatusList(indexes)
+	if err != nil {
+		return nil, err
+	}
+
+	listURL := statusListURL(purpose, list)
+	return &verifiableCredential{
+		Context:   []string{vcContextV2},
+		ID:        listURL,
+		Type:      []string{"VerifiableCredential", "BitstringStatusListCredential"},
+		Issuer:    config.ISSUER_DID,
+		ValidFrom: now.UTC().Format(time.RFC3339),
+		CredentialSubject: map[string]interface{}{
+			"id":            listURL + "#list",
+			"type":          "BitstringStatusList",
+			"statusPurpose": purpose,
+			"encodedList":   encoded,
+		},
+	}, nil
+}
+
+// statusListCacheEntry 已签名的状态列表凭证
+type statusListCacheEntry struct {
+	credential *verifiableCredential // ldp_vc
+	jwt        string                // jwt_vc_json
+	builtAt    time.Time
+}
+
+// statusListCache 按 用途/列表编号 缓存已签名的状态列表凭证，状态变更时失效
+type statusListCache struct {
+	mu      sync.Mutex
+	entries map[string]*statusListCacheEntry
+}
+
+var statusLists = &statusListCache{entries: map[string]*statusListCacheEntry{}}
+
+// Get 返回已签名的状态列表凭证，缓存过期或已失效时重新生成。
+// 列表编号超出已分配的范围时返回 errStatusListNotFound，不为任意编号生成和缓存空列表
+func (s *statusListCache) Get(purpose string, list uint) (*statusListCacheEntry, error) {
+	cacheKey := fmt.Sprintf("%s/%d", purpose, list)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry, ok := s.entries[cacheKey]; ok && time.Since(entry.builtAt) < statusListCacheTTL {
+		return entry, nil
+	}
+
+	highest, err := highestStatusList(DB)
+	if err != nil {
+		return nil, err
+	}
+	if list == 0 || list > highest {
+		return nil, errStatusListNotFound
+	}
+
+	key := platformKeys.Current()
+	if key == nil {
+		return nil, fmt.Errorf("平台签名密钥不可用")
+	}
+	now := time.Now()
+	vc, err := buildStatusListCredential(purpose, list, now)
+	if err != nil {
+		return nil, err
+	}
+	jwtVC, err := signCredentialJWT(vc, key)
+	if err != nil {
+		return nil, err
+	}
+	if err := addDataIntegrityProof(vc, key, now); err != nil {
+		return nil, err
+	}
+
+	entry := &statusListCacheEntry{credential: vc, jwt: jwtVC, builtAt: now}
+	s.storeLocked(cacheKey, entry)
+	return entry, nil
+}
+
+// storeLocked 写入缓存：先清理过期项，仍达到上限时淘汰最早生成的一项。调用方需持有 s.mu
+func (s *statusListCache) storeLocked(cacheKey string, entry *statusListCacheEntry) {
+	if _, ok := s.entries[cacheKey]; !ok && len(s.entries) >= maxStatusListCacheEntries {
+		oldestKey := ""
+		for k, e := range s.entries {
+			if time.Since(e.builtAt) >= statusListCacheTTL {
+				delete(s.entries, k)
+				continue
+			}
+			if oldestKey == "" || e.builtAt.Before(s.entries[oldestKey].builtAt) {
+				oldestKey = k
+			}
+		}
+		if len(s.entries) >= maxStatusListCacheEntries {
+			delete(s.entries, oldestKey)
+		}
+	}
+	s.entries[cacheKey] = entry
+}
+
+// Invalidate 清空缓存
+func (s *statusListCache) Invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = map[string]*statusListCacheEntry{}
+}
+
+// setCredentialStatus 设置单个凭证的状态位。撤销不可恢复；暂停可以解除
+func setCredentialStatus(tx *gorm.DB, credentialID string, purpose string, value bool, reason string) (*IssuedCredential, error) {
+	column, err := statusListColumn(purpose)
+	if err != nil {
+		return nil, err
+	}
+
+	var record IssuedCredential
+	if err := tx.Where("credential_id = ?", credentialID).First(&record).Error; err != nil {
+		return nil, err
+	}
+	if purpose == statusPurposeRevocation && !value && record.RevokedAt != nil {
+		return nil, fmt.Errorf("撤销不可恢复")
+	}
+	current := record.RevokedAt != nil
+	if purpose == statusPurposeSuspension {
+		current = record.SuspendedAt != nil
+	}
+	if current == value {
+		return &record, nil
+	}
+
+	updates := map[string]interface{}{column: nil}
+	if value {
+		updates[column] = time.Now()
+		updates["status_reason"] = reason
+	}
+	if err := tx.Model(&record).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+	statusLists.Invalidate()
+	fmt.Printf("凭证状态变更: %s %s=%t (%s)\n", credentialID, purpose, value, reason)
+	return &record, nil
+}
+
+// revokeCredentialsForDID 撤销某个 DID 所有未撤销的凭证。
+// 在调用方的事务中执行，调用方需在事务提交后调用 statusLists.Invalidate()
+func revokeCredentialsForDID(tx *gorm.DB, did string, reason string) error {
+	result := tx.Model(&IssuedCredential{}).
+		Where("did = ? AND revoked_at IS NULL", did).
+		Updates(map[string]interface{}{"revoked_at": time.Now(), "status_reason": reason})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		fmt.Printf("已撤销 %s 的 %d 个凭证 (%s)\n", did, result.RowsAffected, reason)
+	}
+	return nil
+}
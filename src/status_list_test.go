@@ -0,0 +1,84 @@
+package main
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// fakeMaxCredentialID 签发记录的最大ID
+func fakeMaxCredentialID(id int64) fakeResult {
+	return fakeResult{match: "MAX(id)", columns: []string{"max"}, rows: [][]driver.Value{{id}}}
+}
+
+func TestStatusListCacheRejectsUnallocatedList(t *testing.T) {
+	savedDB, savedKeys := DB, platformKeys
+	defer func() { DB, platformKeys = savedDB, savedKeys }()
+	platformKeys = newKeyStore(t.TempDir(), signingAlgES256, 0)
+	if err := platformKeys.Load(""); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		maxID int64
+		list  uint
+		found bool
+	}{
+		{0, 1, false}, // 尚未签发任何凭证
+		{5, 1, true},
+		{5, 2, false},
+		{statusListSize, 2, false},
+		{statusListSize + 1, 2, true},
+		{5, 1 << 31, false},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("max=%d/list=%d", tt.maxID, tt.list), func(t *testing.T) {
+			DB, _ = newFakeGormDB(t, fakeMaxCredentialID(tt.maxID))
+			cache := &statusListCache{entries: map[string]*statusListCacheEntry{}}
+			entry, err := cache.Get(statusPurposeRevocation, tt.list)
+			if !tt.found {
+				if !errors.Is(err, errStatusListNotFound) {
+					t.Fatalf("err = %v, want errStatusListNotFound", err)
+				}
+				if len(cache.entries) != 0 {
+					t.Fatal("不存在的列表被缓存")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if entry.credential.ID != statusListURL(statusPurposeRevocation, tt.list) {
+				t.Fatalf("状态列表 ID = %s", entry.credential.ID)
+			}
+		})
+	}
+}
+
+func TestStatusListCacheEvictsOldestEntry(t *testing.T) {
+	cache := &statusListCache{entries: map[string]*statusListCacheEntry{}}
+	now := time.Now()
+	for i := 0; i < maxStatusListCacheEntries; i++ {
+		cache.storeLocked(fmt.Sprintf("revocation/%d", i+1), &statusListCacheEntry{builtAt: now.Add(time.Duration(i) * time.Millisecond)})
+	}
+	cache.storeLocked("suspension/1", &statusListCacheEntry{builtAt: now.Add(time.Second)})
+	if len(cache.entries) != maxStatusListCacheEntries {
+		t.Fatalf("缓存项数 = %d, want %d", len(cache.entries), maxStatusListCacheEntries)
+	}
+	if _, ok := cache.entries["revocation/1"]; ok {
+		t.Fatal("最早生成的缓存项未被淘汰")
+	}
+
+	// 过期项优先清理
+	cache.entries["revocation/2"].builtAt = now.Add(-2 * statusListCacheTTL)
+	cache.entries["revocation/3"].builtAt = now.Add(-2 * statusListCacheTTL)
+	cache.storeLocked("suspension/2", &statusListCacheEntry{builtAt: now})
+	if len(cache.entries) != maxStatusListCacheEntries-1 {
+		t.Fatalf("缓存项数 = %d, want %d", len(cache.entries), maxStatusListCacheEntries-1)
+	}
+	if _, ok := cache.entries["revocation/4"]; !ok {
+		t.Fatal("未过期的缓存项被淘汰")
+	}
+}
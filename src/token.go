@@ -0,0 +1,157 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/cosmos-link/did-login/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// 登录方式（amr，RFC 8176）；SIWE 和可验证展示没有注册值，使用自定义值
+const (
+	amrPassword     = "pwd"  // Email + 密码
+	amrWebAuthn     = "hwk"  // WebAuthn 认证器（硬件密钥）
+	amrSIWE         = "siwe" // Sign-In with Ethereum
+	amrPresentation = "vp"   // 可验证展示（OpenID4VP）
+)
+
+// accessTokenClaims 访问令牌载荷：sub 为用户 DID
+type accessTokenClaims struct {
+	UserType  string   `json:"user_type"`
+	AMR       []string `json:"amr"`
+	SessionID string   `json:"sid"` // 会话ID，一次登录及其后续刷新签发的令牌相同；注销按 sid 生效，jti 只用于区分单个令牌
+	jwt.RegisteredClaims
+}
+
+// tokenService 统一签发和校验登录令牌，使用非对称密钥签名，依赖方通过 JWKS 按 kid 验证
+type tokenService struct {
+	keys     *keyStore
+	issuer   string
+	audience string
+	ttl      time.Duration
+}
+
+// 登录令牌签名密钥库：退役密钥在宽限期内继续发布，之后删除
+var tokenKeys = newKeyStore(config.JWT_KEYSTORE_PATH, config.JWT_KEY_ALGORITHM, tokenKeyGracePeriod())
+
+// 全局令牌服务
+var authTokens = &tokenService{
+	keys:     tokenKeys,
+	issuer:   config.JWT_ISSUER,
+	audience: config.JWT_AUDIENCE,
+	ttl:      time.Duration(config.JWT_ACCESS_TTL_MINUTES) * time.Minute,
+}
+
+// tokenKeyGracePeriod 退役密钥的宽限期，不少于令牌有效期，保证轮换前签发的令牌都能验证
+func tokenKeyGracePeriod() time.Duration {
+	grace := time.Duration(config.JWT_KEY_GRACE_HOURS) * time.Hour
+	if ttl := time.Duration(config.JWT_ACCESS_TTL_MINUTES) * time.Minute; grace < ttl {
+		return ttl
+	}
+	return grace
+}
+
+// initTokenService 加载令牌签名密钥，并按配置的间隔自动轮换。密钥不可用时拒绝启动
+func initTokenService() {
+	if err := tokenKeys.Load(""); err != nil {
+		panic(fmt.Sprintf("加载令牌签名密钥失败: %v", err))
+	}
+	current := tokenKeys.Current()
+	fmt.Printf("✅ 登录令牌 iss=%s aud=%s，有效期 %s，当前签名密钥 %s（%s），共 %d 个验证密钥\n",
+		authTokens.issuer, authTokens.audience, authTokens.ttl, current.ID, current.Algorithm, len(tokenKeys.VerificationKeys()))
+
+	interval := time.Duration(config.JWT_KEY_ROTATION_DAYS) * 24 * time.Hour
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			// 重新读取目录：多副本共享密钥库时可以看到其他副本轮换的密钥，并清理过期密钥
+			if err := tokenKeys.Load(""); err != nil {
+				fmt.Printf("Warning: 重新加载令牌签名密钥失败: %v\n", err)
+				continue
+			}
+			if time.Since(tokenKeys.Current().CreatedAt) < interval {
+				continue
+			}
+			if _, err := rotateTokenKey(); err != nil {
+				fmt.Printf("Warning: 轮换令牌签名密钥失败: %v\n", err)
+			}
+		}
+	}()
+}
+
+// rotateTokenKey 轮换令牌签名密钥（定时任务和管理接口共用）
+func rotateTokenKey() (*signingKey, error) {
+	previous := tokenKeys.Current()
+	key, err := tokenKeys.Rotate()
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("令牌签名密钥已轮换: %s -> %s（%s）\n", previous.ID, key.ID, key.Algorithm)
+	return key, nil
+}
+
+// newTokenID 随机令牌ID（jti）
+func newTokenID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Issue 为用户签发访问令牌，sessionID 为所属会话，amr 为本次登录使用的认证方式
+func (s *tokenService) Issue(did string, userType string, sessionID string, amr ...string) (string, error) {
+	now := time.Now()
+	claims := &accessTokenClaims{
+		UserType:  userType,
+		AMR:       amr,
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.issuer,
+			Subject:   did,
+			Audience:  jwt.ClaimStrings{s.audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.ttl)),
+			ID:        newTokenID(),
+		},
+	}
+	key := s.keys.Current()
+	if key == nil {
+		return "", fmt.Errorf("令牌签名密钥不可用")
+	}
+	return key.SignJWT(claims, nil)
+}
+
+// Parse 校验签名、iss、aud 和有效期，返回令牌载荷
+func (s *tokenService) Parse(tokenString string) (*accessTokenClaims, error) {
+	claims := &accessTokenClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		key := s.keys.LookupOrReload(kid)
+		if key == nil {
+			return nil, fmt.Errorf("未知的签名密钥 %q", kid)
+		}
+		if t.Method.Alg() != key.Algorithm {
+			return nil, fmt.Errorf("签名算法 %s 与密钥 %s 不匹配", t.Method.Alg(), kid)
+		}
+		return key.Signer.Public(), nil
+	},
+		jwt.WithValidMethods([]string{signingAlgES256, signingAlgEdDSA}),
+		jwt.WithIssuer(s.issuer),
+		jwt.WithAudience(s.audience),
+		jwt.WithIssuedAt(),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Subject == "" || claims.SessionID == "" {
+		return nil, fmt.Errorf("令牌缺少 sub 或 sid")
+	}
+	return claims, nil
+}
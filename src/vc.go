@@ -0,0 +1,185 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"time"
+
+	"github.com/cosmos-link/did-login/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// W3C Verifiable Credentials Data Model 2.0
+const vcContextV2 = "https://www.w3.org/ns/credentials/v2"
+
+// 平台签发的用户类型凭证
+const credentialTypeUserType = "UserTypeCredential"
+
+// 凭证格式（与 OpenID4VCI 的 format 标识一致）
+const (
+	credentialFormatJWT = "jwt_vc_json" // VC-JOSE-COSE，typ=vc+jwt
+	credentialFormatLDP = "ldp_vc"      // 内嵌 Data Integrity 证明
+)
+
+// Data Integrity 密码套件。签发使用 RDF 数据集规范化（RDFC-1.0）的套件；
+// 早期签发的凭证使用 JCS（RFC 8785）规范化的套件，仍然可以验证
+const (
+	cryptosuiteECDSARDFC = "ecdsa-rdfc-2019" // ES256 密钥
+	cryptosuiteEdDSARDFC = "eddsa-rdfc-2022" // EdDSA 密钥
+	cryptosuiteECDSAJCS  = "ecdsa-jcs-2019"  // ES256 密钥（仅验证）
+	cryptosuiteEdDSAJCS  = "eddsa-jcs-2022"  // EdDSA 密钥（仅验证）
+)
+
+// cryptosuitesForAlgorithm 密钥算法可以使用的密码套件，第一个用于签发
+func cryptosuitesForAlgorithm(algorithm string) []string {
+	if algorithm == signingAlgEdDSA {
+		return []string{cryptosuiteEdDSARDFC, cryptosuiteEdDSAJCS}
+	}
+	return []string{cryptosuiteECDSARDFC, cryptosuiteECDSAJCS}
+}
+
+// dataIntegrityProof W3C Data Integrity 证明
+type dataIntegrityProof struct {
+	Context            interface{} `json:"@context,omitempty"`
+	Type               string      `json:"type"`
+	Cryptosuite        string      `json:"cryptosuite"`
+	Created            string      `json:"created"`
+	VerificationMethod string      `json:"verificationMethod"`
+	ProofPurpose       string      `json:"proofPurpose"`
+	ProofValue         string      `json:"proofValue,omitempty"`
+}
+
+// verifiableCredential 可验证凭证
+type verifiableCredential struct {
+	Context           []string               `json:"@context"`
+	ID                string                 `json:"id"`
+	Type              []string               `json:"type"`
+	Issuer            string                 `json:"issuer"`
+	ValidFrom         string                 `json:"validFrom"`
+	ValidUntil        string                 `json:"validUntil,omitempty"`
+	CredentialSubject map[string]interface{} `json:"credentialSubject"`
+	CredentialStatus  interface{}            `json:"credentialStatus,omitempty"`
+	Proof             *dataIntegrityProof    `json:"proof,omitempty"`
+}
+
+// vcJWTClaims JWT-VC 的载荷：凭证本身加上 JWT 注册声明
+type vcJWTClaims struct {
+	verifiableCredential
+	jwt.RegisteredClaims
+}
+
+// newCredentialID 生成 urn:uuid 形式的凭证ID（UUID v4）
+func newCredentialID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// newUserTypeCredential 生成（未签名的）用户类型凭证：证明 DID 属于已验证的某类账户
+func newUserTypeCredential(user *User, now time.Time) (*verifiableCredential, error) {
+	holder, err := normalizeDID(user.DID)
+	if err != nil {
+		return nil, fmt.Errorf("用户DID无效: %v", err)
+	}
+
+	return &verifiableCredential{
+		Context:    []string{vcContextV2},
+		ID:         newCredentialID(),
+		Type:       []string{"VerifiableCredential", credentialTypeUserType},
+		Issuer:     config.ISSUER_DID,
+		ValidFrom:  now.UTC().Format(time.RFC3339),
+		ValidUntil: now.Add(credentialTTL()).UTC().Format(time.RFC3339),
+		CredentialSubject: map[string]interface{}{
+			"id":            holder,
+			"userType":      user.UserType,
+			"emailVerified": user.EmailVerifiedAt != nil,
+		},
+	}, nil
+}
+
+// credentialTTL 凭证有效期
+func credentialTTL() time.Duration {
+	return time.Duration(config.ISSUER_CREDENTIAL_TTL_DAYS) * 24 * time.Hour
+}
+
+// signCredentialJWT 以 JWT-VC 形式签发凭证，kid 为平台 DID 的验证方法ID
+func signCredentialJWT(vc *verifiableCredential, key *signingKey) (string, error) {
+	validFrom, _ := time.Parse(time.RFC3339, vc.ValidFrom)
+
+	claims := vcJWTClaims{
+		verifiableCredential: *vc,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    vc.Issuer,
+			Subject:   fmt.Sprint(vc.CredentialSubject["id"]),
+			ID:        vc.ID,
+			IssuedAt:  jwt.NewNumericDate(validFrom),
+			NotBefore: jwt.NewNumericDate(validFrom),
+		},
+	}
+	if validUntil, err := time.Parse(time.RFC3339, vc.ValidUntil); err == nil {
+		claims.ExpiresAt = jwt.NewNumericDate(validUntil)
+	}
+	return key.SignJWT(claims, map[string]interface{}{
+		"kid": config.ISSUER_DID + "#" + key.ID,
+		"typ": "vc+jwt",
+		"cty": "vc",
+	})
+}
+
+// addDataIntegrityProof 为凭证添加 Data Integrity 证明（ecdsa-rdfc-2019 / eddsa-rdfc-2022）
+func addDataIntegrityProof(vc *verifiableCredential, key *signingKey, now time.Time) error {
+	vc.Proof = nil
+	proof := &dataIntegrityProof{
+		Type:               "DataIntegrityProof",
+		Cryptosuite:        cryptosuitesForAlgorithm(key.Algorithm)[0],
+		Created:            now.UTC().Format(time.RFC3339),
+		VerificationMethod: config.ISSUER_DID + "#" + key.ID,
+		ProofPurpose:       "assertionMethod",
+	}
+	hashData, err := dataIntegrityHashData(vc, proof)
+	if err != nil {
+		return err
+	}
+	sig, err := key.Sign(hashData)
+	if err != nil {
+		return err
+	}
+
+	proof.ProofValue = "z" + base58Encode(sig)
+	vc.Proof = proof
+	return nil
+}
+
+// dataIntegrityHashData 待签名数据: SHA-256(规范化(证明配置)) || SHA-256(规范化(无证明的文档))
+// 证明配置不含 proofValue，@context 与文档一致；按证明的密码套件选择 RDFC-1.0 或 JCS 规范化
+func dataIntegrityHashData(vc *verifiableCredential, proof *dataIntegrityProof) ([]byte, error) {
+	unsecured := *vc
+	unsecured.Proof = nil
+	proofConfig := *proof
+	proofConfig.ProofValue = ""
+	proofConfig.Context = vc.Context
+
+	var canonicalize func(interface{}) ([]byte, error)
+	switch proof.Cryptosuite {
+	case cryptosuiteECDSARDFC, cryptosuiteEdDSARDFC:
+		canonicalize = canonicalizeJSONLD
+	case cryptosuiteECDSAJCS, cryptosuiteEdDSAJCS:
+		canonicalize = canonicalizeJSON
+	default:
+		return nil, fmt.Errorf("不支持的密码套件 %s", proof.Cryptosuite)
+	}
+	canonicalProof, err := canonicalize(proofConfig)
+	if err != nil {
+		return nil, err
+	}
+	canonicalDocument, err := canonicalize(unsecured)
+	if err != nil {
+		return nil, err
+	}
+	proofHash := sha256.Sum256(canonicalProof)
+	documentHash := sha256.Sum256(canonicalDocument)
+	return append(proofHash[:], documentHash[:]...), nil
+}
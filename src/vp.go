@@ -0,0 +1,380 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cosmos-link/did-login/config"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	secpecdsa "github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// 可验证展示登录的展示定义ID（DIF Presentation Exchange）
+const (
+	presentationDefinitionID = "did-portal-login"
+	inputDescriptorUserType  = "user_type_credential"
+)
+
+// -------------------------- ES256K --------------------------
+
+// signingMethodES256K secp256k1 JWT 签名（did:ethr 持有者使用）
+// ES256K 为 r || s，ES256K-R 追加恢复ID；验证时恢复公钥并与期望的以太坊地址比较
+type signingMethodES256K struct {
+	name        string
+	recoverable bool
+}
+
+var (
+	signingMethodES256KPlain = &signingMethodES256K{name: "ES256K"}
+	signingMethodES256KR     = &signingMethodES256K{name: "ES256K-R", recoverable: true}
+)
+
+func init() {
+	jwt.RegisterSigningMethod(signingMethodES256KPlain.Alg(), func() jwt.SigningMethod { return signingMethodES256KPlain })
+	jwt.RegisterSigningMethod(signingMethodES256KR.Alg(), func() jwt.SigningMethod { return signingMethodES256KR })
+}
+
+func (m *signingMethodES256K) Alg() string {
+	return m.name
+}
+
+// Verify key 为期望的以太坊地址（小写 0x 形式）
+func (m *signingMethodES256K) Verify(signingString string, sig []byte, key interface{}) error {
+	address, ok := key.(string)
+	if !ok {
+		return jwt.ErrInvalidKeyType
+	}
+	digest := sha256.Sum256([]byte(signingString))
+
+	var candidates [][]byte
+	switch {
+	case m.recoverable && len(sig) == 65:
+		candidates = [][]byte{sig}
+	case !m.recoverable && len(sig) == 64:
+		// 没有恢复ID时两个候选公钥都尝试
+		candidates = [][]byte{append(slices.Clone(sig), 0), append(slices.Clone(sig), 1)}
+	default:
+		return jwt.ErrSignatureInvalid
+	}
+	for _, candidate := range candidates {
+		if recovered, err := recoverEthereumAddress(digest[:], candidate); err == nil && recovered == address {
+			return nil
+		}
+	}
+	return jwt.ErrSignatureInvalid
+}
+
+// Sign key 为 *secp256k1.PrivateKey
+func (m *signingMethodES256K) Sign(signingString string, key interface{}) ([]byte, error) {
+	priv, ok := key.(*secp256k1.PrivateKey)
+	if !ok {
+		return nil, jwt.ErrInvalidKeyType
+	}
+	digest := sha256.Sum256([]byte(signingString))
+	compact := secpecdsa.SignCompact(priv, digest[:], false) // [27 + recid] || r || s
+	sig := append(compact[1:], compact[0]-27)
+	if !m.recoverable {
+		sig = sig[:64]
+	}
+	return sig, nil
+}
+
+// -------------------------- 展示请求 --------------------------
+
+// presentationDefinition 登录时要求展示的凭证：平台签发的 UserTypeCredential
+func presentationDefinition() map[string]interface{} {
+	return map[string]interface{}{
+		"id":      presentationDefinitionID,
+		"purpose": "使用平台签发的用户类型凭证登录",
+		"input_descriptors": []interface{}{
+			map[string]interface{}{
+				"id": inputDescriptorUserType,
+				"format": map[string]interface{}{
+					credentialFormatJWT: map[string]interface{}{"alg": []string{signingAlgES256, signingAlgEdDSA}},
+					credentialFormatLDP: map[string]interface{}{"proof_type": []string{"DataIntegrityProof"}},
+				},
+				"constraints": map[string]interface{}{
+					"fields": []interface{}{
+						map[string]interface{}{
+							"path":   []string{"$.type", "$.vc.type"},
+							"filter": map[string]interface{}{"type": "array", "contains": map[string]interface{}{"const": credentialTypeUserType}},
+						},
+						map[string]interface{}{
+							"path":   []string{"$.issuer", "$.iss"},
+							"filter": map[string]interface{}{"type": "string", "const": config.ISSUER_DID},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// -------------------------- 展示验证 --------------------------
+
+// verifiablePresentation 可验证展示，verifiableCredential 中每项是 JWT-VC 字符串或内嵌证明的凭证对象
+type verifiablePresentation struct {
+	Context              []string          `json:"@context"`
+	Type                 []string          `json:"type"`
+	Holder               string            `json:"holder"`
+	VerifiableCredential []json.RawMessage `json:"verifiableCredential"`
+}
+
+// vpJWTClaims JWT-VP（jwt_vp_json）的载荷
+type vpJWTClaims struct {
+	Nonce string                 `json:"nonce"`
+	VP    verifiablePresentation `json:"vp"`
+	jwt.RegisteredClaims
+}
+
+// presentationResult 验证通过的展示
+type presentationResult struct {
+	Holder     string // 规范化后的持有者 DID
+	Nonce      string
+	Credential *verifiableCredential
+}
+
+// verifyPresentationJWT 验证 JWT-VP：签名由持有者 DID 的验证方法签出，aud 为平台，
+// 并验证其中的用户类型凭证（签发者、有效期、撤销状态、主体与持有者一致）
+func verifyPresentationJWT(ctx context.Context, vpToken string, now time.Time) (*presentationResult, error) {
+	claims := &vpJWTClaims{}
+	_, err := jwt.ParseWithClaims(vpToken, claims, func(t *jwt.Token) (interface{}, error) {
+		return holderVerificationKey(ctx, claims.Issuer, t)
+	},
+		jwt.WithValidMethods([]string{signingMethodES256KPlain.Alg(), signingMethodES256KR.Alg()}),
+		jwt.WithAudience(config.ISSUER_DID),
+		jwt.WithTimeFunc(func() time.Time { return now }),
+		jwt.WithLeeway(siweClockSkew),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("展示签名验证失败: %v", err)
+	}
+
+	holder, err := normalizeDID(claims.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	if claims.VP.Holder != "" {
+		if vpHolder, err := normalizeDID(claims.VP.Holder); err != nil || vpHolder != holder {
+			return nil, fmt.Errorf("展示的holder与签名者不一致")
+		}
+	}
+	if claims.Nonce == "" {
+		return nil, fmt.Errorf("展示缺少nonce")
+	}
+	if !slices.Contains(claims.VP.Type, "VerifiablePresentation") {
+		return nil, fmt.Errorf("展示类型无效")
+	}
+
+	for _, raw := range claims.VP.VerifiableCredential {
+		vc, err := verifyEmbeddedCredential(raw, now)
+		if err != nil {
+			return nil, err
+		}
+		if vc == nil {
+			continue
+		}
+		subject, err := normalizeDID(fmt.Sprint(vc.CredentialSubject["id"]))
+		if err != nil || subject != holder {
+			return nil, fmt.Errorf("凭证主体与展示持有者不一致")
+		}
+		return &presentationResult{Holder: holder, Nonce: claims.Nonce, Credential: vc}, nil
+	}
+	return nil, fmt.Errorf("展示中没有用户类型凭证")
+}
+
+// holderVerificationKey 解析持有者 DID，按 JWT 头部 kid（或 authentication 中的第一个）选择验证方法，
+// 返回 ES256K 验证所需的以太坊地址
+func holderVerificationKey(ctx context.Context, holder string, t *jwt.Token) (interface{}, error) {
+	doc, err := didResolver.Resolve(ctx, holder)
+	if err != nil {
+		return nil, fmt.Errorf("解析持有者DID失败: %v", err)
+	}
+
+	methodID, _ := t.Header["kid"].(string)
+	if methodID == "" && len(doc.Authentication) > 0 {
+		methodID, _ = doc.Authentication[0].(string)
+	}
+	// kid 中的 DID 部分可能未规范化（如裸地址、网络名），统一替换为文档ID
+	if i := strings.Index(methodID, "#"); i >= 0 {
+		if base := methodID[:i]; base != "" {
+			if normalized, err := normalizeDID(base); err != nil || normalized != doc.ID {
+				return nil, fmt.Errorf("验证方法 %s 不属于持有者", methodID)
+			}
+		}
+		methodID = doc.ID + methodID[i:]
+	}
+	if !slices.Contains(doc.Authentication, interface{}(methodID)) {
+		return nil, fmt.Errorf("验证方法 %s 不能用于认证", methodID)
+	}
+	method := doc.FindVerificationMethod(methodID)
+	if method == nil {
+		return nil, fmt.Errorf("找不到验证方法 %s", methodID)
+	}
+
+	if method.Type != "EcdsaSecp256k1RecoveryMethod2020" || method.BlockchainAccountID == "" {
+		return nil, fmt.Errorf("不支持的验证方法类型: %s", method.Type)
+	}
+	// blockchainAccountId: eip155:<chainId>:<address>
+	account := method.BlockchainAccountID[strings.LastIndex(method.BlockchainAccountID, ":")+1:]
+	return normalizeEthereumAddress(account)
+}
+
+// verifyEmbeddedCredential 验证展示中的一个凭证；不是用户类型凭证时返回 nil（跳过）
+func verifyEmbeddedCredential(raw json.RawMessage, now time.Time) (*verifiableCredential, error) {
+	var vc *verifiableCredential
+	var jwtString string
+	if err := json.Unmarshal(raw, &jwtString); err == nil {
+		// 先不验证签名解析类型，其他签发者的凭证直接跳过
+		claims := &vcJWTClaims{}
+		if _, _, err := jwt.NewParser().ParseUnverified(jwtString, claims); err != nil {
+			return nil, fmt.Errorf("凭证格式无效: %v", err)
+		}
+		if !slices.Contains(claims.Type, credentialTypeUserType) {
+			return nil, nil
+		}
+		if vc, err = verifyCredentialJWT(jwtString, now); err != nil {
+			return nil, err
+		}
+	} else {
+		vc = &verifiableCredential{}
+		if err := json.Unmarshal(raw, vc); err != nil {
+			return nil, fmt.Errorf("凭证格式无效: %v", err)
+		}
+		if !slices.Contains(vc.Type, credentialTypeUserType) {
+			return nil, nil
+		}
+		if err := verifyDataIntegrityProof(vc); err != nil {
+			return nil, err
+		}
+	}
+
+	if vc.Issuer != config.ISSUER_DID {
+		return nil, fmt.Errorf("不受信任的凭证签发者: %s", vc.Issuer)
+	}
+	if err := checkCredentialValidity(vc, now); err != nil {
+		return nil, err
+	}
+	if err := checkCredentialRevocation(vc); err != nil {
+		return nil, err
+	}
+	return vc, nil
+}
+
+// verifyCredentialJWT 验证平台签发的 JWT-VC
+func verifyCredentialJWT(tokenString string, now time.Time) (*verifiableCredential, error) {
+	claims := &vcJWTClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		key := platformVerificationKey(kid)
+		if key == nil {
+			return nil, fmt.Errorf("未知的签名密钥 %s", kid)
+		}
+		if t.Method.Alg() != key.Algorithm {
+			return nil, fmt.Errorf("签名算法与密钥不匹配")
+		}
+		return key.Signer.Public(), nil
+	},
+		jwt.WithValidMethods([]string{signingAlgES256, signingAlgEdDSA}),
+		jwt.WithIssuer(config.ISSUER_DID),
+		jwt.WithTimeFunc(func() time.Time { return now }),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("凭证签名验证失败: %v", err)
+	}
+	return &claims.verifiableCredential, nil
+}
+
+// platformVerificationKey 按验证方法ID（<平台DID>#<kid>）或 kid 查找平台密钥
+func platformVerificationKey(methodID string) *signingKey {
+	kid := strings.TrimPrefix(methodID, config.ISSUER_DID+"#")
+	if kid == "" || strings.Contains(kid, "#") {
+		return nil
+	}
+	return platformKeys.Lookup(kid)
+}
+
+// verifyDataIntegrityProof 验证平台签发凭证的 Data Integrity 证明
+// （ecdsa-rdfc-2019 / eddsa-rdfc-2022，以及早期签发的 ecdsa-jcs-2019 / eddsa-jcs-2022）
+func verifyDataIntegrityProof(vc *verifiableCredential) error {
+	proof := vc.Proof
+	if proof == nil || proof.Type != "DataIntegrityProof" || proof.ProofPurpose != "assertionMethod" {
+		return fmt.Errorf("凭证缺少有效的证明")
+	}
+	key := platformVerificationKey(proof.VerificationMethod)
+	if key == nil {
+		return fmt.Errorf("未知的验证方法 %s", proof.VerificationMethod)
+	}
+	if !slices.Contains(cryptosuitesForAlgorithm(key.Algorithm), proof.Cryptosuite) {
+		return fmt.Errorf("密码套件 %s 与密钥不匹配", proof.Cryptosuite)
+	}
+	if !strings.HasPrefix(proof.ProofValue, "z") {
+		return fmt.Errorf("proofValue 必须为 base58btc 编码")
+	}
+	sig, err := base58Decode(proof.ProofValue[1:])
+	if err != nil {
+		return err
+	}
+	hashData, err := dataIntegrityHashData(vc, proof)
+	if err != nil {
+		return err
+	}
+
+	valid := false
+	switch pub := key.Signer.Public().(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(hashData)
+		valid = len(sig) == 64 && ecdsa.Verify(pub, digest[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:]))
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(pub, hashData, sig)
+	}
+	if !valid {
+		return fmt.Errorf("凭证证明签名无效")
+	}
+	return nil
+}
+
+// checkCredentialValidity 检查凭证的 validFrom / validUntil
+func checkCredentialValidity(vc *verifiableCredential, now time.Time) error {
+	validFrom, err := time.Parse(time.RFC3339, vc.ValidFrom)
+	if err != nil {
+		return fmt.Errorf("凭证validFrom无效")
+	}
+	if now.Add(siweClockSkew).Before(validFrom) {
+		return fmt.Errorf("凭证尚未生效")
+	}
+	if vc.ValidUntil != "" {
+		validUntil, err := time.Parse(time.RFC3339, vc.ValidUntil)
+		if err != nil {
+			return fmt.Errorf("凭证validUntil无效")
+		}
+		if now.After(validUntil) {
+			return fmt.Errorf("凭证已过期")
+		}
+	}
+	return nil
+}
+
+// checkCredentialRevocation 按签发记录检查凭证是否已撤销或暂停（与状态列表的数据来源相同）
+func checkCredentialRevocation(vc *verifiableCredential) error {
+	var record IssuedCredential
+	if err := DB.Where("credential_id = ?", vc.ID).First(&record).Error; err != nil {
+		return fmt.Errorf("凭证 %s 不是平台签发的", vc.ID)
+	}
+	if record.RevokedAt != nil {
+		return fmt.Errorf("凭证已撤销")
+	}
+	if record.SuspendedAt != nil {
+		return fmt.Errorf("凭证已暂停")
+	}
+	return nil
+}
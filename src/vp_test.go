@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cosmos-link/did-login/config"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// vpTestHolder did:ethr 持有者及其 secp256k1 私钥
+type vpTestHolder struct {
+	key *secp256k1.PrivateKey
+	did string
+}
+
+func newVPTestHolder(t *testing.T) *vpTestHolder {
+	t.Helper()
+	key, address := newTestEthereumKey(t)
+	did, err := normalizeDID(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &vpTestHolder{key: key, did: did}
+}
+
+// withVPTestPlatform 测试期间使用临时的平台签名密钥和 DID 解析器
+func withVPTestPlatform(t *testing.T) {
+	t.Helper()
+	savedKeys, savedResolver, savedDB := platformKeys, didResolver, DB
+	t.Cleanup(func() { platformKeys, didResolver, DB = savedKeys, savedResolver, savedDB })
+	platformKeys = newKeyStore(t.TempDir(), signingAlgES256, 0)
+	if err := platformKeys.Load(""); err != nil {
+		t.Fatal(err)
+	}
+	didResolver = newDIDResolver(nil)
+}
+
+// issueTestCredential 平台为 subject 签发 JWT 形式的用户类型凭证
+func issueTestCredential(t *testing.T, subject string, key *signingKey) (*verifiableCredential, string) {
+	t.Helper()
+	vc, err := newUserTypeCredential(&User{DID: subject, UserType: "个人"}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := signCredentialJWT(vc, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return vc, token
+}
+
+// issuedCredentialRecord 签发记录查询的结果
+func issuedCredentialRecord(vc *verifiableCredential, revokedAt, suspendedAt *time.Time) fakeResult {
+	row := []driver.Value{vc.ID, nil, nil}
+	if revokedAt != nil {
+		row[1] = *revokedAt
+	}
+	if suspendedAt != nil {
+		row[2] = *suspendedAt
+	}
+	return fakeResult{
+		match:   "ykt_issued_credentials",
+		columns: []string{"credential_id", "revoked_at", "suspended_at"},
+		rows:    [][]driver.Value{row},
+	}
+}
+
+// signTestPresentation 持有者以 ES256K 签名 JWT-VP，modify 可在签名前修改载荷
+func signTestPresentation(t *testing.T, holder *vpTestHolder, nonce string, credentials []string, modify func(*vpJWTClaims)) string {
+	t.Helper()
+	now := time.Now()
+	claims := &vpJWTClaims{
+		Nonce: nonce,
+		VP: verifiablePresentation{
+			Context: []string{vcContextV2},
+			Type:    []string{"VerifiablePresentation"},
+			Holder:  holder.did,
+		},
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    holder.did,
+			Audience:  jwt.ClaimStrings{config.ISSUER_DID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(5 * time.Minute)),
+		},
+	}
+	for _, credential := range credentials {
+		raw, _ := json.Marshal(credential)
+		claims.VP.VerifiableCredential = append(claims.VP.VerifiableCredential, raw)
+	}
+	if modify != nil {
+		modify(claims)
+	}
+
+	token := jwt.NewWithClaims(signingMethodES256KPlain, claims)
+	token.Header["kid"] = holder.did + "#controller"
+	signed, err := token.SignedString(holder.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestVerifyPresentationJWTRoundTrip(t *testing.T) {
+	withVPTestPlatform(t)
+	holder := newVPTestHolder(t)
+	vc, credential := issueTestCredential(t, holder.did, platformKeys.Current())
+	DB, _ = newFakeGormDB(t, issuedCredentialRecord(vc, nil, nil))
+
+	for _, method := range []*signingMethodES256K{signingMethodES256KPlain, signingMethodES256KR} {
+		t.Run(method.Alg(), func(t *testing.T) {
+			token := jwt.NewWithClaims(method, &vpJWTClaims{
+				Nonce: "nonce-1",
+				VP: verifiablePresentation{
+					Type:                 []string{"VerifiablePresentation"},
+					VerifiableCredential: []json.RawMessage{json.RawMessage(`"` + credential + `"`)},
+				},
+				RegisteredClaims: jwt.RegisteredClaims{
+					Issuer:    holder.did,
+					Audience:  jwt.ClaimStrings{config.ISSUER_DID},
+					ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+				},
+			})
+			vpToken, err := token.SignedString(holder.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := verifyPresentationJWT(context.Background(), vpToken, time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Holder != holder.did || result.Nonce != "nonce-1" || result.Credential.ID != vc.ID {
+				t.Fatalf("结果错误: %+v", result)
+			}
+			if result.Credential.CredentialSubject["userType"] != "个人" {
+				t.Fatalf("凭证主体错误: %v", result.Credential.CredentialSubject)
+			}
+		})
+	}
+}
+
+func TestVerifyPresentationJWTRejects(t *testing.T) {
+	withVPTestPlatform(t)
+	holder := newVPTestHolder(t)
+	other := newVPTestHolder(t)
+	vc, credential := issueTestCredential(t, holder.did, platformKeys.Current())
+	_, otherCredential := issueTestCredential(t, other.did, platformKeys.Current())
+
+	// 其他签发者用自己的密钥签发的同类凭证
+	foreignKeys := newKeyStore(t.TempDir(), signingAlgES256, 0)
+	if err := foreignKeys.Load(""); err != nil {
+		t.Fatal(err)
+	}
+	foreignVC, err := newUserTypeCredential(&User{DID: holder.did, UserType: "政府"}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	foreignVC.Issuer = "did:web:issuer.invalid"
+	foreignCredential, err := foreignKeys.Current().SignJWT(vcJWTClaims{
+		verifiableCredential: *foreignVC,
+		RegisteredClaims:     jwt.RegisteredClaims{Issuer: foreignVC.Issuer, Subject: holder.did},
+	}, map[string]interface{}{"kid": foreignVC.Issuer + "#" + foreignKeys.Current().ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	revokedAt := time.Now().Add(-time.Hour)
+	tests := []struct {
+		name    string
+		vpToken string
+		records []fakeResult // 签发记录查询的结果，为空时查不到记录
+		errText string
+	}{
+		{"wrong audience", signTestPresentation(t, holder, "nonce", []string{credential}, func(c *vpJWTClaims) {
+			c.Audience = jwt.ClaimStrings{"did:web:verifier.invalid"}
+		}), []fakeResult{issuedCredentialRecord(vc, nil, nil)}, "展示签名验证失败"},
+		{"expired presentation", signTestPresentation(t, holder, "nonce", []string{credential}, func(c *vpJWTClaims) {
+			c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+		}), []fakeResult{issuedCredentialRecord(vc, nil, nil)}, "展示签名验证失败"},
+		{"signed by another key", signTestPresentation(t, other, "nonce", []string{credential}, func(c *vpJWTClaims) {
+			c.Issuer, c.VP.Holder = holder.did, holder.did
+		}), []fakeResult{issuedCredentialRecord(vc, nil, nil)}, "展示签名验证失败"},
+		{"missing nonce", signTestPresentation(t, holder, "", []string{credential}, nil), []fakeResult{issuedCredentialRecord(vc, nil, nil)}, "nonce"},
+		{"holder differs from signer", signTestPresentation(t, holder, "nonce", []string{credential}, func(c *vpJWTClaims) {
+			c.VP.Holder = other.did
+		}), []fakeResult{issuedCredentialRecord(vc, nil, nil)}, "holder"},
+		{"credential subject differs from holder", signTestPresentation(t, holder, "nonce", []string{otherCredential}, nil), []fakeResult{issuedCredentialRecord(vc, nil, nil)}, "凭证主体"},
+		{"credential revoked", signTestPresentation(t, holder, "nonce", []string{credential}, nil), []fakeResult{issuedCredentialRecord(vc, &revokedAt, nil)}, "已撤销"},
+		{"credential suspended", signTestPresentation(t, holder, "nonce", []string{credential}, nil), []fakeResult{issuedCredentialRecord(vc, nil, &revokedAt)}, "已暂停"},
+		{"credential not issued by platform", signTestPresentation(t, holder, "nonce", []string{credential}, nil), nil, "不是平台签发的"},
+		{"foreign issuer", signTestPresentation(t, holder, "nonce", []string{foreignCredential}, nil), []fakeResult{issuedCredentialRecord(vc, nil, nil)}, "凭证签名验证失败"},
+		{"no credential", signTestPresentation(t, holder, "nonce", nil, nil), []fakeResult{issuedCredentialRecord(vc, nil, nil)}, "没有用户类型凭证"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			DB, _ = newFakeGormDB(t, tt.records...)
+			_, err := verifyPresentationJWT(context.Background(), tt.vpToken, time.Now())
+			if err == nil {
+				t.Fatal("无效的展示被接受")
+			}
+			if !strings.Contains(err.Error(), tt.errText) {
+				t.Fatalf("错误信息 %q 不包含 %q", err, tt.errText)
+			}
+		})
+	}
+}
+
+// 展示中的 nonce 只能使用一次（/api/login/vp/submit 验证后消费 nonce）
+func TestPresentationNonceSingleUse(t *testing.T) {
+	withVPTestPlatform(t)
+	savedStore := challengeStore
+	t.Cleanup(func() { challengeStore = savedStore })
+	challengeStore = newMemoryChallengeStore(maxChallengesPerCeremony)
+
+	holder := newVPTestHolder(t)
+	vc, credential := issueTestCredential(t, holder.did, platformKeys.Current())
+	DB, _ = newFakeGormDB(t, issuedCredentialRecord(vc, nil, nil))
+
+	ctx := context.Background()
+	nonce := generateChallenge()
+	if err := challengeStore.Save(ctx, ceremonyPresentation, nonce, challengeEntry{Challenge: nonce}, challengeTTL); err != nil {
+		t.Fatal(err)
+	}
+	vpToken := signTestPresentation(t, holder, nonce, []string{credential}, nil)
+
+	for i, wantOK := range []bool{true, false} {
+		result, err := verifyPresentationJWT(ctx, vpToken, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = challengeStore.Consume(ctx, ceremonyPresentation, result.Nonce)
+		if (err == nil) != wantOK {
+			t.Fatalf("第 %d 次提交: Consume() = %v, want ok=%t", i+1, err, wantOK)
+		}
+	}
+
+	// 未由平台下发的 nonce
+	unknown := signTestPresentation(t, holder, "unknown-nonce", []string{credential}, nil)
+	result, err := verifyPresentationJWT(ctx, unknown, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := challengeStore.Consume(ctx, ceremonyPresentation, result.Nonce); err == nil {
+		t.Fatal("未下发的 nonce 被接受")
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/cosmos-link/did-login/config"
+	"github.com/fxamacker/cbor/v2"
+)
+
+// authenticatorData 标志位（WebAuthn Level 2 §6.1）
+const (
+	authFlagUserPresent       byte = 0x01 // UP: 用户在场
+	authFlagUserVerified      byte = 0x04 // UV: 用户已验证
+	authFlagBackupEligible    byte = 0x08 // BE: 凭证可备份（Level 3）
+	authFlagBackupState       byte = 0x10 // BS: 凭证已备份（Level 3）
+	authFlagAttestedCredData  byte = 0x40 // AT: 包含凭证数据
+	authFlagExtensionDataIncl byte = 0x80 // ED: 包含扩展数据
+)
+
+// attestationObject CBOR结构
+type attestationObject struct {
+	Fmt      string                     `cbor:"fmt"`
+	AttStmt  map[string]cbor.RawMessage `cbor:"attStmt"`
+	AuthData []byte                     `cbor:"authData"`
+}
+
+// attestedCredentialData 注册时authenticatorData中携带的凭证信息
+type attestedCredentialData struct {
+	AAGUID              []byte // 认证器型号标识（16字节）
+	CredentialID        []byte // 凭证ID（原始字节）
+	CredentialPublicKey []byte // COSE_Key（原始CBOR编码）
+}
+
+// authenticatorData 解析后的认证器数据
+type authenticatorData struct {
+	Raw                []byte
+	RPIDHash           []byte
+	Flags              byte
+	SignCount          uint32
+	AttestedCredential *attestedCredentialData
+	Extensions         cbor.RawMessage
+}
+
+// UserPresent 是否设置了UP标志位
+func (a *authenticatorData) UserPresent() bool {
+	return a.Flags&authFlagUserPresent != 0
+}
+
+// UserVerified 是否设置了UV标志位
+func (a *authenticatorData) UserVerified() bool {
+	return a.Flags&authFlagUserVerified != 0
+}
+
+// BackupEligible 是否设置了BE标志位
+func (a *authenticatorData) BackupEligible() bool {
+	return a.Flags&authFlagBackupEligible != 0
+}
+
+// BackupState 是否设置了BS标志位
+func (a *authenticatorData) BackupState() bool {
+	return a.Flags&authFlagBackupState != 0
+}
+
+// formatAAGUID 将16字节AAGUID格式化为UUID字符串
+func formatAAGUID(b []byte) string {
+	if len(b) != 16 {
+		return ""
+	}
+	h := hex.EncodeToString(b)
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32]
+}
+
+// decodeWebAuthnBase64 解码前端传来的base64url数据（兼容缺少padding和标准base64）
+func decodeWebAuthnBase64(s string) ([]byte, error) {
+	padded := s
+	switch len(s) % 4 {
+	case 2:
+		padded += "=="
+	case 3:
+		padded += "="
+	}
+
+	b, err := base64.URLEncoding.DecodeString(padded)
+	if err != nil {
+		// 尝试 StdEncoding
+		b, err = base64.StdEncoding.DecodeString(padded)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// parseAttestationObject 解析CBOR编码的attestationObject
+func parseAttestationObject(raw []byte) (*attestationObject, *authenticatorData, error) {
+	var obj attestationObject
+	if err := cbor.Unmarshal(raw, &obj); err != nil {
+		return nil, nil, fmt.Errorf("attestationObject CBOR解码失败: %v", err)
+	}
+	if obj.Fmt == "" {
+		return nil, nil, fmt.Errorf("attestationObject缺少fmt")
+	}
+	if len(obj.AuthData) == 0 {
+		return nil, nil, fmt.Errorf("attestationObject缺少authData")
+	}
+
+	authData, err := parseAuthenticatorData(obj.AuthData)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &obj, authData, nil
+}
+
+// parseAuthenticatorData 按规范解析authenticatorData:
+// rpIdHash(32) | flags(1) | signCount(4) | [attestedCredentialData] | [extensions]
+func parseAuthenticatorData(raw []byte) (*authenticatorData, error) {
+	if len(raw) < 37 {
+		return nil, fmt.Errorf("authenticatorData太短")
+	}
+
+	authData := &authenticatorData{
+		Raw:       raw,
+		RPIDHash:  raw[0:32],
+		Flags:     raw[32],
+		SignCount: binary.BigEndian.Uint32(raw[33:37]),
+	}
+	rest := raw[37:]
+
+	if authData.Flags&authFlagAttestedCredData != 0 {
+		// aaguid(16) | credentialIdLength(2) | credentialId | credentialPublicKey
+		if len(rest) < 18 {
+			return nil, fmt.Errorf("attestedCredentialData太短")
+		}
+		credIDLen := int(binary.BigEndian.Uint16(rest[16:18]))
+		if credIDLen > 1023 {
+			return nil, fmt.Errorf("凭证ID长度无效: %d", credIDLen)
+		}
+		if len(rest) < 18+credIDLen {
+			return nil, fmt.Errorf("凭证ID数据不完整")
+		}
+
+		cred := &attestedCredentialData{
+			AAGUID:       rest[0:16],
+			CredentialID: rest[18 : 18+credIDLen],
+		}
+		rest = rest[18+credIDLen:]
+
+		// COSE_Key 是CBOR编码，长度需要解码后才能确定
+		var coseKey cbor.RawMessage
+		remaining, err := cbor.UnmarshalFirst(rest, &coseKey)
+		if err != nil {
+			return nil, fmt.Errorf("解析凭证公钥失败: %v", err)
+		}
+		cred.CredentialPublicKey = []byte(coseKey)
+		authData.AttestedCredential = cred
+		rest = remaining
+	}
+
+	if authData.Flags&authFlagExtensionDataIncl != 0 {
+		var ext cbor.RawMessage
+		remaining, err := cbor.UnmarshalFirst(rest, &ext)
+		if err != nil {
+			return nil, fmt.Errorf("解析扩展数据失败: %v", err)
+		}
+		authData.Extensions = ext
+		rest = remaining
+	}
+
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("authenticatorData包含多余的 %d 字节", len(rest))
+	}
+
+	// 不可备份的凭证不可能处于已备份状态
+	if authData.BackupState() && !authData.BackupEligible() {
+		return nil, fmt.Errorf("authenticatorData标志位无效: 设置了BS但未设置BE")
+	}
+	return authData, nil
+}
+
+// rpIDForOrigin 校验来源是否在允许列表中，并返回该来源对应的RP ID
+// 本地开发来源（allow_localhost）使用 localhost，其余来源使用配置的 rp_id
+func rpIDForOrigin(origin string) (string, error) {
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") {
+		return "", fmt.Errorf("无效的来源: %s", origin)
+	}
+	if u.Path != "" || u.RawQuery != "" || u.User != nil {
+		return "", fmt.Errorf("无效的来源: %s", origin)
+	}
+
+	if config.WEBAUTHN_ALLOW_LOCALHOST && u.Hostname() == "localhost" {
+		return "localhost", nil
+	}
+
+	allowed := false
+	for _, pattern := range config.WEBAUTHN_ALLOWED_ORIGINS {
+		if originMatches(pattern, u) {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return "", fmt.Errorf("不允许的来源: %s", origin)
+	}
+
+	// 来源域名必须等于 RP ID 或是其子域名
+	rpId := config.WEBAUTHN_RP_ID
+	host := u.Hostname()
+	if host != rpId && !strings.HasSuffix(host, "."+rpId) {
+		return "", fmt.Errorf("来源 %s 与 RP ID %s 不匹配", origin, rpId)
+	}
+	return rpId, nil
+}
+
+// originMatches 匹配单个来源规则，支持 https://*.example.com 形式的子域名通配符
+func originMatches(pattern string, u *url.URL) bool {
+	p, err := url.Parse(pattern)
+	if err != nil || p.Scheme != u.Scheme || p.Port() != u.Port() {
+		return false
+	}
+
+	patternHost := strings.ToLower(p.Hostname())
+	host := strings.ToLower(u.Hostname())
+	if strings.HasPrefix(patternHost, "*.") {
+		return strings.HasSuffix(host, patternHost[1:]) && len(host) > len(patternHost)-1
+	}
+	return host == patternHost
+}
+
+// rpIDForRequest 生成注册/登录选项时使用的RP ID
+// 只有来自允许的本地开发来源的请求使用 localhost，其余一律使用配置的 rp_id
+func rpIDForRequest(origin string) string {
+	if origin != "" {
+		if rpId, err := rpIDForOrigin(origin); err == nil {
+			return rpId
+		}
+	}
+	return config.WEBAUTHN_RP_ID
+}